@@ -0,0 +1,139 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import "fmt"
+
+// AnomalyKind definitions.
+const (
+	// AnomalyNonMinimalLength is a Length in the long form where the short
+	// form or fewer octets would do.
+	AnomalyNonMinimalLength AnomalyKind = iota + 1
+	// AnomalyTrailingBytes is the bytes after the messages that are ignored.
+	AnomalyTrailingBytes
+	// AnomalyUnknownTag is an element of unknown Tag in the Transaction
+	// Portion or in the Component Portion.
+	AnomalyUnknownTag
+	// AnomalyLengthMismatch is the contents of a message that are not
+	// consumed entirely by its portions.
+	AnomalyLengthMismatch
+)
+
+// AnomalyKind is the kind of Anomaly.
+type AnomalyKind int
+
+// String returns the AnomalyKind in string.
+func (k AnomalyKind) String() string {
+	switch k {
+	case AnomalyNonMinimalLength:
+		return "non-minimal-length"
+	case AnomalyTrailingBytes:
+		return "trailing-bytes"
+	case AnomalyUnknownTag:
+		return "unknown-tag"
+	case AnomalyLengthMismatch:
+		return "length-mismatch"
+	}
+	return fmt.Sprintf("unknown(%d)", int(k))
+}
+
+// Anomaly is a recoverable oddity found while parsing, which is tolerated and
+// does not make the parse fail.
+type Anomaly struct {
+	Kind AnomalyKind
+	// Offset is the position of the element from the beginning of the bytes
+	// given to the parser.
+	Offset int
+	// Tag is the Tag of the element, or zero for AnomalyTrailingBytes.
+	Tag Tag
+	// Detail describes the Anomaly in human readable string.
+	Detail string
+}
+
+// String returns the Anomaly in human readable string.
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s at offset %d: %s", a.Kind, a.Offset, a.Detail)
+}
+
+// reportAnomalies calls the hook given with WithAnomalyHook for the anomalies
+// in the message tx parsed from b, which starts at base of the whole input.
+//
+// Only the structure defined by TCAP is inspected, i.e., the Parameters in
+// Components are not, as they belong to the upper layer.
+func (o *parseOptions) reportAnomalies(b []byte, base int, tx *IE) {
+	if o.anomalyHook == nil {
+		return
+	}
+
+	o.checkLength(b, base, tx)
+	if n := childrenLen(tx); n != len(tx.Value) {
+		o.anomalyHook(Anomaly{
+			Kind: AnomalyLengthMismatch, Offset: base, Tag: tx.Tag,
+			Detail: fmt.Sprintf("%d of %d octets consumed", n, len(tx.Value)),
+		})
+	}
+
+	o.forEachChild(b, base, tx, func(pb []byte, poff int, portion *IE) {
+		switch portion.Tag {
+		case TagOriginatingTID, TagDestinationTID, TagPAbortCause:
+		case TagDialoguePortion:
+			o.checkLengthAll(pb, poff, portion)
+		case TagComponentPortion:
+			o.forEachChild(pb, poff, portion, func(cb []byte, coff int, comp *IE) {
+				if !isComponentType(comp.Tag) {
+					o.anomalyHook(Anomaly{
+						Kind: AnomalyUnknownTag, Offset: coff, Tag: comp.Tag,
+						Detail: fmt.Sprintf("tag 0x%02x in component portion", uint8(comp.Tag)),
+					})
+					return
+				}
+				o.forEachChild(cb, coff, comp, func(xb []byte, xoff int, x *IE) {
+					// the result sequence is defined by TCAP, while its
+					// Parameter is not.
+					if x.Tag == TagResultSequence && (comp.Tag == TagReturnResultLast || comp.Tag == TagReturnResultNotLast) {
+						o.forEachChild(xb, xoff, x, func([]byte, int, *IE) {})
+					}
+				})
+			})
+		default:
+			o.anomalyHook(Anomaly{
+				Kind: AnomalyUnknownTag, Offset: poff, Tag: portion.Tag,
+				Detail: fmt.Sprintf("tag 0x%02x in transaction portion", uint8(portion.Tag)),
+			})
+		}
+	})
+}
+
+// forEachChild checks the Length of each child of i parsed from b, which
+// starts at offset, and calls fn with its bytes and offset.
+func (o *parseOptions) forEachChild(b []byte, offset int, i *IE, fn func(b []byte, offset int, child *IE)) {
+	hdr := 1 + lengthOctets(b)
+	n := hdr
+	for _, c := range i.IE {
+		o.checkLength(b[n:], offset+n, c)
+		fn(b[n:], offset+n, c)
+		n += encodedLen(b[n:], c)
+	}
+}
+
+// checkLengthAll checks the Length of all the descendants of i.
+func (o *parseOptions) checkLengthAll(b []byte, offset int, i *IE) {
+	o.forEachChild(b, offset, i, o.checkLengthAll)
+}
+
+// checkLength reports AnomalyNonMinimalLength if the Length of i at the
+// beginning of b is not in the minimal form.
+func (o *parseOptions) checkLength(b []byte, offset int, i *IE) {
+	if b[1]&0x80 == 0 {
+		return
+	}
+	n := int(b[1] & 0x7f)
+	if l := len(i.Value); l < 0x80 || (n > 1 && b[2] == 0) {
+		o.anomalyHook(Anomaly{
+			Kind: AnomalyNonMinimalLength, Offset: offset, Tag: i.Tag,
+			Detail: fmt.Sprintf("Length %d in %d octets", l, 1+n),
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestAnomalyHook(t *testing.T) {
+	msg := []byte{
+		// Transaction Portion with non-minimal Length
+		0x62, 0x81, 0x14,
+		0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion with non-minimal Length
+		0x6c, 0x81, 0x0b,
+		// Invoke
+		0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x38,
+		// unknown element
+		0x85, 0x01, 0x00,
+	}
+
+	var got []tcap.Anomaly
+	hook := tcap.WithAnomalyHook(func(a tcap.Anomaly) {
+		got = append(got, a)
+	})
+
+	if _, err := tcap.ParseBER(append(msg, 0x00), hook); err != nil {
+		t.Fatal(err)
+	}
+	want := []tcap.Anomaly{
+		{Kind: tcap.AnomalyNonMinimalLength, Offset: 0, Tag: 0x62, Detail: "Length 20 in 2 octets"},
+		{Kind: tcap.AnomalyNonMinimalLength, Offset: 9, Tag: 0x6c, Detail: "Length 11 in 2 octets"},
+		{Kind: tcap.AnomalyUnknownTag, Offset: 20, Tag: 0x85, Detail: "tag 0x85 in component portion"},
+		{Kind: tcap.AnomalyTrailingBytes, Offset: 23, Detail: "1 octets after messages"},
+	}
+	verify.Values(t, "anomalies", got, want)
+
+	t.Run("strict with zero padding", func(t *testing.T) {
+		got = nil
+		if _, err := tcap.ParseBERStrict(append(msg, 0x00, 0x00), hook, tcap.WithZeroPadding()); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(got); n != 4 || got[3].Kind != tcap.AnomalyTrailingBytes || got[3].Offset != 23 {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("no anomalies", func(t *testing.T) {
+		got = nil
+		b := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationCancellationContext, 3).
+			Invoke(0, 3, []byte{0x30, 0x03, 0x04, 0x01, 0x01}).Bytes()
+		if _, err := tcap.ParseBER(b, hook); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("got %v want none", got)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+// Arena is a reusable storage for the structures that ParseBERInto produces,
+// which eliminates the allocations per message in a decode loop.
+//
+// The storage grows as needed while decoding the first messages, and is reused
+// after Reset without allocation once it is large enough. The TCAPs parsed with
+// an Arena are valid until the next Reset, and the Value of IEs refers to the
+// byte sequence given to ParseBERInto as ParseBER does.
+//
+// An Arena must not be used by multiple goroutines at the same time. The zero
+// value is ready to use.
+type Arena struct {
+	ies      []IE
+	nIEs     int
+	ptrs     []*IE
+	nPtrs    int
+	comps    []Component
+	nComps   int
+	cptrs    []*Component
+	nCptrs   int
+	messages []arenaMessage
+	nMsgs    int
+
+	// stack holds the IEs being parsed until they are put in ptrs.
+	stack []*IE
+	opts  parseOptions
+}
+
+// arenaMessage is the set of structures for a message, which is allocated at
+// once.
+type arenaMessage struct {
+	tcap        TCAP
+	transaction Transaction
+	dialogue    Dialogue
+	pdu         DialoguePDU
+	components  Components
+}
+
+// arenaMinLen is the number of elements allocated at least when Arena grows.
+const arenaMinLen = 16
+
+// Reset makes the storage available for reuse. The TCAPs parsed before must not
+// be used after Reset.
+func (a *Arena) Reset() {
+	a.nIEs, a.nPtrs, a.nComps, a.nCptrs, a.nMsgs = 0, 0, 0, 0, 0
+	a.stack = a.stack[:0]
+}
+
+// The allocators below fall back on the heap when Arena is nil, so that the
+// same parser code works with and without Arena.
+//
+// When the storage is exhausted, a new one twice as large is allocated, while
+// the old one is left to the structures already handed out. After Reset, only
+// the latest, largest one is reused.
+
+func (a *Arena) newIE() *IE {
+	if a == nil {
+		return &IE{}
+	}
+	if a.nIEs == len(a.ies) {
+		a.ies = make([]IE, growLen(len(a.ies), 1))
+		a.nIEs = 0
+	}
+	i := &a.ies[a.nIEs]
+	*i = IE{}
+	a.nIEs++
+	return i
+}
+
+func (a *Arena) newIEs(n int) []*IE {
+	if n == 0 {
+		return nil
+	}
+	if a.nPtrs+n > len(a.ptrs) {
+		a.ptrs = make([]*IE, growLen(len(a.ptrs), n))
+		a.nPtrs = 0
+	}
+	ies := a.ptrs[a.nPtrs : a.nPtrs+n : a.nPtrs+n]
+	a.nPtrs += n
+	return ies
+}
+
+func (a *Arena) newComponent() *Component {
+	if a == nil {
+		return &Component{}
+	}
+	if a.nComps == len(a.comps) {
+		a.comps = make([]Component, growLen(len(a.comps), 1))
+		a.nComps = 0
+	}
+	c := &a.comps[a.nComps]
+	*c = Component{}
+	a.nComps++
+	return c
+}
+
+func (a *Arena) newComponents(n int) []*Component {
+	if a == nil || n == 0 {
+		return nil
+	}
+	if a.nCptrs+n > len(a.cptrs) {
+		a.cptrs = make([]*Component, growLen(len(a.cptrs), n))
+		a.nCptrs = 0
+	}
+	comps := a.cptrs[a.nCptrs : a.nCptrs : a.nCptrs+n]
+	a.nCptrs += n
+	return comps
+}
+
+func (a *Arena) newMessage() *arenaMessage {
+	if a == nil {
+		return &arenaMessage{}
+	}
+	if a.nMsgs == len(a.messages) {
+		a.messages = make([]arenaMessage, growLen(len(a.messages), 1))
+		a.nMsgs = 0
+	}
+	m := &a.messages[a.nMsgs]
+	*m = arenaMessage{}
+	a.nMsgs++
+	return m
+}
+
+// parseIE parses b as an IE recursively, in the same way as ParseIERecursive.
+func (a *Arena) parseIE(b []byte) (*IE, error) {
+	if a == nil {
+		return ParseIERecursive(b)
+	}
+	i := a.newIE()
+	if err := i.parseRecursive(b, a); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// parseIEs parses b as multiple IEs, in the same way as ParseAsBER.
+func (a *Arena) parseIEs(b []byte) ([]*IE, error) {
+	return a.parseList(b, true)
+}
+
+// parseTLVs parses b as multiple IEs without parsing their children.
+func (a *Arena) parseTLVs(b []byte) ([]*IE, error) {
+	return a.parseList(b, false)
+}
+
+func (a *Arena) parseList(b []byte, recursive bool) ([]*IE, error) {
+	if a == nil {
+		if recursive {
+			return ParseAsBER(b)
+		}
+		var ies []*IE
+		for len(b) >= 2 {
+			i := &IE{}
+			if err := i.parseTLV(b); err != nil {
+				return nil, err
+			}
+			ies = append(ies, i)
+			b = b[encodedLen(b, i):]
+		}
+		return ies, nil
+	}
+
+	base := len(a.stack)
+	for len(b) >= 2 {
+		i := a.newIE()
+		var err error
+		if recursive {
+			err = i.parseRecursive(b, a)
+		} else {
+			err = i.parseTLV(b)
+		}
+		if err != nil {
+			a.stack = a.stack[:base]
+			return nil, err
+		}
+		a.stack = append(a.stack, i)
+		b = b[encodedLen(b, i):]
+	}
+
+	ies := a.newIEs(len(a.stack) - base)
+	copy(ies, a.stack[base:])
+	a.stack = a.stack[:base]
+	return ies, nil
+}
+
+// growLen returns the length of the new storage that has at least n elements.
+func growLen(cur, n int) int {
+	l := 2 * cur
+	if l < arenaMinLen {
+		l = arenaMinLen
+	}
+	if l < n {
+		l = n
+	}
+	return l
+}
+
+// ParseBERInto parses given byte sequence as a single TCAP in the same way as
+// ParseBERStrict, with the structures taken from the Arena given as a.
+//
+// Unlike ParseBERStrict, it takes no ParseOption, to avoid the allocations.
+// If a is nil, it is the same as ParseBERStrict without ParseOption.
+func ParseBERInto(b []byte, a *Arena) (*TCAP, error) {
+	if a == nil {
+		return ParseBERStrict(b)
+	}
+	a.opts = parseOptions{maxIEs: DefaultMaxIEs, maxMessageSize: DefaultMaxMessageSize}
+	return parseBERStrict(b, &a.opts, a)
+}
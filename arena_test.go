@@ -0,0 +1,88 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+var arenaMessages = [][]byte{
+	tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		Invoke(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Bytes(),
+	tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+		Invoke(1, 45, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).
+		ReturnResult(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Bytes(),
+	tcaptest.End().DTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		ReturnError(1, 1, nil).Bytes(),
+	tcaptest.Abort().DTID(0x11111111).PAbortCause(tcap.ResourceLimitation).Bytes(),
+}
+
+func TestParseBERInto(t *testing.T) {
+	a := &tcap.Arena{}
+	for i := 0; i < 3; i++ {
+		a.Reset()
+		for _, b := range arenaMessages {
+			got, err := tcap.ParseBERInto(b, a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := tcap.ParseBERStrict(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verify.Values(t, "", got, want) {
+				return
+			}
+		}
+	}
+
+	if _, err := tcap.ParseBERInto(arenaMessages[0][:10], a); err == nil {
+		t.Errorf("got no error for truncated message")
+	}
+}
+
+func TestParseBERIntoAllocs(t *testing.T) {
+	a := &tcap.Arena{}
+	parse := func() {
+		a.Reset()
+		for _, b := range arenaMessages {
+			if _, err := tcap.ParseBERInto(b, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// warm up the Arena.
+	parse()
+
+	if n := testing.AllocsPerRun(100, parse); n != 0 {
+		t.Errorf("got %v allocations per run want 0", n)
+	}
+}
+
+func BenchmarkParseBERInto(b *testing.B) {
+	msg := arenaMessages[1]
+	a := &tcap.Arena{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		a.Reset()
+		if _, err := tcap.ParseBERInto(msg, a); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseBERStrict(b *testing.B) {
+	msg := arenaMessages[1]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tcap.ParseBERStrict(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+// Outcome definitions of the dialogues classified by DialogueClassifier.
+const (
+	OutcomeOpen int = iota
+	OutcomeComplete
+	OutcomeAborted
+)
+
+// ClassifiedDialogue is a dialogue correlated from the TCAPs that share the
+// Transaction IDs, with its lifecycle outcome.
+type ClassifiedDialogue struct {
+	// TIDs are the Transaction IDs of the both ends seen so far. The first one
+	// is the OTID of Begin if HasBegin is true.
+	TIDs []uint32
+	// HasBegin is whether Begin of the dialogue is seen.
+	HasBegin bool
+	// Outcome is either OutcomeOpen, OutcomeComplete or OutcomeAborted.
+	Outcome int
+	// Messages is the number of the TCAPs of the dialogue.
+	Messages int
+}
+
+// OutcomeString returns the Outcome in string.
+func (d *ClassifiedDialogue) OutcomeString() string {
+	switch d.Outcome {
+	case OutcomeOpen:
+		return "open"
+	case OutcomeComplete:
+		return "complete"
+	case OutcomeAborted:
+		return "aborted"
+	}
+	return ""
+}
+
+// DialogueClassifier correlates the TCAPs into dialogues by OTID and DTID, and
+// classifies them into complete (ended by End), aborted (ended by Abort), or
+// still open (Begin or Continue only).
+//
+// The TCAPs can be given out of order, e.g., End before Continue. A dialogue
+// ended once is not reopened by the TCAPs that arrive late, and is not changed
+// by another End or Abort. Unidirectional is ignored as it has no TIDs.
+//
+// DialogueClassifier is not safe for concurrent use.
+type DialogueClassifier struct {
+	dialogues map[uint32]*ClassifiedDialogue
+}
+
+// NewDialogueClassifier creates a new DialogueClassifier.
+func NewDialogueClassifier() *DialogueClassifier {
+	return &DialogueClassifier{
+		dialogues: map[uint32]*ClassifiedDialogue{},
+	}
+}
+
+// Add classifies the TCAP given as t, and returns the dialogue it belongs to,
+// or nil if it has no TIDs.
+func (c *DialogueClassifier) Add(t *TCAP) *ClassifiedDialogue {
+	var d *ClassifiedDialogue
+	switch t.MessageType() {
+	case Begin:
+		otid := t.OTID()
+		d = c.lookup(otid)
+		if !d.HasBegin {
+			d.HasBegin = true
+			d.moveToFront(otid)
+		}
+	case Continue:
+		// the DTID is the peer, which is likely the initiator if both are
+		// new, i.e., the first Continue from the responder.
+		d = c.link(t.DTID(), t.OTID())
+	case End:
+		d = c.lookup(t.DTID())
+		d.end(OutcomeComplete)
+	case Abort:
+		d = c.lookup(t.DTID())
+		d.end(OutcomeAborted)
+	default:
+		return nil
+	}
+
+	d.Messages++
+	return d
+}
+
+// Dialogue returns the dialogue that has the TID given, or nil.
+func (c *DialogueClassifier) Dialogue(tid uint32) *ClassifiedDialogue {
+	return c.dialogues[tid]
+}
+
+// Dialogues returns all the dialogues classified so far, in no particular
+// order.
+func (c *DialogueClassifier) Dialogues() []*ClassifiedDialogue {
+	seen := map[*ClassifiedDialogue]bool{}
+	var ds []*ClassifiedDialogue
+	for _, d := range c.dialogues {
+		if !seen[d] {
+			seen[d] = true
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// lookup returns the dialogue that has the TID given, creating it if not found.
+func (c *DialogueClassifier) lookup(tid uint32) *ClassifiedDialogue {
+	if d, ok := c.dialogues[tid]; ok {
+		return d
+	}
+	d := &ClassifiedDialogue{TIDs: []uint32{tid}}
+	c.dialogues[tid] = d
+	return d
+}
+
+// link returns the dialogue that has both TIDs given, merging the ones found
+// by each TID if they are different.
+func (c *DialogueClassifier) link(peer, own uint32) *ClassifiedDialogue {
+	dp, okp := c.dialogues[peer]
+	do, oko := c.dialogues[own]
+	switch {
+	case !okp && !oko:
+		d := &ClassifiedDialogue{TIDs: []uint32{peer, own}}
+		c.dialogues[peer], c.dialogues[own] = d, d
+		return d
+	case !oko:
+		dp.TIDs = append(dp.TIDs, own)
+		c.dialogues[own] = dp
+		return dp
+	case !okp:
+		do.TIDs = append(do.TIDs, peer)
+		c.dialogues[peer] = do
+		return do
+	case dp == do:
+		return dp
+	}
+
+	// both are seen separately, e.g., Begin and End before Continue.
+	into, from := dp, do
+	if from.HasBegin {
+		into, from = from, into
+	}
+	into.TIDs = append(into.TIDs, from.TIDs...)
+	into.Messages += from.Messages
+	into.end(from.Outcome)
+	for _, tid := range from.TIDs {
+		c.dialogues[tid] = into
+	}
+	return into
+}
+
+// end sets the Outcome if the dialogue is not ended yet.
+func (d *ClassifiedDialogue) end(outcome int) {
+	if d.Outcome == OutcomeOpen {
+		d.Outcome = outcome
+	}
+}
+
+// moveToFront moves the TID given to the first of TIDs.
+func (d *ClassifiedDialogue) moveToFront(tid uint32) {
+	for i, x := range d.TIDs {
+		if x == tid {
+			copy(d.TIDs[1:i+1], d.TIDs[:i])
+			d.TIDs[0] = tid
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestDialogueClassifier(t *testing.T) {
+	cases := []struct {
+		description string
+		msgs        []*tcap.TCAP
+		want        *tcap.ClassifiedDialogue
+	}{
+		{
+			"complete",
+			[]*tcap.TCAP{
+				tcaptest.Begin().OTID(0x11).Message(),
+				tcaptest.Continue().OTID(0x22).DTID(0x11).Message(),
+				tcaptest.End().DTID(0x22).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11, 0x22}, HasBegin: true, Outcome: tcap.OutcomeComplete, Messages: 3},
+		},
+		{
+			"open",
+			[]*tcap.TCAP{
+				tcaptest.Begin().OTID(0x11).Message(),
+				tcaptest.Continue().OTID(0x22).DTID(0x11).Message(),
+				tcaptest.Continue().OTID(0x11).DTID(0x22).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11, 0x22}, HasBegin: true, Outcome: tcap.OutcomeOpen, Messages: 3},
+		},
+		{
+			"aborted",
+			[]*tcap.TCAP{
+				tcaptest.Begin().OTID(0x11).Message(),
+				tcaptest.Abort().DTID(0x11).PAbortCause(tcap.ResourceLimitation).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11}, HasBegin: true, Outcome: tcap.OutcomeAborted, Messages: 2},
+		},
+		{
+			"out of order",
+			[]*tcap.TCAP{
+				tcaptest.End().DTID(0x22).Message(),
+				tcaptest.Begin().OTID(0x11).Message(),
+				tcaptest.Continue().OTID(0x22).DTID(0x11).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11, 0x22}, HasBegin: true, Outcome: tcap.OutcomeComplete, Messages: 3},
+		},
+		{
+			"Continue before Begin",
+			[]*tcap.TCAP{
+				tcaptest.Continue().OTID(0x22).DTID(0x11).Message(),
+				tcaptest.Begin().OTID(0x11).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11, 0x22}, HasBegin: true, Outcome: tcap.OutcomeOpen, Messages: 2},
+		},
+		{
+			"late Continue after End",
+			[]*tcap.TCAP{
+				tcaptest.Begin().OTID(0x11).Message(),
+				tcaptest.End().DTID(0x11).Message(),
+				tcaptest.Continue().OTID(0x22).DTID(0x11).Message(),
+				tcaptest.Abort().DTID(0x22).Message(),
+			},
+			&tcap.ClassifiedDialogue{TIDs: []uint32{0x11, 0x22}, HasBegin: true, Outcome: tcap.OutcomeComplete, Messages: 4},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			cl := tcap.NewDialogueClassifier()
+			for _, m := range c.msgs {
+				cl.Add(m)
+			}
+
+			ds := cl.Dialogues()
+			if len(ds) != 1 {
+				t.Fatalf("got %d dialogues want 1", len(ds))
+			}
+			verify.Values(t, "dialogue", ds[0], c.want)
+			for _, tid := range c.want.TIDs {
+				if cl.Dialogue(tid) != ds[0] {
+					t.Errorf("got another dialogue for TID %#x", tid)
+				}
+			}
+		})
+	}
+
+	cl := tcap.NewDialogueClassifier()
+	if d := cl.Add(tcap.NewUnidirectionalInvoke(1, 46, nil)); d != nil {
+		t.Errorf("got %v want nil for Unidirectional", d)
+	}
+}
@@ -6,10 +6,11 @@ package tcap_test
 
 import (
 	"encoding"
+	"errors"
 	"testing"
 
-	"github.com/pascaldekloe/goe/verify"
 	"github.com/hdddl/go-tcap"
+	"github.com/pascaldekloe/goe/verify"
 )
 
 type serializable interface {
@@ -34,7 +35,7 @@ var testcases = []struct {
 			3,                                // ACN Version
 			0,                                // Invoke Id
 			3,                                // OpCode
-			[]byte{0x04, 0x08, 0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87, 0xf9}, // Payload
+			[]byte{0x30, 0x0a, 0x04, 0x08, 0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87, 0xf9}, // Payload
 		),
 		serialized: []byte{
 			// Transaction Portion
@@ -73,10 +74,10 @@ var testcases = []struct {
 		),
 		serialized: []byte{
 			// Transaction Portion
-			0x64, 0x3e, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x6b, 0x2a, 0x28, 0x28, 0x06, 0x07, 0x00, 0x11,
+			0x64, 0x3a, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x6b, 0x26, 0x28, 0x24, 0x06, 0x07, 0x00, 0x11,
 			0x86, 0x05, 0x01, 0x01, 0x01,
 			// Dialogue Portion
-			0xa0, 0x1d, 0x61, 0x1b, 0x80, 0x02, 0x07, 0x80, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01,
+			0xa0, 0x19, 0x61, 0x17, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01,
 			0x00, 0x02, 0x03, 0xa2, 0x03, 0x02, 0x01, 0x00, 0xa3, 0x05, 0xa1, 0x03, 0x02, 0x01, 0x00,
 			// Component Portion
 			0x6c, 0x0a, 0xa2, 0x08, 0x02, 0x01, 0x00, 0x30, 0x03, 0x02, 0x01, 0x03,
@@ -103,7 +104,7 @@ var testcases = []struct {
 			3,                              // ACN Version
 			0,                              // Invoke Id
 			71,                             // OpCode
-			[]byte{0xde, 0xad, 0xbe, 0xef}, // Payload
+			[]byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}, // Payload
 		),
 		serialized: []byte{
 			// Transaction Portion
@@ -135,7 +136,7 @@ var testcases = []struct {
 			1,          // Invoke Id
 			61,         // OpCode
 			[]byte{
-				0x04, 0x01, 0x0f, 0x04, 0x09, 0xaa, 0x1b, 0x2e, 0x47, 0xab, 0xd9, 0x46, 0xaa, 0x11, 0x80, 0x07,
+				0x30, 0x17, 0x04, 0x01, 0x0f, 0x04, 0x09, 0xaa, 0x1b, 0x2e, 0x47, 0xab, 0xd9, 0x46, 0xaa, 0x11, 0x80, 0x07,
 				0x91, 0x18, 0x08, 0x11, 0x11, 0x22, 0x22,
 			}, // Payload
 		),
@@ -165,7 +166,7 @@ var testcases = []struct {
 			1,          // Invoke Id
 			61,         // OpCode
 			[]byte{
-				0x04, 0x01, 0x0f, 0x04, 0x09, 0xaa, 0x1b, 0x2e, 0x47, 0xab, 0xd9, 0x46, 0xaa, 0x11, 0x80, 0x07,
+				0x30, 0x17, 0x04, 0x01, 0x0f, 0x04, 0x09, 0xaa, 0x1b, 0x2e, 0x47, 0xab, 0xd9, 0x46, 0xaa, 0x11, 0x80, 0x07,
 				0x91, 0x18, 0x08, 0x11, 0x11, 0x22, 0x22,
 			}, // Payload
 		),
@@ -190,6 +191,72 @@ var testcases = []struct {
 			return v1, nil
 		},
 	}, {
+		description: "TCAP/Unidirectional - AUDT - Invoke",
+		structured: tcap.NewUnidirectionalInvokeWithDialogue(
+			tcap.UnidialogueAsID,                 // DialogueType
+			tcap.ShortMsgRelayContext,            // ACN
+			3,                                    // ACN Version
+			1,                                    // Invoke Id
+			46,                                   // OpCode
+			[]byte{0x30, 0x03, 0x80, 0x01, 0x00}, // Payload
+		),
+		serialized: []byte{
+			// Transaction Portion
+			0x61, 0x2f,
+			// Dialogue Portion
+			0x6b, 0x1e, 0x28, 0x1c, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x02, 0x01, 0xa0, 0x11, 0x60,
+			0x0f, 0x80, 0x02, 0x07, 0x80, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x15, 0x03,
+			// Component Portion
+			0x6c, 0x0d, 0xa1, 0x0b, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2e, 0x30, 0x03, 0x80, 0x01, 0x00,
+		},
+		parseFunc: func(b []byte) (serializable, error) {
+			v, err := tcap.Parse(b)
+			if err != nil {
+				return nil, err
+			}
+			// clear unnecessary payload
+			v.Transaction.Payload = nil
+			v.Dialogue.SingleAsn1Type.Value = nil
+			v.Dialogue.Payload = nil
+
+			return v, nil
+		},
+	}, {
+		description: "ParseBER / TCAP/Unidirectional - AUDT - Invoke",
+		structured: tcap.NewUnidirectionalInvokeWithDialogue(
+			tcap.UnidialogueAsID,                 // DialogueType
+			tcap.ShortMsgRelayContext,            // ACN
+			3,                                    // ACN Version
+			1,                                    // Invoke Id
+			46,                                   // OpCode
+			[]byte{0x30, 0x03, 0x80, 0x01, 0x00}, // Payload
+		),
+		serialized: []byte{
+			// Transaction Portion
+			0x61, 0x2f,
+			// Dialogue Portion
+			0x6b, 0x1e, 0x28, 0x1c, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x02, 0x01, 0xa0, 0x11, 0x60,
+			0x0f, 0x80, 0x02, 0x07, 0x80, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x15, 0x03,
+			// Component Portion
+			0x6c, 0x0d, 0xa1, 0x0b, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2e, 0x30, 0x03, 0x80, 0x01, 0x00,
+		},
+		parseFunc: func(b []byte) (serializable, error) {
+			v, err := tcap.ParseBER(b)
+			if err != nil {
+				return nil, err
+			}
+			v1 := v[0]
+
+			// clear unnecessary payload
+			v1.Transaction.Payload = nil
+			v1.Dialogue.SingleAsn1Type.Value = nil
+			v1.Dialogue.SingleAsn1Type.IE = nil
+			v1.Dialogue.Payload = nil
+			v1.Dialogue.DialoguePDU.ApplicationContextName.IE = nil
+
+			return v1, nil
+		},
+	}, {
 		description: "TCAP/End - AARE - ReturnResultLast",
 		structured: tcap.NewEndReturnResultWithDialogue(
 			0x11111111,                     // OTID
@@ -199,14 +266,14 @@ var testcases = []struct {
 			1,                              // Invoke Id
 			71,                             // OpCode
 			true,                           // Last or not
-			[]byte{0xde, 0xad, 0xbe, 0xef}, // Payload
+			[]byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}, // Payload
 		),
 		serialized: []byte{
 			// Transaction Portion
-			0x64, 0x44, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+			0x64, 0x40, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
 			// Dialogue Portion
-			0x6b, 0x2a, 0x28, 0x28, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x1d, 0x61,
-			0x1b, 0x80, 0x02, 0x07, 0x80, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x1d, 0x03,
+			0x6b, 0x26, 0x28, 0x24, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x19, 0x61,
+			0x17, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x1d, 0x03,
 			0xa2, 0x03, 0x02, 0x01, 0x00, 0xa3, 0x05, 0xa1,
 			0x03, 0x02, 0x01, 0x00,
 			// Component Portion
@@ -225,6 +292,51 @@ var testcases = []struct {
 			v.Components.Component[0].ResultRetres.Value = nil
 			v.Components.Component[0].Parameter.IE = nil
 
+			return v, nil
+		},
+	}, {
+		description: "TCAP/Abort - ABRT / U-Abort with user information",
+		structured: func() *tcap.TCAP {
+			t := &tcap.TCAP{
+				Transaction: tcap.NewAbort(0x11111111, 0, nil),
+				Dialogue: tcap.NewDialogue(
+					tcap.DialogueAsID, 1, // OID, Version
+					tcap.NewABRT(
+						0, // AbortSource: dialogue-service-user
+						tcap.NewIE(
+							tcap.NewContextSpecificConstructorTag(30),
+							[]byte{
+								0x28, 0x0f, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01, 0xa0, 0x04, 0xa4, 0x02, 0x80,
+								0x00,
+							},
+						),
+					),
+					nil,
+				),
+			}
+			t.Transaction.PAbortCause = nil
+			t.SetLength()
+
+			return t
+		}(),
+		serialized: []byte{
+			// Transaction Portion
+			0x67, 0x2d, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+			// Dialogue Portion
+			0x6b, 0x25, 0x28, 0x23, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x18, 0x64,
+			0x16, 0x80, 0x01, 0x00, 0xbe, 0x11, 0x28, 0x0f, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01,
+			0x01, 0xa0, 0x04, 0xa4, 0x02, 0x80, 0x00,
+		},
+		parseFunc: func(b []byte) (serializable, error) {
+			v, err := tcap.Parse(b)
+			if err != nil {
+				return nil, err
+			}
+			// clear unnecessary payload
+			v.Transaction.Payload = nil
+			v.Dialogue.SingleAsn1Type.Value = nil
+			v.Dialogue.Payload = nil
+
 			return v, nil
 		},
 	},
@@ -300,8 +412,8 @@ var testcases = []struct {
 			[]byte{0xde, 0xad, 0xbe, 0xef},
 		),
 		serialized: []byte{
-			0x6b, 0x2e, 0x28, 0x2c, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x1d, 0x61,
-			0x1b, 0x80, 0x02, 0x07, 0x80, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x1d, 0x03,
+			0x6b, 0x2a, 0x28, 0x28, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x19, 0x61,
+			0x17, 0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x1d, 0x03,
 			0xa2, 0x03, 0x02, 0x01, 0x00, 0xa3, 0x05, 0xa1, 0x03, 0x02, 0x01, 0x00, 0xde, 0xad, 0xbe, 0xef,
 		},
 		parseFunc: func(b []byte) (serializable, error) {
@@ -318,7 +430,7 @@ var testcases = []struct {
 	// Component Portion
 	{
 		description: "Components/invoke",
-		structured:  tcap.NewComponents(tcap.NewInvoke(0, 0, 71, true, []byte{0xde, 0xad, 0xbe, 0xef})),
+		structured:  tcap.NewComponents(tcap.NewInvoke(0, 0, 71, true, []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef})),
 		serialized: []byte{
 			0x6c, 0x0e, 0xa1, 0x0c, 0x02, 0x01, 0x00, 0x02, 0x01, 0x47, 0x30, 0x04, 0xde, 0xad, 0xbe, 0xef,
 		},
@@ -334,7 +446,7 @@ var testcases = []struct {
 		},
 	}, {
 		description: "Components/returnResultLast",
-		structured:  tcap.NewComponents(tcap.NewReturnResult(0, 71, true, true, []byte{0xde, 0xad, 0xbe, 0xef})),
+		structured:  tcap.NewComponents(tcap.NewReturnResult(0, 71, true, true, []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef})),
 		serialized: []byte{
 			0x6c, 0x10, 0xa2, 0x0e, 0x02, 0x01, 0x00, 0x30, 0x09, 0x02, 0x01, 0x47, 0x30, 0x04, 0xde, 0xad,
 			0xbe, 0xef,
@@ -350,6 +462,29 @@ var testcases = []struct {
 
 			return v, nil
 		},
+	}, {
+		description: "Components/returnResultLast without OpCode",
+		structured:  tcap.NewComponents(tcap.NewReturnResultWithoutOpCode(0, true, []byte{0x04, 0x04, 0xde, 0xad, 0xbe, 0xef})),
+		serialized: []byte{
+			0x6c, 0x0d, 0xa2, 0x0b, 0x02, 0x01, 0x00, 0x30, 0x06, 0x04, 0x04, 0xde, 0xad, 0xbe, 0xef,
+		},
+		parseFunc: func(b []byte) (serializable, error) {
+			v, err := tcap.ParseComponents(b)
+			if err != nil {
+				return nil, err
+			}
+			// clear unnecessary payload
+			v.Component[0].ResultRetres.Value = nil
+
+			return v, nil
+		},
+	}, {
+		description: "Components/returnResultLast without result",
+		structured:  tcap.NewComponents(tcap.NewReturnResultWithoutOpCode(0, true, nil)),
+		serialized: []byte{
+			0x6c, 0x05, 0xa2, 0x03, 0x02, 0x01, 0x00,
+		},
+		parseFunc: func(b []byte) (serializable, error) { return tcap.ParseComponents(b) },
 	}, {
 		description: "Components/returnError",
 		structured:  tcap.NewComponents(tcap.NewReturnError(0, 71, true, []byte{0xde, 0xad, 0xbe, 0xef})),
@@ -409,3 +544,74 @@ func TestCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBERUAbort(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x67, 0x2d, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Dialogue Portion
+		0x6b, 0x25, 0x28, 0x23, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x18, 0x64,
+		0x16, 0x80, 0x01, 0x00, 0xbe, 0x11, 0x28, 0x0f, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01,
+		0x01, 0xa0, 0x04, 0xa4, 0x02, 0x80, 0x00,
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(parsed), 1; got != want {
+		t.Fatalf("got %v messages want %v", got, want)
+	}
+
+	v := parsed[0]
+	if got, want := v.Transaction.MessageTypeString(), "Abort"; got != want {
+		t.Errorf("MessageType: got %v want %v", got, want)
+	}
+	if got, want := v.DTID(), uint32(0x11111111); got != want {
+		t.Errorf("DTID: got %#x want %#x", got, want)
+	}
+	if v.Transaction.PAbortCause != nil {
+		t.Errorf("PAbortCause: got %v want nil", v.Transaction.PAbortCause)
+	}
+	if v.Components != nil {
+		t.Errorf("Components: got %v want nil", v.Components)
+	}
+	if v.Dialogue == nil || v.Dialogue.DialoguePDU == nil {
+		t.Fatal("Dialogue: got nil")
+	}
+
+	pdu := v.Dialogue.DialoguePDU
+	if got, want := pdu.DialogueType(), "ABRT"; got != want {
+		t.Errorf("DialogueType: got %v want %v", got, want)
+	}
+	if pdu.AbortSource == nil || pdu.ProtocolVersion != nil {
+		t.Fatalf("AbortSource: got %v, ProtocolVersion: got %v", pdu.AbortSource, pdu.ProtocolVersion)
+	}
+	if got, want := pdu.AbortSource.Value, []byte{0x00}; !verify.Values(t, "AbortSource", got, want) {
+		t.Fail()
+	}
+	if pdu.UserInformation == nil {
+		t.Fatal("UserInformation: got nil")
+	}
+	if got, want := pdu.UserInformation.Value, b[30:]; !verify.Values(t, "UserInformation", got, want) {
+		t.Fail()
+	}
+}
+
+func TestParseAbortWithBothReasons(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x67, 0x2a, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, 0x04,
+		// Dialogue Portion
+		0x6b, 0x1f, 0x28, 0x1d, 0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01, 0xa0, 0x12, 0x64,
+		0x10, 0x80, 0x01, 0x00, 0xbe, 0x0b, 0x28, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01,
+		0x01,
+	}
+
+	if _, err := tcap.Parse(b); err != tcap.ErrAbortReasonConflict {
+		t.Errorf("Parse: got %v want %v", err, tcap.ErrAbortReasonConflict)
+	}
+	if _, err := tcap.ParseBER(b); !errors.Is(err, tcap.ErrAbortReasonConflict) {
+		t.Errorf("ParseBER: got %v want %v", err, tcap.ErrAbortReasonConflict)
+	}
+}
@@ -20,6 +20,39 @@ const (
 	ReturnResultNotLast
 )
 
+// Component Tag definitions.
+const (
+	TagInvoke              Tag = 0xa1
+	TagReturnResultLast    Tag = 0xa2
+	TagReturnError         Tag = 0xa3
+	TagReject              Tag = 0xa4
+	TagReturnResultNotLast Tag = 0xa7
+
+	// TagResultSequence is the SEQUENCE in ReturnResult that contains the
+	// Operation Code and the Parameter.
+	TagResultSequence Tag = 0x30
+)
+
+// tagLinkedID is the Tag of the Linked ID in Invoke, i.e., [0] IMPLICIT.
+const tagLinkedID Tag = 0x80
+
+// Operation Class definitions, which tell the outcomes of an Invoke reported
+// by the peer.
+const (
+	_ int = iota
+	// OperationClass1 reports both success and failure.
+	OperationClass1
+	// OperationClass2 reports failure only.
+	OperationClass2
+	// OperationClass3 reports success only.
+	OperationClass3
+	// OperationClass4 reports neither success nor failure.
+	OperationClass4
+)
+
+// maxInvokeID is the largest Invoke ID that fits in a single octet INTEGER.
+const maxInvokeID = 127
+
 // Problem Type definitions.
 const (
 	GeneralProblem int = iota
@@ -68,14 +101,17 @@ const (
 // This is a TCAP Components' Header part. Contents are in Component field.
 type Components struct {
 	Tag       Tag
-	Length    uint8
+	Length    int
 	Component []*Component
+	// Skipped is the number of Components left undecoded by WithMaxComponents,
+	// which are not in Component and thus dropped when marshaled.
+	Skipped int
 }
 
 // Component represents a TCAP Component.
 type Component struct {
 	Type          Tag
-	Length        uint8
+	Length        int
 	InvokeID      *IE
 	LinkedID      *IE
 	ResultRetres  *IE
@@ -84,6 +120,14 @@ type Component struct {
 	ErrorCode     *IE
 	ProblemCode   *IE
 	Parameter     *IE
+
+	// Class is the Operation Class of Invoke, which is not sent on the wire
+	// but used locally, e.g., by Dispatcher. Zero means OperationClass1.
+	Class int
+
+	// Raw is the pre-encoded Component, which is put verbatim by MarshalTo
+	// instead of the fields above if not nil. See NewRawComponent.
+	Raw []byte
 }
 
 // NewComponents creates a new Components.
@@ -111,7 +155,7 @@ func NewInvoke(invID, lkID, opCode int, isLocal bool, param []byte) *Component {
 
 	if lkID > 0 {
 		c.LinkedID = &IE{
-			Tag:    NewContextSpecificPrimitiveTag(0),
+			Tag:    tagLinkedID,
 			Length: 1,
 			Value:  []byte{uint8(lkID)},
 		}
@@ -127,6 +171,14 @@ func NewInvoke(invID, lkID, opCode int, isLocal bool, param []byte) *Component {
 	return c
 }
 
+// NewInvokeWithClass returns a new single Invoke Component of the Operation
+// Class given, e.g., OperationClass4 for the operations without any reply.
+func NewInvokeWithClass(invID, lkID, opCode int, isLocal bool, class int, param []byte) *Component {
+	c := NewInvoke(invID, lkID, opCode, isLocal, param)
+	c.Class = class
+	return c
+}
+
 // NewReturnResult returns a new single ReturnResultLast or ReturnResultNotLast Component.
 func NewReturnResult(invID, opCode int, isLocal, isLast bool, param []byte) *Component {
 	tag := ReturnResultNotLast
@@ -137,7 +189,7 @@ func NewReturnResult(invID, opCode int, isLocal, isLast bool, param []byte) *Com
 	c := &Component{
 		Type: NewContextSpecificConstructorTag(tag),
 		ResultRetres: &IE{
-			Tag: NewUniversalConstructorTag(0x10),
+			Tag: TagResultSequence,
 		},
 		InvokeID: &IE{
 			Tag:    NewUniversalPrimitiveTag(2),
@@ -157,6 +209,39 @@ func NewReturnResult(invID, opCode int, isLocal, isLast bool, param []byte) *Com
 	return c
 }
 
+// NewReturnResultWithoutOpCode returns a new single ReturnResultLast or
+// ReturnResultNotLast Component without Operation Code, which is implied by
+// the Invoke it answers.
+//
+// If param is nil, the result sequence is omitted entirely.
+func NewReturnResultWithoutOpCode(invID int, isLast bool, param []byte) *Component {
+	tag := ReturnResultNotLast
+	if isLast {
+		tag = ReturnResultLast
+	}
+
+	c := &Component{
+		Type: NewContextSpecificConstructorTag(tag),
+		InvokeID: &IE{
+			Tag:    NewUniversalPrimitiveTag(2),
+			Length: 1,
+			Value:  []byte{uint8(invID)},
+		},
+	}
+
+	if param != nil {
+		c.ResultRetres = &IE{
+			Tag: TagResultSequence,
+		}
+		if err := c.setParameterFromBytesWithTag(param); err != nil {
+			logf("failed to build Parameter: %v", err)
+		}
+	}
+
+	c.SetLength()
+	return c
+}
+
 // NewReturnError returns a new single ReturnError Component.
 func NewReturnError(invID, errCode int, isLocal bool, param []byte) *Component {
 	c := &Component{
@@ -180,15 +265,38 @@ func NewReturnError(invID, errCode int, isLocal bool, param []byte) *Component {
 	return c
 }
 
+// NewReturnErrorWithOID returns a new single ReturnError Component with the
+// global Error Code, i.e., the OID in dot notation given as errOID.
+func NewReturnErrorWithOID(invID int, errOID string, param []byte) (*Component, error) {
+	code, err := NewGlobalCode(errOID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewReturnError(invID, 0, true, param)
+	c.ErrorCode = code
+	c.SetLength()
+	return c, nil
+}
+
 // NewReject returns a new single Reject Component.
+//
+// problemType is the category of the problem, which is one of GeneralProblem,
+// InvokeProblem, ReturnResultProblem and ReturnErrorProblem, and is encoded as
+// the context-specific tag [0] to [3] of the problem code. problemCode is the
+// code defined for the category, e.g., ResultProblemMistypedParameter for
+// ReturnResultProblem and ErrorProblemUnrecognizedError for ReturnErrorProblem.
+//
+// invID of -1 makes the Invoke ID NULL, which is used if the Invoke ID cannot
+// be derived from the rejected Component.
 func NewReject(invID, problemType int, problemCode uint8, param []byte) *Component {
+	invokeID := NewIE(NewUniversalPrimitiveTag(2), []byte{uint8(invID)})
+	if invID == -1 {
+		invokeID = NewNull()
+	}
 	c := &Component{
-		Type: NewContextSpecificConstructorTag(Invoke),
-		InvokeID: &IE{
-			Tag:    NewUniversalPrimitiveTag(2),
-			Length: 1,
-			Value:  []byte{uint8(invID)},
-		},
+		Type:     NewContextSpecificConstructorTag(Reject),
+		InvokeID: invokeID,
 		ProblemCode: &IE{
 			Tag:    NewContextSpecificPrimitiveTag(problemType),
 			Length: 1,
@@ -206,6 +314,18 @@ func NewReject(invID, problemType int, problemCode uint8, param []byte) *Compone
 	return c
 }
 
+// NewRawComponent returns a new Component that has the pre-encoded byte
+// sequence given as b, e.g., a captured one, which is marshaled as it is
+// without being parsed. Type and Length are taken from b only for reference.
+func NewRawComponent(b []byte) *Component {
+	c := &Component{Raw: b}
+	if _, length, err := decodeLength(b); err == nil {
+		c.Type = Tag(b[0])
+		c.Length = length
+	}
+	return c
+}
+
 // NewOperationCode returns a Operation Code.
 func NewOperationCode(code int, isLocal bool) *IE {
 	var tag = 6
@@ -219,11 +339,83 @@ func NewOperationCode(code int, isLocal bool) *IE {
 	}
 }
 
+// isOperationCodeTag reports whether the tag is of local(INTEGER) or
+// global(OBJECT IDENTIFIER) Operation Code.
+func isOperationCodeTag(t uint8) bool {
+	return t == 0x02 || t == 0x06
+}
+
+// maxLocalCodeLen is the maximum length of the local (INTEGER) Operation Code
+// and Error Code, which are small integers in MAP.
+const maxLocalCodeLen = 2
+
+// verifyLocalCode checks the length of the Operation Code or Error Code given
+// as code in the Component of the type given as typ, if it is a local one.
+func verifyLocalCode(typ Tag, code *IE) error {
+	if code == nil || code.Tag != NewUniversalPrimitiveTag(2) {
+		return nil
+	}
+	if l := len(code.Value); l < 1 || l > maxLocalCodeLen {
+		return &InvalidLocalCodeError{Component: typ, Length: l}
+	}
+	return nil
+}
+
+// isComponentType reports whether the tag is one of the Component types.
+func isComponentType(tag Tag) bool {
+	switch tag {
+	case TagInvoke, TagReturnResultLast, TagReturnError, TagReject, TagReturnResultNotLast:
+		return true
+	}
+	return false
+}
+
 // NewErrorCode returns a Error Code.
 func NewErrorCode(code int, isLocal bool) *IE {
 	return NewOperationCode(code, isLocal)
 }
 
+// NewGlobalCode returns a global Operation Code or Error Code, i.e., the OBJECT
+// IDENTIFIER of the OID in dot notation given as oid.
+func NewGlobalCode(oid string) (*IE, error) {
+	b, err := encodeOID(oid)
+	if err != nil {
+		return nil, err
+	}
+	return NewIE(NewUniversalPrimitiveTag(6), b), nil
+}
+
+// Code Kind definitions, which tell the form of Operation Code and Error Code.
+const (
+	CodeKindNone int = iota
+	CodeKindLocal
+	CodeKindGlobal
+)
+
+// codeKind returns the form of Operation Code or Error Code given as code.
+func codeKind(code *IE) int {
+	if code == nil {
+		return CodeKindNone
+	}
+	if code.Tag == NewUniversalPrimitiveTag(6) {
+		return CodeKindGlobal
+	}
+	return CodeKindLocal
+}
+
+// codeOID returns the global Operation Code or Error Code given as code in dot
+// notation, or empty string if it is not global.
+func codeOID(code *IE) string {
+	if codeKind(code) != CodeKindGlobal {
+		return ""
+	}
+	oid, err := decodeOID(code.Value)
+	if err != nil {
+		return ""
+	}
+	return oid
+}
+
 // MarshalBinary returns the byte sequence generated from a Components instance.
 func (c *Components) MarshalBinary() ([]byte, error) {
 	b := make([]byte, c.MarshalLen())
@@ -236,9 +428,7 @@ func (c *Components) MarshalBinary() ([]byte, error) {
 // MarshalTo puts the byte sequence in the byte array given as b.
 func (c *Components) MarshalTo(b []byte) error {
 	b[0] = uint8(c.Tag)
-	b[1] = c.Length
-
-	cursor := 2
+	cursor := 1 + putLength(b[1:], c.Length, lengthLen(c.contentLen()))
 	for _, comp := range c.Component {
 		compLen := comp.MarshalLen()
 		if err := comp.MarshalTo(b[cursor : cursor+compLen]); err != nil {
@@ -260,10 +450,13 @@ func (c *Component) MarshalBinary() ([]byte, error) {
 
 // MarshalTo puts the byte sequence in the byte array given as b.
 func (c *Component) MarshalTo(b []byte) error {
-	b[0] = uint8(c.Type)
-	b[1] = c.Length
+	if c.Raw != nil {
+		copy(b, c.Raw)
+		return nil
+	}
 
-	var offset = 2
+	b[0] = uint8(c.Type)
+	var offset = 1 + putLength(b[1:], c.Length, lengthLen(c.contentLen()))
 	if field := c.InvokeID; field != nil {
 		if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
 			return err
@@ -294,10 +487,10 @@ func (c *Component) MarshalTo(b []byte) error {
 		}
 	case ReturnResultLast, ReturnResultNotLast:
 		if field := c.ResultRetres; field != nil {
-			if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
-				return err
-			}
-			offset += field.MarshalLen()
+			// the contents are given by OperationCode and Parameter, even if
+			// it has Value retrieved by parsing.
+			b[offset] = uint8(field.Tag)
+			offset += 1 + putLength(b[offset+1:], field.Length, lengthLen(c.resultLen()))
 		}
 
 		if field := c.OperationCode; field != nil {
@@ -350,25 +543,31 @@ func (c *Components) UnmarshalBinary(b []byte) error {
 		return io.ErrUnexpectedEOF
 	}
 
+	hdr, length, err := decodeLength(b)
+	if err != nil {
+		return err
+	}
 	c.Tag = Tag(b[0])
-	c.Length = b[1]
+	c.Length = length
 
-	var offset = 2
-	for {
-		if len(b) < 2 {
-			break
+	// advance by the octets each Component actually occupies, rather than
+	// by its serial length.
+	for offset := hdr; offset < len(b); {
+		n, length, err := decodeLength(b[offset:])
+		if err != nil {
+			return err
+		}
+		end := offset + n + length
+		if end > len(b) {
+			return io.ErrUnexpectedEOF
 		}
 
-		comp, err := ParseComponent(b[offset:])
+		comp, err := ParseComponent(b[offset:end])
 		if err != nil {
 			return err
 		}
 		c.Component = append(c.Component, comp)
-
-		if len(b[offset:]) == int(comp.Length)+2 {
-			break
-		}
-		b = b[offset+comp.MarshalLen()-2:]
+		offset = end
 	}
 	return nil
 }
@@ -387,31 +586,39 @@ func (c *Component) UnmarshalBinary(b []byte) error {
 	if len(b) < 2 {
 		return io.ErrUnexpectedEOF
 	}
+	offset, length, err := decodeLength(b)
+	if err != nil {
+		return err
+	}
 	c.Type = Tag(b[0])
-	c.Length = b[1]
+	c.Length = length
+	if l := offset + length; l <= len(b) {
+		b = b[:l]
+	}
 
-	var err error
-	var offset = 2
 	c.InvokeID, err = ParseIE(b[offset:])
 	if err != nil {
 		return err
 	}
-	offset += c.InvokeID.MarshalLen()
+	offset += encodedLen(b[offset:], c.InvokeID)
 
 	switch c.Type.Code() {
 	case Invoke:
-		/* TODO: Implement LinkedID Parser.
-		   c.LinkedID, err = ParseIE(b[offset:])
-		   if err != nil {
-		       return err
-		   }
-		   offset += c.LinkedID.MarshalLen()
-		*/
+		if offset < len(b) && Tag(b[offset]) == tagLinkedID {
+			c.LinkedID, err = ParseIE(b[offset:])
+			if err != nil {
+				return err
+			}
+			offset += encodedLen(b[offset:], c.LinkedID)
+		}
 		c.OperationCode, err = ParseIE(b[offset:])
 		if err != nil {
 			return err
 		}
-		offset += c.OperationCode.MarshalLen()
+		if err := verifyLocalCode(c.Type, c.OperationCode); err != nil {
+			return err
+		}
+		offset += encodedLen(b[offset:], c.OperationCode)
 
 		if offset >= len(b) {
 			return nil
@@ -421,18 +628,32 @@ func (c *Component) UnmarshalBinary(b []byte) error {
 			return err
 		}
 	case ReturnResultLast, ReturnResultNotLast:
-		c.ResultRetres, err = ParseIE(b[offset:])
-		if err != nil {
-			return err
+		// the result sequence is optional.
+		if offset >= len(b) {
+			return nil
+		}
+		// some implementations put the Operation Code and the Parameter
+		// without the result sequence, which are taken as they are.
+		if Tag(b[offset]) == TagResultSequence {
+			c.ResultRetres, err = ParseIE(b[offset:])
+			if err != nil {
+				return err
+			}
+			offset = 0
+			b = c.ResultRetres.Value
 		}
-		offset = 0
-		b = c.ResultRetres.Value[offset:]
 
-		c.OperationCode, err = ParseIE(b[offset:])
-		if err != nil {
-			return err
+		// Operation Code may be omitted as it is implied by the Invoke.
+		if len(b) != 0 && isOperationCodeTag(b[offset]) {
+			c.OperationCode, err = ParseIE(b[offset:])
+			if err != nil {
+				return err
+			}
+			if err := verifyLocalCode(c.Type, c.OperationCode); err != nil {
+				return err
+			}
+			offset += encodedLen(b[offset:], c.OperationCode)
 		}
-		offset += c.OperationCode.MarshalLen()
 
 		if offset >= len(b) {
 			return nil
@@ -446,7 +667,10 @@ func (c *Component) UnmarshalBinary(b []byte) error {
 		if err != nil {
 			return err
 		}
-		offset += c.ErrorCode.MarshalLen()
+		if err := verifyLocalCode(c.Type, c.ErrorCode); err != nil {
+			return err
+		}
+		offset += encodedLen(b[offset:], c.ErrorCode)
 
 		if offset >= len(b) {
 			return nil
@@ -494,13 +718,28 @@ func (c *Component) setParameterFromBytes(b []byte) error {
 }
 
 // the first byte should be tag
+//
+// The Tag is kept as it is, e.g., the context-specific one of the implicitly
+// tagged Parameter, and the contents of the primitive one are not parsed as IEs.
 func (c *Component) setParameterFromBytesWithTag(b []byte) error {
 	if b == nil || len(b) < 2 {
 		return io.ErrUnexpectedEOF
 	}
 
 	tag := Tag(b[0])
-	b = b[2:]
+	offset := 1 + lengthOctets(b)
+	if offset > len(b) {
+		return io.ErrUnexpectedEOF
+	}
+	b = b[offset:]
+
+	if tag.Form() != Constructor {
+		c.Parameter = &IE{
+			Tag:   tag,
+			Value: b,
+		}
+		return nil
+	}
 
 	ies, err := ParseMultiIEs(b)
 	if err != nil {
@@ -524,62 +763,92 @@ func (c *Component) setParameterFromBytesWithTag(b []byte) error {
 
 // SetValsFrom sets the values from IE parsed by ParseBER.
 func (c *Components) SetValsFrom(berParsed *IE) error {
+	return c.setValsFrom(berParsed, nil)
+}
+
+// setValsFrom is SetValsFrom with the Components taken from the Arena given as
+// a, which can be nil.
+func (c *Components) setValsFrom(berParsed *IE, a *Arena) error {
 	c.Tag = berParsed.Tag
 	c.Length = berParsed.Length
+	if c.Component == nil {
+		c.Component = a.newComponents(len(berParsed.IE))
+	}
 	for _, ie := range berParsed.IE {
-		comp := &Component{
-			Type:   ie.Tag,
-			Length: ie.Length,
-		}
+		comp := a.newComponent()
+		comp.Type = ie.Tag
+		comp.Length = ie.Length
 
 		switch ie.Tag {
-		case 0xa1: // Invoke
+		case TagInvoke:
+			// the Parameter is the element next to the Operation Code,
+			// which can be either a single element or a sequence/set.
 			for i, iex := range ie.IE {
-				switch iex.Tag {
-				case 0x02:
-					if i == 0 {
-						comp.InvokeID = iex
-					} else {
-						comp.OperationCode = iex
-					}
-				case 0x30:
+				switch {
+				case i == 0 && iex.Tag == 0x02:
+					comp.InvokeID = iex
+				case i == 1 && iex.Tag == tagLinkedID:
+					comp.LinkedID = iex
+				case comp.OperationCode == nil && isOperationCodeTag(uint8(iex.Tag)):
+					comp.OperationCode = iex
+				case comp.OperationCode != nil && comp.Parameter == nil:
 					comp.Parameter = iex
 				}
 			}
-		case 0xa2, 0xa7: // ReturnResult(Not)Last
+		case TagReturnResultLast, TagReturnResultNotLast:
 			for i, iex := range ie.IE {
-				switch iex.Tag {
-				case 0x02:
-					if i == 0 {
-						comp.InvokeID = iex
-					}
-				case 0x30:
+				switch {
+				case i == 0 && iex.Tag == 0x02:
+					comp.InvokeID = iex
+				case i == 1 && iex.Tag == TagResultSequence:
 					comp.ResultRetres = iex
-					for _, riex := range iex.IE {
-						switch riex.Tag {
-						case 0x02:
+					for j, riex := range iex.IE {
+						switch {
+						case j == 0 && isOperationCodeTag(uint8(riex.Tag)):
 							comp.OperationCode = riex
-						case 0x30:
+						case comp.Parameter == nil:
 							comp.Parameter = riex
 						}
 					}
+				case i == 1 && isOperationCodeTag(uint8(iex.Tag)):
+					// the result without the result sequence, which some
+					// implementations send.
+					comp.OperationCode = iex
+				case i != 0 && comp.ResultRetres == nil && comp.Parameter == nil:
+					comp.Parameter = iex
+				}
+			}
+		case TagReturnError:
+			for i, iex := range ie.IE {
+				switch {
+				case i == 0 && iex.Tag == 0x02:
+					comp.InvokeID = iex
+				case comp.ErrorCode == nil && isOperationCodeTag(uint8(iex.Tag)):
+					comp.ErrorCode = iex
+				case comp.ErrorCode != nil && comp.Parameter == nil:
+					comp.Parameter = iex
 				}
 			}
-		case 0xa3: // ReturnError
+		case TagReject:
 			for i, iex := range ie.IE {
 				switch iex.Tag {
-				case 0x02:
+				case 0x02, 0x05:
 					if i == 0 {
 						comp.InvokeID = iex
-					} else {
-						comp.ErrorCode = iex
 					}
-				case 0x30:
-					comp.Parameter = iex
+				case 0x80, 0x81, 0x82, 0x83:
+					comp.ProblemCode = iex
 				}
 			}
 		}
 
+		if err := verifyLocalCode(comp.Type, comp.OperationCode); err != nil {
+			return err
+		}
+		if err := verifyLocalCode(comp.Type, comp.ErrorCode); err != nil {
+			return err
+		}
+
 		c.Component = append(c.Component, comp)
 	}
 
@@ -588,7 +857,13 @@ func (c *Components) SetValsFrom(berParsed *IE) error {
 
 // MarshalLen returns the serial length of Components.
 func (c *Components) MarshalLen() int {
-	var l = 2
+	l := c.contentLen()
+	return 1 + lengthLen(l) + l
+}
+
+// contentLen returns the length of the contents of Components.
+func (c *Components) contentLen() int {
+	var l int
 	for _, comp := range c.Component {
 		l += comp.MarshalLen()
 	}
@@ -597,7 +872,33 @@ func (c *Components) MarshalLen() int {
 
 // MarshalLen returns the serial length of Component.
 func (c *Component) MarshalLen() int {
-	var l = 2 + c.InvokeID.MarshalLen()
+	if c.Raw != nil {
+		return len(c.Raw)
+	}
+
+	l := c.contentLen()
+	return 1 + lengthLen(l) + l
+}
+
+// resultLen returns the length of the contents of the result sequence, i.e.,
+// the Operation Code and the Parameter.
+func (c *Component) resultLen() int {
+	var l int
+	if field := c.OperationCode; field != nil {
+		l += field.MarshalLen()
+	}
+	if field := c.Parameter; field != nil {
+		l += field.MarshalLen()
+	}
+	return l
+}
+
+// contentLen returns the length of the contents of Component.
+func (c *Component) contentLen() int {
+	var l int
+	if field := c.InvokeID; field != nil {
+		l += field.MarshalLen()
+	}
 	switch c.Type.Code() {
 	case Invoke:
 		if field := c.LinkedID; field != nil {
@@ -610,15 +911,11 @@ func (c *Component) MarshalLen() int {
 			l += field.MarshalLen()
 		}
 	case ReturnResultLast, ReturnResultNotLast:
-		if field := c.ResultRetres; field != nil {
-			l += field.MarshalLen()
-		}
-		if field := c.OperationCode; field != nil {
-			l += field.MarshalLen()
-		}
-		if field := c.Parameter; field != nil {
-			l += field.MarshalLen()
+		r := c.resultLen()
+		if c.ResultRetres != nil {
+			r += 1 + lengthLen(r)
 		}
+		l += r
 	case ReturnError:
 		if field := c.ErrorCode; field != nil {
 			l += field.MarshalLen()
@@ -639,12 +936,40 @@ func (c *Components) SetLength() {
 	c.Length = 0
 	for _, comp := range c.Component {
 		comp.SetLength()
-		c.Length += uint8(comp.MarshalLen())
+		c.Length += comp.MarshalLen()
 	}
 }
 
+// CheckInvokeIDs returns *DuplicateInvokeIDError if any Invoke ID is used by
+// multiple Invokes, which the peer rejects with InvokeProblemDuplicateInvokeID.
+//
+// Only the Invokes are checked, as the other Components refer to the Invoke
+// IDs of the peer, which can be the same as the local ones.
+func (c *Components) CheckInvokeIDs() error {
+	seen := map[int]int{}
+	var dups []int
+	for _, comp := range c.Component {
+		if comp.Type.Code() != Invoke || comp.InvokeID == nil {
+			continue
+		}
+		id := decodeIntIE(comp.InvokeID)
+		if seen[id]++; seen[id] == 2 {
+			dups = append(dups, id)
+		}
+	}
+
+	if dups != nil {
+		return &DuplicateInvokeIDError{IDs: dups}
+	}
+	return nil
+}
+
 // SetLength sets the length in Length field.
 func (c *Component) SetLength() {
+	if c.Raw != nil {
+		return
+	}
+
 	l := 0
 	if field := c.InvokeID; field != nil {
 		field.SetLength()
@@ -673,9 +998,9 @@ func (c *Component) SetLength() {
 		l += c.SequenceTag.MarshalLen()
 	}
 	if field := c.ResultRetres; field != nil {
-		field.Length = uint8(l)
+		field.Length = l
 	}
-	c.Length = uint8(c.MarshalLen() - 2)
+	c.Length = c.contentLen()
 }
 
 // ComponentTypeString returns the Component Type in string.
@@ -695,27 +1020,160 @@ func (c *Component) ComponentTypeString() string {
 	return ""
 }
 
-// InvID returns the InvID in string.
+// ProblemString returns the Problem of Reject in string, in the form of
+// "category: code", e.g., "invoke-problem: mistypedParameter".
+//
+// The problem code is interpreted in the category given as problemType, as
+// the same code has different meanings in each category. An unknown code is
+// given in decimal, and an unknown category results in empty string.
+func ProblemString(problemType int, problemCode uint8) string {
+	var category string
+	var codes []string
+	switch problemType {
+	case GeneralProblem:
+		category = "general-problem"
+		codes = []string{
+			"unrecognizedComponent", "mistypedComponent", "badlyStructuredComponent",
+		}
+	case InvokeProblem:
+		category = "invoke-problem"
+		codes = []string{
+			"duplicateInvokeID", "unrecognizedOperation", "mistypedParameter", "resourceLimitation",
+			"initiatingRelease", "unrecognizedLinkedID", "linkedResponseUnexpected", "unexpectedLinkedOperation",
+		}
+	case ReturnResultProblem:
+		category = "return-result-problem"
+		codes = []string{
+			"unrecognizedInvokeID", "returnResultUnexpected", "mistypedParameter",
+		}
+	case ReturnErrorProblem:
+		category = "return-error-problem"
+		codes = []string{
+			"unrecognizedInvokeID", "returnErrorUnexpected", "unrecognizedError", "unexpectedError", "mistypedParameter",
+		}
+	default:
+		return ""
+	}
+
+	if int(problemCode) < len(codes) {
+		return category + ": " + codes[problemCode]
+	}
+	return fmt.Sprintf("%s: %d", category, problemCode)
+}
+
+// ProblemString returns the Problem of Reject Component in string.
+// See ProblemString for the format.
+func (c *Component) ProblemString() string {
+	if c.Type.Code() != Reject {
+		return ""
+	}
+	p := c.ProblemCode
+	if p == nil || len(p.Value) == 0 {
+		return ""
+	}
+	return ProblemString(p.Tag.Code(), p.Value[0])
+}
+
+// InvID returns the InvID in string. It returns 0 if the Invoke ID is absent or NULL.
 func (c *Component) InvID() uint8 {
-	if c.InvokeID != nil {
+	if c.InvokeID != nil && len(c.InvokeID.Value) != 0 {
 		return c.InvokeID.Value[0]
 	}
 	return 0
 }
 
 // OpCode returns the OpCode in string.
+//
+// It returns 0 if the code is absent, e.g., in ReturnResult without the result.
 func (c *Component) OpCode() uint8 {
-	if c.Type.Code() == ReturnError {
-		return c.ErrorCode.Value[0]
-	} else if c.Type.Code() != Reject {
-		return c.OperationCode.Value[0]
+	if code := c.opCodeIE(); code != nil && len(code.Value) != 0 {
+		return code.Value[0]
 	}
 	return 0
 }
 
+// opCodeIE returns the ErrorCode of ReturnError or the OperationCode of the
+// other Components, or nil for Reject.
+func (c *Component) opCodeIE() *IE {
+	switch c.Type.Code() {
+	case ReturnError:
+		return c.ErrorCode
+	case Reject:
+		return nil
+	}
+	return c.OperationCode
+}
+
+// Parameters returns the child IEs of the Parameter, which are parsed from the
+// Parameter if not parsed yet. It returns an empty slice if the Parameter is
+// absent, primitive, or cannot be parsed.
+func (c *Component) Parameters() []*IE {
+	p := c.Parameter
+	if p == nil || p.Tag.Form() != Constructor {
+		return []*IE{}
+	}
+	if len(p.IE) != 0 {
+		return p.IE
+	}
+
+	ies, err := p.AsIEs()
+	if err != nil || len(ies) == 0 {
+		return []*IE{}
+	}
+	return ies
+}
+
+// OperationCodeKind returns the form of the Operation Code, i.e., either
+// CodeKindLocal or CodeKindGlobal, or CodeKindNone if it is absent.
+func (c *Component) OperationCodeKind() int {
+	return codeKind(c.OperationCode)
+}
+
+// OperationCodeOID returns the global Operation Code in dot notation, or empty
+// string if the Operation Code is not global.
+func (c *Component) OperationCodeOID() string {
+	return codeOID(c.OperationCode)
+}
+
+// ErrorCodeKind returns the form of the Error Code, i.e., either CodeKindLocal
+// or CodeKindGlobal, or CodeKindNone if it is absent.
+func (c *Component) ErrorCodeKind() int {
+	return codeKind(c.ErrorCode)
+}
+
+// ErrorCodeOID returns the global Error Code in dot notation, or empty string
+// if the Error Code is not global.
+func (c *Component) ErrorCodeOID() string {
+	return codeOID(c.ErrorCode)
+}
+
+// IsLast reports whether the Component is ReturnResultLast, i.e., the last
+// segment of the result. It is false for ReturnResultNotLast, and also for the
+// other types of Components.
+func (c *Component) IsLast() bool {
+	return c.Type.Code() == ReturnResultLast
+}
+
+// IsParameterConstructed reports whether the Parameter is constructed, i.e., a
+// parameter sequence or set, rather than a single primitive element.
+func (c *Component) IsParameterConstructed() bool {
+	return c.Parameter != nil && c.Parameter.Tag.Form() == Constructor
+}
+
+// ParameterBytes returns the Parameter encoded in bytes including its Tag and
+// Length, or nil if the Parameter is absent.
+func (c *Component) ParameterBytes() []byte {
+	p := c.Parameter
+	if p == nil {
+		return nil
+	}
+	b := appendLength([]byte{uint8(p.Tag)}, len(p.Value), 1)
+	return append(b, p.Value...)
+}
+
 // String returns Components in human readable string.
 func (c *Components) String() string {
-	return fmt.Sprintf("{Tag: %#x, Length: %d, Component: %v}",
+	return fmt.Sprintf("{Tag: %v, Length: %d, Component: %v}",
 		c.Tag,
 		c.Length,
 		c.Component,
@@ -724,7 +1182,7 @@ func (c *Components) String() string {
 
 // String returns Component in human readable string.
 func (c *Component) String() string {
-	return fmt.Sprintf("{Type: %#x, Length: %d, ResultRetres: %v, InvokeID: %v, LinkedID: %v, OperationCode: %v, ErrorCode: %v, ProblemCode: %v, Parameter: %v}",
+	return fmt.Sprintf("{Type: %v, Length: %d, ResultRetres: %v, InvokeID: %v, LinkedID: %v, OperationCode: %v, ErrorCode: %v, ProblemCode: %v, Parameter: %v}",
 		c.Type,
 		c.Length,
 		c.ResultRetres,
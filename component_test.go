@@ -0,0 +1,1063 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+func TestTypedComponent(t *testing.T) {
+	param := []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}
+	cases := []struct {
+		description string
+		component   *tcap.Component
+		check       func(t *testing.T, tc tcap.TypedComponent)
+	}{
+		{
+			"invoke",
+			tcap.NewInvoke(1, 0, 71, true, param),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.InvokeComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 1 || v.LinkedID != -1 || v.OperationCode != 71 || !v.IsLocal {
+					t.Errorf("got %+v", v)
+				}
+			},
+		}, {
+			"invoke with linked ID",
+			tcap.NewInvoke(2, 1, 71, true, param),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.InvokeComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 2 || v.LinkedID != 1 {
+					t.Errorf("got %+v", v)
+				}
+			},
+		}, {
+			"returnResultLast",
+			tcap.NewReturnResult(1, 71, true, true, param),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.ReturnResultLastComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 1 || v.OperationCode != 71 || v.Parameter == nil {
+					t.Errorf("got %+v", v)
+				}
+			},
+		}, {
+			"returnResultNotLast",
+			tcap.NewReturnResult(1, 71, true, false, param),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.ReturnResultNotLastComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 1 || v.OperationCode != 71 {
+					t.Errorf("got %+v", v)
+				}
+			},
+		}, {
+			"returnError",
+			tcap.NewReturnError(1, 34, true, []byte{0xde, 0xad, 0xbe, 0xef}),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.ReturnErrorComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 1 || v.ErrorCode != 34 {
+					t.Errorf("got %+v", v)
+				}
+			},
+		}, {
+			"reject",
+			tcap.NewReject(1, tcap.InvokeProblem, tcap.InvokeProblemMistypedParameter, nil),
+			func(t *testing.T, tc tcap.TypedComponent) {
+				v, ok := tc.(*tcap.RejectComponent)
+				if !ok {
+					t.Fatalf("got %T", tc)
+				}
+				if v.InvokeID != 1 || v.ProblemType != tcap.InvokeProblem || v.ProblemCode != tcap.InvokeProblemMistypedParameter {
+					t.Errorf("got %+v", v)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tc := c.component.Typed()
+			c.check(t, tc)
+
+			want, err := c.component.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tc.Component().MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verify.Values(t, "", got, want) {
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestTypedComponentsRoundTrip(t *testing.T) {
+	param, err := tcap.ParseIERecursive([]byte{0x30, 0x02, 0x04, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := []tcap.TypedComponent{
+		&tcap.InvokeComponent{InvokeID: 1, LinkedID: -1, OperationCode: 71, IsLocal: true, Parameter: param},
+		&tcap.InvokeComponent{InvokeID: 2, LinkedID: 1, OperationCode: 71, IsLocal: true},
+		&tcap.InvokeComponent{InvokeID: 8, LinkedID: 0, OperationCode: 71, IsLocal: true},
+		&tcap.ReturnResultLastComponent{InvokeID: 1, OperationCode: 71, IsLocal: true, Parameter: param},
+		&tcap.ReturnResultNotLastComponent{InvokeID: 3, OperationCode: 71, IsLocal: true, Parameter: param},
+		&tcap.InvokeComponent{InvokeID: 7, LinkedID: -1, OperationCodeOID: "1.2.3.5", Parameter: param},
+		&tcap.ReturnResultLastComponent{InvokeID: 7, OperationCodeOID: "1.2.3.5", Parameter: param},
+		&tcap.ReturnErrorComponent{InvokeID: 4, ErrorCode: 34, IsLocal: true},
+		&tcap.ReturnErrorComponent{InvokeID: 5, ErrorCodeOID: "1.2.3.4", Parameter: param},
+		&tcap.RejectComponent{InvokeID: 6, ProblemType: tcap.InvokeProblem, ProblemCode: tcap.InvokeProblemMistypedParameter},
+	}
+
+	b, err := tcap.MarshalTypedComponents(comps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tcap.Tag(b[0]), tcap.TagComponentPortion; got != want {
+		t.Errorf("got Tag %v want %v", got, want)
+	}
+
+	parsed, err := tcap.ParseTypedComponents(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(comps) {
+		t.Fatalf("got %d Components want %d", len(parsed), len(comps))
+	}
+	for i, tc := range parsed {
+		verify.Values(t, fmt.Sprintf("component %d", i), tc, comps[i])
+	}
+
+	re, err := tcap.MarshalTypedComponents(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "re-marshaled", re, b)
+
+	if _, err := tcap.ParseTypedComponents([]byte{0x6c}); err == nil {
+		t.Error("got no error with truncated Component Portion")
+	}
+}
+
+func TestTypedComponentsFromParseBER(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x65, 0x1e, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11, 0x49, 0x04, 0x22, 0x22, 0x22, 0x22,
+		// Component Portion
+		0x6c, 0x10,
+		// Invoke
+		0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x3d,
+		// Reject
+		0xa4, 0x06, 0x02, 0x01, 0x00, 0x81, 0x01, 0x01,
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typed := parsed[0].TypedComponents()
+	if got, want := len(typed), 2; got != want {
+		t.Fatalf("got %d components want %d", got, want)
+	}
+	for i, tc := range typed {
+		switch v := tc.(type) {
+		case *tcap.InvokeComponent:
+			if i != 0 || v.InvokeID != 1 || v.OperationCode != 61 {
+				t.Errorf("got %+v at %d", v, i)
+			}
+		case *tcap.RejectComponent:
+			if i != 1 || v.InvokeID != 0 || v.ProblemType != tcap.InvokeProblem || v.ProblemCode != tcap.InvokeProblemUnrecognizedOperation {
+				t.Errorf("got %+v at %d", v, i)
+			}
+		default:
+			t.Errorf("got unexpected %T at %d", v, i)
+		}
+	}
+}
+
+func TestReturnResultIsLast(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x65, 0x28, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11, 0x49, 0x04, 0x22, 0x22, 0x22, 0x22,
+		// Component Portion
+		0x6c, 0x1a,
+		// ReturnResultNotLast
+		0xa7, 0x0b, 0x02, 0x01, 0x01, 0x30, 0x06, 0x02, 0x01, 0x38, 0x04, 0x01, 0xaa,
+		// ReturnResultLast
+		0xa2, 0x0b, 0x02, 0x01, 0x01, 0x30, 0x06, 0x02, 0x01, 0x38, 0x04, 0x01, 0xbb,
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comps := parsed[0].Components.Component
+	if got, want := len(comps), 2; got != want {
+		t.Fatalf("got %d components want %d", got, want)
+	}
+	for i, want := range []bool{false, true} {
+		if got := comps[i].IsLast(); got != want {
+			t.Errorf("#%d: got IsLast %v want %v", i, got, want)
+		}
+
+		tc, ok := comps[i].Typed().(interface{ IsLast() bool })
+		if !ok {
+			t.Fatalf("#%d: got %T without IsLast", i, comps[i].Typed())
+		}
+		if got := tc.IsLast(); got != want {
+			t.Errorf("#%d: got typed IsLast %v want %v", i, got, want)
+		}
+	}
+
+	if tcap.NewInvoke(1, -1, 56, true, nil).IsLast() {
+		t.Error("got IsLast for Invoke")
+	}
+}
+
+func TestAccessorsWithoutOperationCode(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x64, 0x22, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion
+		0x6c, 0x1a,
+		// ReturnResultLast with parameter only
+		0xa2, 0x0b, 0x02, 0x01, 0x00, 0x30, 0x06, 0x04, 0x04, 0xde, 0xad, 0xbe, 0xef,
+		// ReturnResultLast without result
+		0xa2, 0x03, 0x02, 0x01, 0x01,
+		// Reject with general problem
+		0xa4, 0x06, 0x02, 0x01, 0x02, 0x80, 0x01, 0x00,
+	}
+
+	parsed, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	berParsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*tcap.TCAP{parsed, berParsed[0]} {
+		// only the Reject has the code, which is 0.
+		verify.Values(t, "OpCode", m.OpCode(), []uint8{0})
+		// nil for the Components without Parameter.
+		verify.Values(t, "LayerPayload", m.LayerPayload(), [][]byte{{0xde, 0xad, 0xbe, 0xef}, nil, nil})
+	}
+}
+
+func TestParseBERReturnResultWithoutOpCode(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x64, 0x1a, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion
+		0x6c, 0x12,
+		// ReturnResultLast with parameter only
+		0xa2, 0x0b, 0x02, 0x01, 0x00, 0x30, 0x06, 0x04, 0x04, 0xde, 0xad, 0xbe, 0xef,
+		// ReturnResultLast without result
+		0xa2, 0x03, 0x02, 0x01, 0x01,
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typed := parsed[0].TypedComponents()
+	if got, want := len(typed), 2; got != want {
+		t.Fatalf("got %d components want %d", got, want)
+	}
+	for i, tc := range typed {
+		v, ok := tc.(*tcap.ReturnResultLastComponent)
+		if !ok {
+			t.Fatalf("got %T at %d", tc, i)
+		}
+		if got, want := v.InvokeID, i; got != want {
+			t.Errorf("got InvokeID %d want %d", got, want)
+		}
+		if got, want := v.OperationCode, -1; got != want {
+			t.Errorf("got OperationCode %d want %d", got, want)
+		}
+
+		// re-marshaling keeps the absence of Operation Code.
+		got, err := v.Component().MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := b[10:23]
+		if i == 1 {
+			want = b[23:]
+		}
+		if !verify.Values(t, "", got, want) {
+			t.Fail()
+		}
+	}
+	if p := typed[0].(*tcap.ReturnResultLastComponent).Parameter; p == nil || p.Tag != 0x04 {
+		t.Errorf("got Parameter %v want OCTET STRING", p)
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	cases := []struct {
+		problemType int
+		problemCode uint8
+		want        string
+	}{
+		{tcap.GeneralProblem, tcap.BadlyStructuredComponent, "general-problem: badlyStructuredComponent"},
+		{tcap.InvokeProblem, tcap.InvokeProblemMistypedParameter, "invoke-problem: mistypedParameter"},
+		{tcap.ReturnResultProblem, tcap.ResultProblemMistypedParameter, "return-result-problem: mistypedParameter"},
+		{tcap.ReturnErrorProblem, tcap.ErrorProblemUnexpectedError, "return-error-problem: unexpectedError"},
+		{tcap.ReturnErrorProblem, 9, "return-error-problem: 9"},
+		{9, 0, ""},
+	}
+
+	for _, c := range cases {
+		if got := tcap.ProblemString(c.problemType, c.problemCode); got != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+	}
+
+	r := tcap.NewReject(1, tcap.InvokeProblem, tcap.InvokeProblemUnrecognizedOperation, nil)
+	if got, want := r.ProblemString(), "invoke-problem: unrecognizedOperation"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := r.Typed().(*tcap.RejectComponent).String(), "invoke-problem: unrecognizedOperation"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestRejectProblemCategories(t *testing.T) {
+	cases := []struct {
+		description string
+		problemType int
+		problemCode uint8
+		want        []byte
+	}{
+		{
+			"return-result-problem",
+			tcap.ReturnResultProblem, tcap.ResultProblemReturnResultUnexpected,
+			[]byte{0xa4, 0x06, 0x02, 0x01, 0x01, 0x82, 0x01, 0x01},
+		}, {
+			"return-error-problem",
+			tcap.ReturnErrorProblem, tcap.ErrorProblemUnrecognizedError,
+			[]byte{0xa4, 0x06, 0x02, 0x01, 0x01, 0x83, 0x01, 0x02},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r := tcap.NewReject(1, c.problemType, c.problemCode, nil)
+			b, err := r.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.want) {
+				t.Errorf("got %x want %x", b, c.want)
+			}
+
+			m := tcap.NewMessage(tcap.End, tcap.WithDTID(0x11111111), tcap.WithComponent(r))
+			mb, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := tcap.ParseBER(mb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parsed[0].Components.Component[0].Typed()
+			verify.Values(t, "RejectComponent", got, &tcap.RejectComponent{
+				InvokeID:    1,
+				ProblemType: c.problemType,
+				ProblemCode: c.problemCode,
+			})
+			if got, want := parsed[0].Components.Component[0].ProblemString(), tcap.ProblemString(c.problemType, c.problemCode); got != want {
+				t.Errorf("got %q want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestOperationCodes(t *testing.T) {
+	m := tcap.NewMessage(
+		tcap.Continue,
+		tcap.WithOTID(0x11111111),
+		tcap.WithDTID(0x22222222),
+		tcap.WithComponent(tcap.NewReturnResult(1, 2, true, true, []byte{0x30, 0x00})),
+		tcap.WithComponent(tcap.NewReturnResultWithoutOpCode(2, true, nil)),
+		tcap.WithComponent(tcap.NewReturnError(3, 1, true, nil)),
+		tcap.WithComponent(tcap.NewInvoke(4, -1, 7, true, []byte{0x30, 0x00})),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify.Values(t, "OperationCodes", parsed[0].OperationCodes(), []int{2, 7})
+}
+
+func TestParseMixedComponents(t *testing.T) {
+	b := tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+		Component(tcap.NewReturnResult(1, 23, true, false, []byte{0x30, 0x03, 0x80, 0x01, 0x00})).
+		Invoke(2, 24, []byte{0x30, 0x00}).
+		Bytes()
+
+	parse := map[string]func([]byte) (*tcap.TCAP, error){
+		"Parse": tcap.Parse,
+		"ParseBER": func(b []byte) (*tcap.TCAP, error) {
+			parsed, err := tcap.ParseBER(b)
+			if err != nil {
+				return nil, err
+			}
+			return parsed[0], nil
+		},
+	}
+	for name, f := range parse {
+		t.Run(name, func(t *testing.T) {
+			m, err := f(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "ComponentType", m.ComponentType(), []string{"returnResultNotLast", "invoke"})
+			verify.Values(t, "InvokeID", m.InvokeID(), []uint8{1, 2})
+			verify.Values(t, "OperationCodes", m.OperationCodes(), []int{23, 24})
+		})
+	}
+}
+
+func TestNullParameter(t *testing.T) {
+	null, err := tcap.NewNull().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x05, 0x00}; !bytes.Equal(null, want) {
+		t.Fatalf("got %x want %x", null, want)
+	}
+
+	b := tcaptest.Begin().OTID(0x11111111).Invoke(1, 3, null).Bytes()
+	want := []byte{
+		0x62, 0x12, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x0a, 0xa1, 0x08, 0x02, 0x01, 0x01, 0x02, 0x01, 0x03, 0x05, 0x00,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	ms, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "ParseBER", ms[0].Components.Component[0].Parameter, tcap.NewNull())
+
+	m, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "Parse", m.Components.Component[0].Parameter, tcap.NewNull())
+}
+
+func TestParameters(t *testing.T) {
+	param := []byte{0x30, 0x06, 0x80, 0x01, 0x05, 0x81, 0x01, 0x06}
+	cases := []struct {
+		description string
+		component   *tcap.Component
+		want        []*tcap.IE
+	}{
+		{
+			"SEQUENCE",
+			tcap.NewInvoke(1, -1, 22, true, param),
+			[]*tcap.IE{
+				tcap.NewIE(tcap.NewContextSpecificPrimitiveTag(0), []byte{0x05}),
+				tcap.NewIE(tcap.NewContextSpecificPrimitiveTag(1), []byte{0x06}),
+			},
+		}, {
+			"SEQUENCE not parsed yet",
+			&tcap.Component{Parameter: &tcap.IE{Tag: 0x30, Length: 6, Value: param[2:]}},
+			[]*tcap.IE{
+				tcap.NewIE(tcap.NewContextSpecificPrimitiveTag(0), []byte{0x05}),
+				tcap.NewIE(tcap.NewContextSpecificPrimitiveTag(1), []byte{0x06}),
+			},
+		}, {
+			"primitive",
+			tcap.NewInvoke(1, -1, 22, true, []byte{0x04, 0x01, 0xff}),
+			[]*tcap.IE{},
+		}, {
+			"absent",
+			tcap.NewInvoke(1, -1, 22, true, nil),
+			[]*tcap.IE{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got := c.component.Parameters()
+			if got == nil {
+				t.Fatal("got nil want non-nil slice")
+			}
+			verify.Values(t, "", got, c.want)
+		})
+	}
+}
+
+func TestParameterForm(t *testing.T) {
+	cases := []struct {
+		description string
+		param       []byte
+		constructed bool
+		children    int
+	}{
+		{"single element", []byte{0x04, 0x02, 0x12, 0x34}, false, 0},
+		{"context-specific single element", []byte{0x80, 0x01, 0x05}, false, 0},
+		{"sequence", []byte{0x30, 0x06, 0x80, 0x01, 0x05, 0x81, 0x01, 0x06}, true, 2},
+		{"set", []byte{0x31, 0x03, 0x80, 0x01, 0x05}, true, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			param, err := tcap.ParseIERecursive(c.param)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// NewReturnError takes the contents of parameter sequence.
+			re := tcap.NewReturnError(1, 34, true, nil)
+			re.Parameter = param
+			re.SetLength()
+
+			for _, b := range [][]byte{
+				tcaptest.Begin().OTID(0x11111111).Invoke(1, 22, c.param).Bytes(),
+				tcaptest.End().DTID(0x11111111).Component(re).Bytes(),
+			} {
+				ms, err := tcap.ParseBER(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				comp := ms[0].Components.Component[0]
+				if got := comp.IsParameterConstructed(); got != c.constructed {
+					t.Errorf("got IsParameterConstructed %v want %v", got, c.constructed)
+				}
+				if got := comp.ParameterBytes(); !bytes.Equal(got, c.param) {
+					t.Errorf("got ParameterBytes %x want %x", got, c.param)
+				}
+				if got := len(comp.Parameters()); got != c.children {
+					t.Errorf("got %d children want %d", got, c.children)
+				}
+			}
+		})
+	}
+}
+
+func TestRawComponent(t *testing.T) {
+	inv := tcap.NewInvoke(1, -1, 22, true, []byte{0x04, 0x01, 0x01})
+	ib, err := inv.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ReturnResultLast with InvokeID 2, opCode 22 and Parameter, as captured.
+	raw := []byte{0xa2, 0x0b, 0x02, 0x01, 0x02, 0x30, 0x06, 0x02, 0x01, 0x16, 0x04, 0x01, 0x02}
+
+	m := tcap.NewMessage(
+		tcap.Continue,
+		tcap.WithOTID(0x11111111),
+		tcap.WithDTID(0x22222222),
+		tcap.WithComponent(inv),
+		tcap.WithRawComponent(raw),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := append(ib, raw...)
+	want := []byte{
+		0x65, uint8(14 + len(comps)),
+		0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x49, 0x04, 0x22, 0x22, 0x22, 0x22,
+		0x6c, uint8(len(comps)),
+	}
+	want = append(want, comps...)
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	ms, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := ms[0].Components.Component
+	if len(parsed) != 2 {
+		t.Fatalf("got %d Components want 2", len(parsed))
+	}
+	if got := parsed[1].ComponentTypeString(); got != "returnResultLast" {
+		t.Errorf("got %s want returnResultLast", got)
+	}
+	if got := parsed[1].ParameterBytes(); !bytes.Equal(got, []byte{0x04, 0x01, 0x02}) {
+		t.Errorf("got ParameterBytes %x", got)
+	}
+}
+
+func TestPrivateTagInParameter(t *testing.T) {
+	// SEQUENCE { OCTET STRING, [PRIVATE 1] { [PRIVATE 2] 0xaabbcc } }
+	param := []byte{
+		0x30, 0x0a,
+		0x04, 0x01, 0x01,
+		0xe1, 0x05, 0xc2, 0x03, 0xaa, 0xbb, 0xcc,
+	}
+	b := tcaptest.Begin().OTID(0x11111111).Invoke(1, 22, param).Bytes()
+
+	ms, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ms[0].Components.Component[0].Parameter
+	if got, want := len(p.IE), 2; got != want {
+		t.Fatalf("got %d IEs in Parameter want %d", got, want)
+	}
+	ext := p.IE[1]
+	if got, want := ext.Tag, tcap.NewPrivateConstructorTag(1); got != want {
+		t.Errorf("got Tag %v want %v", got, want)
+	}
+	if got, want := ext.Tag.Class(), tcap.Private; got != want {
+		t.Errorf("got Class %d want %d", got, want)
+	}
+	if got, want := len(ext.IE), 1; got != want {
+		t.Fatalf("got %d IEs in private IE want %d", got, want)
+	}
+	if got, want := ext.IE[0].Tag, tcap.NewPrivatePrimitiveTag(2); got != want {
+		t.Errorf("got Tag %v want %v", got, want)
+	}
+	if got, want := ext.IE[0].Value, []byte{0xaa, 0xbb, 0xcc}; !bytes.Equal(got, want) {
+		t.Errorf("got Value %x want %x", got, want)
+	}
+
+	re, err := ms[0].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+}
+
+func TestInvalidLocalOperationCode(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x62, 0x13,
+		0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion
+		0x6c, 0x0b,
+		// Invoke with 4-octet local Operation Code
+		0xa1, 0x09,
+		0x02, 0x01, 0x01,
+		0x02, 0x04, 0x00, 0x00, 0x00, 0x38,
+	}
+
+	_, err := tcap.ParseBER(b)
+	var pe *tcap.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v want *ParseError", err)
+	}
+	var lce *tcap.InvalidLocalCodeError
+	if !errors.As(err, &lce) {
+		t.Fatalf("got %v want *InvalidLocalCodeError", err)
+	}
+	if lce.Length != 4 {
+		t.Errorf("got Length %d want 4", lce.Length)
+	}
+
+	if _, err := tcap.Parse(b); !errors.As(err, &lce) {
+		t.Errorf("Parse: got %v want *InvalidLocalCodeError", err)
+	}
+
+	// 2-octet local Operation Code is accepted.
+	ok := []byte{
+		0x62, 0x11,
+		0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x09,
+		0xa1, 0x07,
+		0x02, 0x01, 0x01,
+		0x02, 0x02, 0x01, 0x00,
+	}
+	if _, err := tcap.ParseBER(ok); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+}
+
+func TestGlobalErrorCode(t *testing.T) {
+	const errOID = "1.2.840.113549.1.1"
+
+	invoke := tcap.NewBeginInvoke(0x11111111, 1, 56, []byte{0x30, 0x03, 0x04, 0x01, 0x01})
+	b, err := invoke.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed[0].Components.Component[0].OperationCodeKind(); got != tcap.CodeKindLocal {
+		t.Errorf("got OperationCodeKind %d want %d", got, tcap.CodeKindLocal)
+	}
+
+	re, err := tcap.NewReturnErrorWithOID(1, errOID, []byte{0x04, 0x01, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := tcap.NewMessage(tcap.End, tcap.WithDTID(0x11111111), tcap.WithComponent(re))
+	b, err = m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parse := range []struct {
+		name string
+		fn   func([]byte) (*tcap.TCAP, error)
+	}{
+		{"ParseBER", func(b []byte) (*tcap.TCAP, error) { return tcap.ParseBERStrict(b) }},
+		{"Parse", tcap.Parse},
+	} {
+		t.Run(parse.name, func(t *testing.T) {
+			p, err := parse.fn(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := p.Components.Component[0]
+			if got := c.ErrorCodeKind(); got != tcap.CodeKindGlobal {
+				t.Errorf("got ErrorCodeKind %d want %d", got, tcap.CodeKindGlobal)
+			}
+			if got := c.ErrorCodeOID(); got != errOID {
+				t.Errorf("got ErrorCodeOID %s want %s", got, errOID)
+			}
+			if got := c.OperationCodeKind(); got != tcap.CodeKindNone {
+				t.Errorf("got OperationCodeKind %d want %d", got, tcap.CodeKindNone)
+			}
+
+			typed := c.Typed().(*tcap.ReturnErrorComponent)
+			if typed.IsLocal || typed.ErrorCodeOID != errOID {
+				t.Errorf("got %+v", typed)
+			}
+			got, err := typed.Component().MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := re.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %x want %x", got, want)
+			}
+		})
+	}
+
+	if _, err := tcap.NewReturnErrorWithOID(1, "x", nil); err != tcap.ErrInvalidOID {
+		t.Errorf("got %v want %v", err, tcap.ErrInvalidOID)
+	}
+}
+
+func TestCheckInvokeIDs(t *testing.T) {
+	ok := tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+		Invoke(0, 22, nil).Invoke(1, 22, nil).ReturnResult(0, 45, nil).Message()
+	if err := ok.CheckInvokeIDs(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+
+	dup := tcaptest.Begin().OTID(0x11111111).
+		Invoke(0, 22, nil).Invoke(1, 22, nil).Invoke(0, 45, nil).Invoke(0, 46, nil).Message()
+	err := dup.CheckInvokeIDs()
+	var de *tcap.DuplicateInvokeIDError
+	if !errors.As(err, &de) {
+		t.Fatalf("got %v want *DuplicateInvokeIDError", err)
+	}
+	verify.Values(t, "IDs", de.IDs, []int{0})
+
+	if err := tcap.NewMessage(tcap.Begin, tcap.WithOTID(0x11111111)).CheckInvokeIDs(); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+}
+
+func TestReturnResultSequence(t *testing.T) {
+	cases := []struct {
+		description string
+		comp        *tcap.Component
+		serialized  []byte
+		opCode      int
+		param       []byte
+	}{
+		{
+			"result-with-params",
+			tcap.NewReturnResult(1, 22, true, true, []byte{0x04, 0x01, 0xff}),
+			[]byte{0xa2, 0x0b, 0x02, 0x01, 0x01, 0x30, 0x06, 0x02, 0x01, 0x16, 0x04, 0x01, 0xff},
+			22,
+			[]byte{0x04, 0x01, 0xff},
+		}, {
+			"result-no-params",
+			tcap.NewReturnResultWithoutOpCode(1, true, nil),
+			[]byte{0xa2, 0x03, 0x02, 0x01, 0x01},
+			-1,
+			nil,
+		}, {
+			"result-opcode-only",
+			tcap.NewReturnResult(1, 22, true, true, nil),
+			[]byte{0xa2, 0x08, 0x02, 0x01, 0x01, 0x30, 0x03, 0x02, 0x01, 0x16},
+			22,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			b, err := c.comp.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.serialized) {
+				t.Fatalf("got %x want %x", b, c.serialized)
+			}
+
+			msg := tcaptest.End().DTID(0x11111111).Component(c.comp).Bytes()
+			fromBER, err := tcap.ParseBER(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+				comp := m.Components.Component[0]
+				if got, want := comp.OperationCode != nil, c.opCode >= 0; got != want {
+					t.Fatalf("got OperationCode %v want %v", comp.OperationCode, c.opCode)
+				}
+				if c.opCode >= 0 && comp.OperationCode.Value[0] != uint8(c.opCode) {
+					t.Errorf("got OperationCode %x want %d", comp.OperationCode.Value, c.opCode)
+				}
+				if got := comp.ParameterBytes(); !bytes.Equal(got, c.param) {
+					t.Errorf("got Parameter %x want %x", got, c.param)
+				}
+			}
+		})
+	}
+}
+
+func TestReturnResultWithoutSequence(t *testing.T) {
+	// the Operation Code and the Parameter put without the result sequence.
+	msg := []byte{
+		0x64, 0x14, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x0c, 0xa2, 0x0a, 0x02, 0x01, 0x01, 0x02, 0x01, 0x16, 0x30, 0x02, 0x04, 0x00,
+	}
+
+	fromBER, err := tcap.ParseBER(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := tcap.Parse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+		comp := m.Components.Component[0]
+		if comp.ResultRetres != nil {
+			t.Errorf("got result sequence %v want nil", comp.ResultRetres)
+		}
+		if got, want := m.OperationCodes(), []int{22}; !verify.Values(t, "", got, want) {
+			continue
+		}
+		if got, want := comp.ParameterBytes(), []byte{0x30, 0x02, 0x04, 0x00}; !bytes.Equal(got, want) {
+			t.Errorf("got Parameter %x want %x", got, want)
+		}
+	}
+
+	b, err := fromBER[0].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("got %x want %x", b, msg)
+	}
+}
+
+func TestMixedLengthFormComponents(t *testing.T) {
+	longForm := []byte{
+		0x62, 0x16, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x0e,
+		// Invoke with the Length in the long form.
+		0xa1, 0x81, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x16,
+		// ReturnResultLast with the Length in the short form.
+		0xa2, 0x03, 0x02, 0x01, 0x02,
+	}
+	parsed, err := tcap.ParseBER(longForm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "ComponentType", parsed[0].ComponentType(), []string{"invoke", "returnResultLast"})
+	if got, want := parsed[0].InvokeID(), []uint8{1, 2}; !bytes.Equal(got, want) {
+		t.Errorf("got Invoke IDs %v want %v", got, want)
+	}
+
+	indefinite := []byte{
+		0x62, 0x17, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x0f,
+		// Invoke with the indefinite Length, terminated by end-of-contents.
+		0xa1, 0x80, 0x02, 0x01, 0x01, 0x02, 0x01, 0x16, 0x00, 0x00,
+		0xa2, 0x03, 0x02, 0x01, 0x02,
+	}
+	// the indefinite form is not supported, which must not be taken as the
+	// Components that do not exist.
+	if _, err := tcap.ParseBER(indefinite); !errors.Is(err, tcap.ErrUnsupportedLength) {
+		t.Errorf("got %v want %v", err, tcap.ErrUnsupportedLength)
+	}
+	if _, err := tcap.Parse(indefinite); !errors.Is(err, tcap.ErrUnsupportedLength) {
+		t.Errorf("got %v want %v", err, tcap.ErrUnsupportedLength)
+	}
+}
+
+func TestImplicitlyTaggedParameter(t *testing.T) {
+	cases := []struct {
+		description string
+		param       []byte
+		children    []tcap.Tag
+	}{
+		{
+			// [0] IMPLICIT SEQUENCE { [0] IMPLICIT INTEGER, [1] IMPLICIT OCTET STRING }
+			"constructed",
+			[]byte{0xa0, 0x07, 0x80, 0x01, 0x05, 0x81, 0x02, 0x01, 0x00},
+			[]tcap.Tag{0x80, 0x81},
+		}, {
+			// [1] IMPLICIT OCTET STRING, whose contents happen to look like an IE.
+			"primitive",
+			[]byte{0x81, 0x02, 0x01, 0x00},
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			m := tcap.NewMessage(tcap.Begin, tcap.WithOTID(0x11111111), tcap.WithComponent(tcap.NewInvoke(1, -1, 56, true, c.param)))
+			b, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(b, c.param) {
+				t.Fatalf("got %x want suffix %x", b, c.param)
+			}
+
+			fromBER, err := tcap.ParseBER(b, tcap.WithDeepDecode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, parsed := range []*tcap.TCAP{m, fromBER[0], fromBytes} {
+				comp := parsed.Components.Component[0]
+				if got, want := comp.ParameterBytes(), c.param; !bytes.Equal(got, want) {
+					t.Errorf("got Parameter %x want %x", got, want)
+				}
+				var tags []tcap.Tag
+				for _, i := range comp.Parameters() {
+					tags = append(tags, i.Tag)
+				}
+				verify.Values(t, "Parameter children", tags, c.children)
+			}
+
+			re, err := fromBER[0].MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(re, b) {
+				t.Errorf("got %x want %x", re, b)
+			}
+		})
+	}
+}
+
+func TestRejectWithNullInvokeID(t *testing.T) {
+	b := tcaptest.End().DTID(0x11111111).Reject(-1, tcap.GeneralProblem, 2).Bytes()
+	want := []byte{
+		0x64, 0x0f, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x07, 0xa4, 0x05, 0x05, 0x00, 0x80, 0x01, 0x02,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	parsed, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	berParsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*tcap.TCAP{parsed, berParsed[0]} {
+		verify.Values(t, "InvokeID", m.InvokeID(), []uint8{0})
+
+		rej, ok := m.TypedComponents()[0].(*tcap.RejectComponent)
+		if !ok {
+			t.Fatalf("got %T want *RejectComponent", m.TypedComponents()[0])
+		}
+		if got, want := rej.InvokeID, -1; got != want {
+			t.Errorf("got InvokeID %d want %d", got, want)
+		}
+		re, err := rej.Component().MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(re, b[10:]) {
+			t.Errorf("got %x want %x", re, b[10:])
+		}
+	}
+}
+
+func TestTypedInvokeLinkedIDZero(t *testing.T) {
+	// Invoke with Invoke ID 1, Linked ID 0 and Operation Code 71.
+	b := []byte{0xa1, 0x09, 0x02, 0x01, 0x01, 0x80, 0x01, 0x00, 0x02, 0x01, 0x47}
+	c, err := tcap.ParseComponent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typed, ok := c.Typed().(*tcap.InvokeComponent)
+	if !ok {
+		t.Fatalf("got %T want *InvokeComponent", c.Typed())
+	}
+	if got, want := typed.LinkedID, 0; got != want {
+		t.Errorf("got LinkedID %d want %d", got, want)
+	}
+	re, err := typed.Component().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+}
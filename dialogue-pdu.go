@@ -13,9 +13,9 @@ import (
 
 // Code definitions.
 const (
-    AARQ = iota
-    AARE
-    ABRT
+    AARQ = 0
+    AARE = 1
+    ABRT = 4
     // AUDT = 0
 )
 
@@ -69,6 +69,142 @@ const (
     CapGsmSSFToGsmSCFContext = 50
 )
 
+// minContextVersions is the lowest version of the MAP Application Contexts that
+// are not defined in version 1, as in 3GPP TS 29.002.
+var minContextVersions = map[uint8]int{
+    IstAlertingContext:                            3,
+    CallControlTransferContext:                    3,
+    ReportingContext:                              3,
+    CallCompletionContext:                         3,
+    ServiceTerminationContext:                     3,
+    SIWFSAllocationContext:                        3,
+    InterVlrInfoRetrievalContext:                  2,
+    NetworkUnstructuredSsContext:                  2,
+    SubscriberDataModificationNotificationContext: 3,
+    ShortMsgMTRelayContext:                        2,
+    ImsiRetrievalContext:                          2,
+    MsPurgingContext:                              2,
+    SubscriberInfoEnquiryContext:                  3,
+    AnyTimeInfoEnquiryContext:                     3,
+    GroupCallControlContext:                       3,
+    GprsLocationUpdateContext:                     3,
+    GprsLocationInfoRetrievalContext:              3,
+    FailureReportContext:                          3,
+    GprsNotifyContext:                             3,
+    SsInvocationNotificationContext:               3,
+    LocationSvcGatewayContext:                     3,
+    LocationSvcEnquiryContext:                     3,
+    AuthenticationFailureReportContext:            3,
+    MmEventReportingContext:                       3,
+    AnyTimeInfoHandlingContext:                    3,
+}
+
+// maxContextVersions is the highest version of the MAP Application Contexts,
+// as in 3GPP TS 29.002.
+var maxContextVersions = map[uint8]int{
+    NetworkLocUpContext:                           3,
+    LocationCancellationContext:                   3,
+    RoamingNumberEnquiryContext:                   3,
+    IstAlertingContext:                            3,
+    LocationInfoRetrievalContext:                  3,
+    CallControlTransferContext:                    4,
+    ReportingContext:                              3,
+    CallCompletionContext:                         3,
+    ServiceTerminationContext:                     3,
+    ResetContext:                                  2,
+    HandoverControlContext:                        3,
+    SIWFSAllocationContext:                        3,
+    EquipmentMngtContext:                          3,
+    InfoRetrievalContext:                          3,
+    InterVlrInfoRetrievalContext:                  3,
+    SubscriberDataMngtContext:                     3,
+    TracingContext:                                3,
+    NetworkFunctionalSsContext:                    2,
+    NetworkUnstructuredSsContext:                  2,
+    ShortMsgGatewayContext:                        3,
+    ShortMsgRelayContext:                          3,
+    SubscriberDataModificationNotificationContext: 3,
+    ShortMsgAlertContext:                          2,
+    MwdMngtContext:                                3,
+    ShortMsgMTRelayContext:                        3,
+    ImsiRetrievalContext:                          2,
+    MsPurgingContext:                              3,
+    SubscriberInfoEnquiryContext:                  3,
+    AnyTimeInfoEnquiryContext:                     3,
+    GroupCallControlContext:                       3,
+    GprsLocationUpdateContext:                     3,
+    GprsLocationInfoRetrievalContext:              4,
+    FailureReportContext:                          3,
+    GprsNotifyContext:                             3,
+    SsInvocationNotificationContext:               3,
+    LocationSvcGatewayContext:                     3,
+    LocationSvcEnquiryContext:                     3,
+    AuthenticationFailureReportContext:            3,
+    MmEventReportingContext:                       3,
+    AnyTimeInfoHandlingContext:                    3,
+}
+
+// SupportedVersions returns the versions of the MAP Application Context given
+// as acn, from the highest to the lowest. It returns nil if acn is not known
+// as MAP one, e.g., CapGsmSSFToGsmSCFContext.
+//
+// The first one is the version to try first in Begin, and the rest are the
+// ones to fall back to in order, as NextLowerACN does.
+func SupportedVersions(acn uint8) []int {
+    max, ok := maxContextVersions[acn]
+    if !ok {
+        return nil
+    }
+    min, ok := minContextVersions[acn]
+    if !ok {
+        min = 1
+    }
+
+    var vs []int
+    for v := max; v >= min; v-- {
+        vs = append(vs, v)
+    }
+    return vs
+}
+
+// NextLowerACN returns the version of the Application Context given as acn to
+// try next, when the peer rejects the one of currentVersion. It returns false if
+// there is no lower version of acn, or acn is not known as MAP one as in
+// SupportedVersions. If currentVersion is higher than any supported one, the
+// highest supported version is returned.
+//
+// This is for the usual negotiation of MAP: when AARE has RejectPerm with
+// DiagnosticUserACNNotSupported (see DialogueDiagnostic), the Begin is retried
+// with the lower version until it is accepted or there is nothing to try.
+//
+//	if src, reason, ok := m.DialogueDiagnostic(); ok && src == tcap.DialogueServiceUser && reason == tcap.DiagnosticUserACNNotSupported {
+//	    if v, ok := tcap.NextLowerACN(acn, ver); ok {
+//	        // send Begin again with the version v.
+//	    }
+//	}
+//
+// Note that version 1 of MAP has no Dialogue Portion, so the retry with it should
+// be done without Dialogue Portion.
+func NextLowerACN(acn uint8, currentVersion int) (int, bool) {
+    max, ok := maxContextVersions[acn]
+    if !ok {
+        return 0, false
+    }
+    min, ok := minContextVersions[acn]
+    if !ok {
+        min = 1
+    }
+
+    next := currentVersion - 1
+    if next > max {
+        next = max
+    }
+    if next < min {
+        return 0, false
+    }
+    return next, true
+}
+
 // Result Value defnitions.
 const (
     Accepted uint8 = iota
@@ -88,18 +224,48 @@ const (
     NoReasonGiven
     ApplicationContextNameNotSupplied
     NoCommonDialoguePortion = 2 // same as above...
+
+    // ApplicationContextNameNotSupported is the correct name of
+    // ApplicationContextNameNotSupplied, i.e., application-context-name-not-supported.
+    ApplicationContextNameNotSupported = ApplicationContextNameNotSupplied
+)
+
+// Reason definitions in ResultSourceDiagnostic, with the source in the names.
+// Null and NoReasonGiven are common to both sources, while the reason 2 means
+// differently by source.
+const (
+    DiagnosticUserNull                        = Null
+    DiagnosticUserNoReasonGiven               = NoReasonGiven
+    DiagnosticUserACNNotSupported             = ApplicationContextNameNotSupported
+    DiagnosticProviderNull                    = Null
+    DiagnosticProviderNoReasonGiven           = NoReasonGiven
+    DiagnosticProviderNoCommonDialoguePortion = NoCommonDialoguePortion
 )
 
 // Abort Source defnitions.
 const (
-    AbortDialogueServiceUser int = iota
+    AbortDialogueServiceUser AbortSource = iota
     AbortDialogueServiceProvider
 )
 
+// AbortSource is the abort-source in ABRT.
+type AbortSource int
+
+// String returns the AbortSource in the name defined in ASN.1 of Q.773.
+func (s AbortSource) String() string {
+    switch s {
+    case AbortDialogueServiceUser:
+        return "dialogue-service-user"
+    case AbortDialogueServiceProvider:
+        return "dialogue-service-provider"
+    }
+    return fmt.Sprintf("unknown(%d)", int(s))
+}
+
 // DialoguePDU represents a DialoguePDU field in Dialogue.
 type DialoguePDU struct {
     Type                   Tag
-    Length                 uint8
+    Length                 int
     ProtocolVersion        *IE
     ApplicationContextName *IE
     Result                 *IE
@@ -125,13 +291,7 @@ func NewDialoguePDU(dtype, pver int, ctx, ctxver, result uint8, diagsrc int, dia
             Value:  []byte{abortsrc},
         },
     }
-    if len(userinfo) > 0 {
-        d.UserInformation = &IE{
-            Tag:   NewContextSpecificConstructorTag(30),
-            Value: userinfo[0].Value,
-        }
-        d.UserInformation.SetLength()
-    }
+    d.UserInformation = newUserInformationIE(userinfo)
     d.SetLength()
     return d
 }
@@ -141,7 +301,7 @@ func NewDialoguePDU(dtype, pver int, ctx, ctxver, result uint8, diagsrc int, dia
 func NewApplicationContextName(ctx, ver uint8) *IE {
     return &IE{
         Tag:    NewContextSpecificConstructorTag(1),
-        Length: uint8(9),
+        Length: 9,
         Value:  []byte{0x06, 0x07, 4, 0, 0, 1, 0, ctx, ver},
     }
 }
@@ -187,13 +347,7 @@ func NewAARQ(protover int, context, contextver uint8, userinfo ...*IE) *Dialogue
         },
         ApplicationContextName: NewApplicationContextName(context, contextver),
     }
-    if len(userinfo) > 0 {
-        d.UserInformation = &IE{
-            Tag:   NewContextSpecificConstructorTag(30),
-            Value: userinfo[0].Value,
-        }
-        d.UserInformation.SetLength()
-    }
+    d.UserInformation = newUserInformationIE(userinfo)
     d.SetLength()
     return d
 }
@@ -210,13 +364,7 @@ func NewAARE(protover int, context, contextver, result uint8, diagsrc int, reaso
         Result:                 NewResult(result),
         ResultSourceDiagnostic: NewResultSourceDiagnostic(diagsrc, reason),
     }
-    if len(userinfo) > 0 {
-        d.UserInformation = &IE{
-            Tag:   NewContextSpecificConstructorTag(30),
-            Value: userinfo[0].Value,
-        }
-        d.UserInformation.SetLength()
-    }
+    d.UserInformation = newUserInformationIE(userinfo)
     d.SetLength()
     return d
 }
@@ -231,17 +379,75 @@ func NewABRT(abortsrc uint8, userinfo ...*IE) *DialoguePDU {
             Value:  []byte{abortsrc},
         },
     }
-    if len(userinfo) > 0 {
-        d.UserInformation = &IE{
-            Tag:   NewContextSpecificConstructorTag(30),
-            Value: userinfo[0].Value,
-        }
-        d.UserInformation.SetLength()
+    d.UserInformation = newUserInformationIE(userinfo)
+    d.SetLength()
+    return d
+}
+
+// NewAARQWith returns a new AARQ(Dialogue Request) with the optional fields
+// controlled by opts. The protocol-version of version 1 is put by default, and
+// the user-information is omitted by default.
+func NewAARQWith(context, contextver uint8, opts ...DialogueOption) *DialoguePDU {
+    d := &DialoguePDU{
+        Type:                   NewApplicationWideConstructorTag(AARQ),
+        ApplicationContextName: NewApplicationContextName(context, contextver),
+    }
+    d.setOptionalFields(newDialogueOptions(opts, true))
+    d.SetLength()
+    return d
+}
+
+// NewAAREWith returns a new AARE(Dialogue Response) with the optional fields
+// controlled by opts. Both the protocol-version and the user-information are
+// omitted by default.
+func NewAAREWith(context, contextver, result uint8, diagsrc int, reason uint8, opts ...DialogueOption) *DialoguePDU {
+    d := &DialoguePDU{
+        Type:                   NewApplicationWideConstructorTag(AARE),
+        ApplicationContextName: NewApplicationContextName(context, contextver),
+        Result:                 NewResult(result),
+        ResultSourceDiagnostic: NewResultSourceDiagnostic(diagsrc, reason),
     }
+    d.setOptionalFields(newDialogueOptions(opts, false))
     d.SetLength()
     return d
 }
 
+func (d *DialoguePDU) setOptionalFields(o *dialogueOptions) {
+    if o.hasVersion {
+        d.ProtocolVersion = &IE{
+            Tag:   NewContextSpecificPrimitiveTag(0),
+            Value: []byte{0x07, uint8(o.protover << 7)},
+        }
+    }
+    if ui := newUserInformationIE(o.userinfo); ui != nil {
+        d.UserInformation = ui
+    }
+}
+
+// newUserInformationIE puts the EXTERNALs in all the user-information given
+// into a single user-information, or returns nil if none is given.
+func newUserInformationIE(userinfo []*IE) *IE {
+    var value []byte
+    for _, ui := range userinfo {
+        if ui != nil {
+            value = append(value, ui.Value...)
+        }
+    }
+    if value == nil {
+        return nil
+    }
+    return NewIE(NewContextSpecificConstructorTag(30), value)
+}
+
+// UserInformationList returns all the EXTERNALs in the user-information, in
+// the order they appear. It returns nil if the user-information is absent.
+func (d *DialoguePDU) UserInformationList() ([]*UserInformation, error) {
+    if d.UserInformation == nil {
+        return nil, nil
+    }
+    return ParseUserInformation(d.UserInformation)
+}
+
 /*
 // NewAUDT returns a new AUDT(Unidirectional Dialogue).
 func NewAUDT(protover int, context, contextver uint8, userinfo ...*IE) *DialoguePDU {
@@ -284,22 +490,21 @@ func (d *DialoguePDU) MarshalTo(b []byte) error {
     }
 
     b[0] = uint8(d.Type)
-    b[1] = d.Length
+    offset := 1 + putLength(b[1:], d.Length, lengthLen(d.contentLen()))
 
     switch d.Type.Code() {
     case AARQ:
-        return d.marshalAARQTo(b)
+        return d.marshalAARQTo(b, offset)
     case AARE:
-        return d.marshalAARETo(b)
+        return d.marshalAARETo(b, offset)
     case ABRT:
-        return d.marshalABRTTo(b)
+        return d.marshalABRTTo(b, offset)
     default:
         return &InvalidCodeError{Code: d.Type.Code()}
     }
 }
 
-func (d *DialoguePDU) marshalAARQTo(b []byte) error {
-    var offset = 2
+func (d *DialoguePDU) marshalAARQTo(b []byte, offset int) error {
     if field := d.ProtocolVersion; field != nil {
         if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
             return err
@@ -323,8 +528,7 @@ func (d *DialoguePDU) marshalAARQTo(b []byte) error {
     return nil
 }
 
-func (d *DialoguePDU) marshalAARETo(b []byte) error {
-    var offset = 2
+func (d *DialoguePDU) marshalAARETo(b []byte, offset int) error {
     if field := d.ProtocolVersion; field != nil {
         if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
             return err
@@ -362,8 +566,7 @@ func (d *DialoguePDU) marshalAARETo(b []byte) error {
     return nil
 }
 
-func (d *DialoguePDU) marshalABRTTo(b []byte) error {
-    var offset = 2
+func (d *DialoguePDU) marshalABRTTo(b []byte, offset int) error {
     if field := d.AbortSource; field != nil {
         if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
             return err
@@ -395,35 +598,38 @@ func (d *DialoguePDU) UnmarshalBinary(b []byte) error {
         return io.ErrUnexpectedEOF
     }
 
+    offset, length, err := decodeLength(b)
+    if err != nil {
+        return err
+    }
     d.Type = Tag(b[0])
-    d.Length = b[1]
+    d.Length = length
 
     switch d.Type.Code() {
     case AARQ:
-        return d.parseAARQFromBytes(b)
+        return d.parseAARQFromBytes(b, offset)
     case AARE:
-        return d.parseAAREFromBytes(b)
+        return d.parseAAREFromBytes(b, offset)
     case ABRT:
-        return d.parseABRTFromBytes(b)
+        return d.parseABRTFromBytes(b, offset)
     default:
         return &InvalidCodeError{Code: d.Type.Code()}
     }
 }
 
-func (d *DialoguePDU) parseAARQFromBytes(b []byte) error {
+func (d *DialoguePDU) parseAARQFromBytes(b []byte, offset int) error {
     var err error
-    var offset = 2
     d.ProtocolVersion, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.ProtocolVersion.MarshalLen()
+    offset += encodedLen(b[offset:], d.ProtocolVersion)
 
     d.ApplicationContextName, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.ApplicationContextName.MarshalLen()
+    offset += encodedLen(b[offset:], d.ApplicationContextName)
 
     if offset < len(b)-1 {
         if b[offset] == uint8(NewContextSpecificConstructorTag(30)) {
@@ -437,32 +643,38 @@ func (d *DialoguePDU) parseAARQFromBytes(b []byte) error {
     return nil
 }
 
-func (d *DialoguePDU) parseAAREFromBytes(b []byte) error {
+func (d *DialoguePDU) parseAAREFromBytes(b []byte, offset int) error {
     var err error
-    var offset = 2
-    d.ProtocolVersion, err = ParseIE(b[offset:])
-    if err != nil {
-        return err
+    if offset >= len(b) {
+        return io.ErrUnexpectedEOF
+    }
+
+    // protocol-version is optional in AARE, and NewAARE does not set it.
+    if b[offset] == uint8(NewContextSpecificPrimitiveTag(0)) {
+        d.ProtocolVersion, err = ParseIE(b[offset:])
+        if err != nil {
+            return err
+        }
+        offset += encodedLen(b[offset:], d.ProtocolVersion)
     }
-    offset += d.ProtocolVersion.MarshalLen()
 
     d.ApplicationContextName, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.ApplicationContextName.MarshalLen()
+    offset += encodedLen(b[offset:], d.ApplicationContextName)
 
     d.Result, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.Result.MarshalLen()
+    offset += encodedLen(b[offset:], d.Result)
 
     d.ResultSourceDiagnostic, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.ResultSourceDiagnostic.MarshalLen()
+    offset += encodedLen(b[offset:], d.ResultSourceDiagnostic)
 
     if offset < len(b)-1 {
         if b[offset] == uint8(NewContextSpecificConstructorTag(30)) {
@@ -476,14 +688,13 @@ func (d *DialoguePDU) parseAAREFromBytes(b []byte) error {
     return nil
 }
 
-func (d *DialoguePDU) parseABRTFromBytes(b []byte) error {
+func (d *DialoguePDU) parseABRTFromBytes(b []byte, offset int) error {
     var err error
-    var offset = 2
     d.AbortSource, err = ParseIE(b[offset:])
     if err != nil {
         return err
     }
-    offset += d.AbortSource.MarshalLen()
+    offset += encodedLen(b[offset:], d.AbortSource)
     if offset < len(b)-1 {
         if b[offset] == uint8(NewContextSpecificConstructorTag(30)) {
             d.UserInformation, err = ParseIE(b[offset:])
@@ -498,7 +709,13 @@ func (d *DialoguePDU) parseABRTFromBytes(b []byte) error {
 
 // MarshalLen returns the serial length of DialoguePDU.
 func (d *DialoguePDU) MarshalLen() int {
-    l := 2
+    l := d.contentLen()
+    return 1 + lengthLen(l) + l
+}
+
+// contentLen returns the length of the contents of DialoguePDU.
+func (d *DialoguePDU) contentLen() int {
+    var l int
     switch d.Type.Code() {
     case AARQ:
         if field := d.ProtocolVersion; field != nil {
@@ -563,7 +780,7 @@ func (d *DialoguePDU) SetLength() {
     if field := d.UserInformation; field != nil {
         field.SetLength()
     }
-    d.Length = uint8(d.MarshalLen() - 2)
+    d.Length = d.contentLen()
 }
 
 // DialogueType returns the name of Dialogue Type in string.
@@ -580,6 +797,46 @@ func (d *DialoguePDU) DialogueType() string {
     }
 }
 
+// DialogueResult returns the result of AARE, i.e., either Accepted or
+// RejectPerm. ok is false if the DialoguePDU has no valid result.
+func (d *DialoguePDU) DialogueResult() (result uint8, ok bool) {
+    field := d.Result
+    if d.Type.Code() != AARE || field == nil {
+        return 0, false
+    }
+
+    // INTEGER of the result.
+    v := field.Value
+    if len(v) < 3 || v[0] != 0x02 || v[1] == 0 || 2+int(v[1]) > len(v) {
+        return 0, false
+    }
+    return v[1+int(v[1])], true
+}
+
+// DialogueDiagnostic returns the source and the reason in the
+// result-source-diagnostic of AARE. The source is either DialogueServiceUser or
+// DialogueServiceProvider, and the reason is one of the Diagnostic* constants
+// for the source, e.g., DiagnosticUserACNNotSupported.
+//
+// ok is false if the DialoguePDU has no valid result-source-diagnostic.
+func (d *DialoguePDU) DialogueDiagnostic() (source int, reason uint8, ok bool) {
+    field := d.ResultSourceDiagnostic
+    if d.Type.Code() != AARE || field == nil {
+        return 0, 0, false
+    }
+
+    // [1] or [2] of the source, with the INTEGER of the reason inside.
+    v := field.Value
+    if len(v) < 5 || v[2] != 0x02 || v[3] == 0 || 4+int(v[3]) > len(v) {
+        return 0, 0, false
+    }
+    source = Tag(v[0]).Code()
+    if source != DialogueServiceUser && source != DialogueServiceProvider {
+        return 0, 0, false
+    }
+    return source, v[3+int(v[3])], true
+}
+
 // Version returns Protocol Version in string.
 func (d *DialoguePDU) Version() string {
     if d.Type.Code() == AARQ || d.Type.Code() == AARE {
@@ -704,7 +961,7 @@ func (d *DialoguePDU) ContextVersion() string {
 
 // String returns DialoguePDU in human readable string.
 func (d *DialoguePDU) String() string {
-    return fmt.Sprintf("{Type: %#x, Length: %d, ProtocolVersion: %v, ApplicationContextName: %v, Result: %v, ResultSourceDiagnostic: %v, AbortSource: %v}",
+    return fmt.Sprintf("{Type: %v, Length: %d, ProtocolVersion: %v, ApplicationContextName: %v, Result: %v, ResultSourceDiagnostic: %v, AbortSource: %v}",
         d.Type,
         d.Length,
         d.ProtocolVersion,
@@ -0,0 +1,295 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestDialogueOptions(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0x30, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		protoVer = []byte{0x80, 0x02, 0x07, 0x80}
+		acn      = []byte{0xa1, 0x09, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x00, 0x05, 0x03}
+		result   = []byte{0xa2, 0x03, 0x02, 0x01, 0x00, 0xa3, 0x05, 0xa1, 0x03, 0x02, 0x01, 0x00}
+		userInfo = []byte{
+			0xbe, 0x0f, 0x28, 0x0d, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01, 0xa0, 0x02, 0x30, 0x00,
+		}
+	)
+	concat := func(tag uint8, parts ...[]byte) []byte {
+		b := bytes.Join(parts, nil)
+		return append([]byte{tag, uint8(len(b))}, b...)
+	}
+
+	cases := []struct {
+		description string
+		pdu         *tcap.DialoguePDU
+		want        []byte
+	}{
+		{
+			"AARQ default",
+			tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3),
+			concat(0x60, protoVer, acn),
+		}, {
+			"AARQ without protocol-version",
+			tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3, tcap.WithoutProtocolVersion()),
+			concat(0x60, acn),
+		}, {
+			"AARQ with user-information",
+			tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3, tcap.WithUserInformation(ui)),
+			concat(0x60, protoVer, acn, userInfo),
+		}, {
+			"AARQ with user-information only",
+			tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3, tcap.WithoutProtocolVersion(), tcap.WithUserInformation(ui)),
+			concat(0x60, acn, userInfo),
+		}, {
+			"AARE default",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.Accepted, tcap.DialogueServiceUser, tcap.Null),
+			concat(0x61, acn, result),
+		}, {
+			"AARE with protocol-version",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.Accepted, tcap.DialogueServiceUser, tcap.Null, tcap.WithProtocolVersion(1)),
+			concat(0x61, protoVer, acn, result),
+		}, {
+			"AARE with user-information",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.Accepted, tcap.DialogueServiceUser, tcap.Null, tcap.WithUserInformation(ui)),
+			concat(0x61, acn, result, userInfo),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := c.pdu.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("got %x want %x", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDialogueDiagnostic(t *testing.T) {
+	cases := []struct {
+		description string
+		pdu         *tcap.DialoguePDU
+		source      int
+		reason      uint8
+		ok          bool
+	}{
+		{
+			"user null",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.Accepted, tcap.DialogueServiceUser, tcap.DiagnosticUserNull),
+			tcap.DialogueServiceUser, tcap.DiagnosticUserNull, true,
+		}, {
+			"ACN not supported",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.RejectPerm, tcap.DialogueServiceUser, tcap.DiagnosticUserACNNotSupported),
+			tcap.DialogueServiceUser, tcap.DiagnosticUserACNNotSupported, true,
+		}, {
+			"no common dialogue portion",
+			tcap.NewAAREWith(tcap.LocationInfoRetrievalContext, 3, tcap.RejectPerm, tcap.DialogueServiceProvider, tcap.DiagnosticProviderNoCommonDialoguePortion),
+			tcap.DialogueServiceProvider, tcap.DiagnosticProviderNoCommonDialoguePortion, true,
+		}, {
+			"AARQ",
+			tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3),
+			0, 0, false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			b, err := tcap.NewMessage(
+				tcap.End,
+				tcap.WithDTID(0x11111111),
+				tcap.WithDialogue(tcap.NewDialogue(tcap.DialogueAsID, 1, c.pdu, nil)),
+			).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBER, err := tcap.ParseBER(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+				source, reason, ok := m.DialogueDiagnostic()
+				if ok != c.ok || source != c.source || reason != c.reason {
+					t.Errorf("got (%d, %d, %v) want (%d, %d, %v)", source, reason, ok, c.source, c.reason, c.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestNextLowerACN(t *testing.T) {
+	cases := []struct {
+		description string
+		acn         uint8
+		current     int
+		want        int
+		ok          bool
+	}{
+		{"v3 to v2", tcap.LocationCancellationContext, 3, 2, true},
+		{"v2 to v1", tcap.LocationCancellationContext, 2, 1, true},
+		{"no lower than v1", tcap.LocationCancellationContext, 1, 0, false},
+		{"v3 only", tcap.AnyTimeInfoEnquiryContext, 3, 0, false},
+		{"v4 to v3", tcap.GprsLocationInfoRetrievalContext, 4, 3, true},
+		{"from v2", tcap.ShortMsgMTRelayContext, 2, 0, false},
+		{"above the highest", tcap.ResetContext, 9, 2, true},
+		{"not MAP", tcap.CapGsmSSFToGsmSCFContext, 4, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, ok := tcap.NextLowerACN(c.acn, c.current)
+			if got != c.want || ok != c.ok {
+				t.Errorf("got (%d, %v) want (%d, %v)", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestSupportedVersions(t *testing.T) {
+	cases := []struct {
+		description string
+		acn         uint8
+		want        []int
+	}{
+		{"v1 to v3", tcap.LocationCancellationContext, []int{3, 2, 1}},
+		{"v3 only", tcap.AnyTimeInfoEnquiryContext, []int{3}},
+		{"v3 to v4", tcap.GprsLocationInfoRetrievalContext, []int{4, 3}},
+		{"v2 to v3", tcap.ShortMsgMTRelayContext, []int{3, 2}},
+		{"not MAP", tcap.CapGsmSSFToGsmSCFContext, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got := tcap.SupportedVersions(c.acn)
+			verify.Values(t, "versions", got, c.want)
+
+			// falling back from the highest goes through all of them.
+			if len(got) == 0 {
+				return
+			}
+			for i, v := range got[:len(got)-1] {
+				if next, ok := tcap.NextLowerACN(c.acn, v); !ok || next != got[i+1] {
+					t.Errorf("NextLowerACN(%d): got (%d, %v) want (%d, true)", v, next, ok, got[i+1])
+				}
+			}
+			if _, ok := tcap.NextLowerACN(c.acn, got[len(got)-1]); ok {
+				t.Errorf("NextLowerACN(%d): got ok", got[len(got)-1])
+			}
+		})
+	}
+}
+
+func TestTypedDialoguePDU(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0x30, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		description string
+		pdu         *tcap.DialoguePDU
+		typed       tcap.TypedDialoguePDU
+	}{
+		{
+			"AARQ",
+			tcap.NewAARQ(1, tcap.LocationInfoRetrievalContext, 3),
+			&tcap.AARQPDU{ProtocolVersion: 1, Context: tcap.LocationInfoRetrievalContext, ContextVersion: 3},
+		}, {
+			"AARE",
+			tcap.NewAARE(1, tcap.LocationInfoRetrievalContext, 2, tcap.RejectPerm, tcap.DialogueServiceUser, tcap.DiagnosticUserACNNotSupported),
+			&tcap.AAREPDU{
+				ProtocolVersion: -1, Context: tcap.LocationInfoRetrievalContext, ContextVersion: 2,
+				Result: tcap.RejectPerm, DiagnosticSource: tcap.DialogueServiceUser, DiagnosticReason: tcap.DiagnosticUserACNNotSupported,
+			},
+		}, {
+			"ABRT",
+			tcap.NewABRT(uint8(tcap.AbortDialogueServiceProvider), ui),
+			&tcap.ABRTPDU{AbortSource: tcap.AbortDialogueServiceProvider, UserInformation: ui},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			typed := c.pdu.Typed()
+			if !verify.Values(t, "typed", typed, c.typed) {
+				return
+			}
+
+			got, err := typed.DialoguePDU().MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := c.pdu.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %x want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestTCAPTypedDialoguePDU(t *testing.T) {
+	begin, err := tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueAsID, tcap.LocationInfoRetrievalContext, 3, 0, 22, nil).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uni, err := tcap.NewUnidirectionalInvokeWithDialogue(tcap.UnidialogueAsID, tcap.LocationInfoRetrievalContext, 3, 0, 22, nil).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{begin, uni} {
+		parsed, err := tcap.ParseBER(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch pdu := parsed[0].TypedDialoguePDU().(type) {
+		case *tcap.AARQPDU:
+			if parsed[0].MessageType() != tcap.Begin {
+				t.Errorf("got AARQ in %d", parsed[0].MessageType())
+			}
+		case *tcap.AUDTPDU:
+			if parsed[0].MessageType() != tcap.Unidirectional {
+				t.Errorf("got AUDT in %d", parsed[0].MessageType())
+			}
+			if got, want := pdu.Context, tcap.LocationInfoRetrievalContext; got != want {
+				t.Errorf("got Context %d want %d", got, want)
+			}
+		default:
+			t.Errorf("got %T", pdu)
+		}
+	}
+
+	if got := tcap.NewBeginInvoke(0x11111111, 0, 22, nil).TypedDialoguePDU(); got != nil {
+		t.Errorf("got %v want nil", got)
+	}
+}
+
+func TestParseTruncatedAARE(t *testing.T) {
+	// the long form Length takes all the octets, leaving nothing for the contents.
+	if _, err := tcap.ParseDialoguePDU([]byte{0x61, 0x83, 0x00, 0x00, 0x00}); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v want %v", err, io.ErrUnexpectedEOF)
+	}
+}
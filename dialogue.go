@@ -17,12 +17,32 @@ const (
 	UnidialogueAsID
 )
 
+// Dialogue structures. The structured dialogue is used in Begin, End, Continue
+// and Abort, and the unstructured one is used in Unidirectional.
+const (
+	DialogueStructured   = DialogueAsID
+	DialogueUnstructured = UnidialogueAsID
+)
+
+// DialogueOID returns the direct-reference OID in dot notation that identifies
+// the dialogue type given as dlgType, i.e., DialogueAsID or UnidialogueAsID.
+// It returns an empty string if dlgType is unknown.
+func DialogueOID(dlgType uint8) string {
+	switch dlgType {
+	case DialogueAsID:
+		return "0.0.17.773.1.1.1"
+	case UnidialogueAsID:
+		return "0.0.17.773.1.2.1"
+	}
+	return ""
+}
+
 // Dialogue represents a Dialogue Portion of TCAP.
 type Dialogue struct {
 	Tag              Tag
-	Length           uint8
+	Length           int
 	ExternalTag      Tag
-	ExternalLength   uint8
+	ExternalLength   int
 	ObjectIdentifier *IE
 	SingleAsn1Type   *IE
 	DialoguePDU      *DialoguePDU
@@ -30,7 +50,14 @@ type Dialogue struct {
 }
 
 // NewDialogue creates a new Dialogue with the DialoguePDU given.
+//
+// oid is the dialogue type, either DialogueAsID or UnidialogueAsID. Otherwise,
+// the Dialogue is built anyway but fails to be marshaled with
+// *UnsupportedDialogueTypeError, as well as the TCAPs that have it.
 func NewDialogue(oid, ver uint8, pdu *DialoguePDU, payload []byte) *Dialogue {
+	if DialogueOID(oid) == "" {
+		logf("failed to build Dialogue: %v", &UnsupportedDialogueTypeError{Type: oid})
+	}
 	d := &Dialogue{
 		Tag:         NewApplicationWideConstructorTag(11),
 		ExternalTag: NewUniversalConstructorTag(8),
@@ -41,7 +68,7 @@ func NewDialogue(oid, ver uint8, pdu *DialoguePDU, payload []byte) *Dialogue {
 		},
 		SingleAsn1Type: &IE{
 			Tag:    NewContextSpecificConstructorTag(0),
-			Length: uint8(pdu.MarshalLen()),
+			Length: pdu.MarshalLen(),
 		},
 		DialoguePDU: pdu,
 		Payload:     payload,
@@ -65,12 +92,13 @@ func (d *Dialogue) MarshalTo(b []byte) error {
 	if len(b) < 4 {
 		return io.ErrUnexpectedEOF
 	}
+	if err := d.validateDialogueType(); err != nil {
+		return err
+	}
 	b[0] = uint8(d.Tag)
-	b[1] = d.Length
-	b[2] = uint8(d.ExternalTag)
-	b[3] = d.ExternalLength
-
-	var offset = 4
+	offset := 1 + putLength(b[1:], d.Length, lengthLen(d.contentLen()))
+	b[offset] = uint8(d.ExternalTag)
+	offset += 1 + putLength(b[offset+1:], d.ExternalLength, lengthLen(d.externalLen()))
 	if field := d.ObjectIdentifier; field != nil {
 		if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
 			return err
@@ -79,10 +107,17 @@ func (d *Dialogue) MarshalTo(b []byte) error {
 	}
 
 	if field := d.SingleAsn1Type; field != nil {
-		if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
-			return err
+		if d.DialoguePDU != nil {
+			// the contents are given by DialoguePDU, even if it has Value
+			// retrieved by parsing.
+			b[offset] = uint8(field.Tag)
+			offset += 1 + putLength(b[offset+1:], d.DialoguePDU.MarshalLen(), lengthLen(d.DialoguePDU.MarshalLen()))
+		} else {
+			if err := field.MarshalTo(b[offset : offset+field.MarshalLen()]); err != nil {
+				return err
+			}
+			offset += field.MarshalLen()
 		}
-		offset += field.MarshalLen()
 	}
 
 	if field := d.DialoguePDU; field != nil {
@@ -97,6 +132,10 @@ func (d *Dialogue) MarshalTo(b []byte) error {
 }
 
 // ParseDialogue parses given byte sequence as an Dialogue.
+//
+// The byte sequence should start with the Dialogue Portion tag (0x6b), as the
+// one returned by MarshalBinary. The bytes after the Dialogue Portion are kept
+// in Payload.
 func ParseDialogue(b []byte) (*Dialogue, error) {
 	d := &Dialogue{}
 	if err := d.UnmarshalBinary(b); err != nil {
@@ -112,24 +151,35 @@ func (d *Dialogue) UnmarshalBinary(b []byte) error {
 		return io.ErrUnexpectedEOF
 	}
 
+	if tag := Tag(b[0]); tag != TagDialoguePortion {
+		return &UnexpectedPortionTagError{Tag: tag, Want: TagDialoguePortion}
+	}
+	offset, length, err := decodeLength(b)
+	if err != nil {
+		return err
+	}
 	d.Tag = Tag(b[0])
-	d.Length = b[1]
-	d.ExternalTag = Tag(b[2])
-	d.ExternalLength = b[3]
+	d.Length = length
+
+	n, length, err := decodeLength(b[offset:])
+	if err != nil {
+		return err
+	}
+	d.ExternalTag = Tag(b[offset])
+	d.ExternalLength = length
+	offset += n
 
-	var err error
-	var offset = 4
 	d.ObjectIdentifier, err = ParseIE(b[offset:])
 	if err != nil {
 		return err
 	}
-	offset += d.ObjectIdentifier.MarshalLen()
+	offset += encodedLen(b[offset:], d.ObjectIdentifier)
 
 	d.SingleAsn1Type, err = ParseIE(b[offset:])
 	if err != nil {
 		return err
 	}
-	offset += d.SingleAsn1Type.MarshalLen()
+	offset += encodedLen(b[offset:], d.SingleAsn1Type)
 
 	d.DialoguePDU, err = ParseDialoguePDU(d.SingleAsn1Type.Value)
 	if err != nil {
@@ -138,60 +188,145 @@ func (d *Dialogue) UnmarshalBinary(b []byte) error {
 
 	d.Payload = b[offset:]
 
+	if _, err := d.DialogueType(); err != nil {
+		return err
+	}
 	return nil
 }
 
 // SetValsFrom sets the values from IE parsed by ParseBER.
 func (d *Dialogue) SetValsFrom(berParsed *IE) error {
+	return d.setValsFrom(berParsed, nil)
+}
+
+// setValsFrom is SetValsFrom with the DialoguePDU stored in pdu if not nil.
+func (d *Dialogue) setValsFrom(berParsed *IE, pdu *DialoguePDU) error {
 	d.Tag = berParsed.Tag
 	d.Length = berParsed.Length
 	for _, ie := range berParsed.IE {
+		if ie.Tag != 0x28 {
+			continue
+		}
+		d.ExternalTag = ie.Tag
+		d.ExternalLength = ie.Length
+
 		var dpdu *IE
-		if ie.Tag == 0x28 {
-			d.ExternalTag = ie.Tag
-			d.ExternalLength = ie.Length
-			for _, iex := range ie.IE {
-				switch iex.Tag {
-				case 0x06:
-					d.ObjectIdentifier = iex
-				case 0xa0:
-					d.SingleAsn1Type = iex
+		for _, iex := range ie.IE {
+			switch iex.Tag {
+			case 0x06:
+				d.ObjectIdentifier = iex
+			case 0xa0:
+				d.SingleAsn1Type = iex
+				if len(iex.IE) > 0 {
 					dpdu = iex.IE[0]
 				}
 			}
 		}
+		if dpdu == nil {
+			continue
+		}
 
 		switch dpdu.Tag.Code() {
 		case AARQ, AARE, ABRT:
-			d.DialoguePDU = &DialoguePDU{
-				Type:   dpdu.Tag,
-				Length: dpdu.Length,
+			if pdu == nil {
+				pdu = &DialoguePDU{}
 			}
+			pdu.Type = dpdu.Tag
+			pdu.Length = dpdu.Length
+			d.DialoguePDU = pdu
+		default:
+			return &InvalidCodeError{Code: dpdu.Tag.Code()}
 		}
 		for _, iex := range dpdu.IE {
 			switch iex.Tag {
 			case 0x80:
-				d.DialoguePDU.ProtocolVersion = iex
+				// [0] is abort-source in ABRT, protocol-version otherwise.
+				if dpdu.Tag.Code() == ABRT {
+					d.DialoguePDU.AbortSource = iex
+				} else {
+					d.DialoguePDU.ProtocolVersion = iex
+				}
 			case 0xa1:
 				d.DialoguePDU.ApplicationContextName = iex
 			case 0xa2:
 				d.DialoguePDU.Result = iex
 			case 0xa3:
 				d.DialoguePDU.ResultSourceDiagnostic = iex
+			case 0xbe:
+				d.DialoguePDU.UserInformation = iex
 			}
 		}
 	}
+
+	if _, err := d.DialogueType(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DialogueType returns the dialogue type identified by the direct-reference OID
+// in ObjectIdentifier, i.e., DialogueAsID or UnidialogueAsID.
+//
+// It returns *InvalidDialogueOIDError if the OID is missing or unknown.
+func (d *Dialogue) DialogueType() (uint8, error) {
+	field := d.ObjectIdentifier
+	if field == nil || field.Tag != 0x06 {
+		return 0, &InvalidDialogueOIDError{}
+	}
+
+	// compare the contents octets not to decode OID in the normal case.
+	if v := field.Value; len(v) == 7 && string(v[:5]) == "\x00\x11\x86\x05\x01" && v[6] == 1 {
+		switch v[5] {
+		case DialogueAsID, UnidialogueAsID:
+			return v[5], nil
+		}
+	}
+
+	oid, err := decodeOID(field.Value)
+	if err != nil {
+		oid = fmt.Sprintf("%x", field.Value)
+	}
+	return 0, &InvalidDialogueOIDError{OID: oid}
+}
+
+// validateDialogueType checks the dialogue type in the ObjectIdentifier built by
+// NewDialogue. The other OIDs are left as they are, to be able to build
+// arbitrary ones, e.g., for testing.
+func (d *Dialogue) validateDialogueType() error {
+	field := d.ObjectIdentifier
+	if field == nil {
+		return nil
+	}
+	if v := field.Value; len(v) == 7 && string(v[:5]) == "\x00\x11\x86\x05\x01" && DialogueOID(v[5]) == "" {
+		return &UnsupportedDialogueTypeError{Type: v[5]}
+	}
 	return nil
 }
 
 // MarshalLen returns the serial length of Dialogue.
 func (d *Dialogue) MarshalLen() int {
-	l := 4
+	l := d.contentLen()
+	return 1 + lengthLen(l) + l
+}
+
+// contentLen returns the length of the contents of Dialogue, i.e., EXTERNAL.
+func (d *Dialogue) contentLen() int {
+	l := d.externalLen()
+	return 1 + lengthLen(l) + l
+}
+
+// externalLen returns the length of the contents of EXTERNAL.
+func (d *Dialogue) externalLen() int {
+	var l int
 	if field := d.ObjectIdentifier; field != nil {
 		l += field.MarshalLen()
 	}
 	if field := d.SingleAsn1Type; field != nil {
-		l += field.MarshalLen()
+		if pdu := d.DialoguePDU; pdu != nil {
+			l += 1 + lengthLen(pdu.MarshalLen())
+		} else {
+			l += field.MarshalLen()
+		}
 	}
 	if field := d.DialoguePDU; field != nil {
 		l += field.MarshalLen()
@@ -203,13 +338,13 @@ func (d *Dialogue) MarshalLen() int {
 
 // SetLength sets the length in Length field.
 func (d *Dialogue) SetLength() {
-	d.Length = uint8(d.MarshalLen() - 2)
-	d.ExternalLength = uint8(d.MarshalLen() - 4)
+	d.Length = d.contentLen()
+	d.ExternalLength = d.externalLen()
 }
 
 // String returns the SCCP common header values in human readable format.
 func (d *Dialogue) String() string {
-	return fmt.Sprintf("{Tag: %#x, Length: %d, ExternalTag: %x, ExternalLength: %d, ObjectIdentifier: %v, SingleAsn1Type: %v, DialoguePDU: %v, Payload: %x}",
+	return fmt.Sprintf("{Tag: %v, Length: %d, ExternalTag: %v, ExternalLength: %d, ObjectIdentifier: %v, SingleAsn1Type: %v, DialoguePDU: %v, Payload: %x}",
 		d.Tag,
 		d.Length,
 		d.ExternalTag,
@@ -0,0 +1,96 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestDialogueStandalone(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0x30, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdu := tcap.NewAARQWith(tcap.LocationInfoRetrievalContext, 3, tcap.WithUserInformation(ui))
+	d := tcap.NewDialogue(tcap.DialogueAsID, 1, pdu, nil)
+
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := pdu.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x6b, uint8(len(pb) + 13), 0x28, uint8(len(pb) + 11),
+		0x06, 0x07, 0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01,
+		0xa0, uint8(len(pb)),
+	}
+	want = append(want, pb...)
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	parsed, err := tcap.ParseDialogue(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := parsed.DialoguePDU.Context(), pdu.Context(); got != want {
+		t.Errorf("got Context %s want %s", got, want)
+	}
+	if got, want := parsed.DialoguePDU.ContextVersion(), pdu.ContextVersion(); got != want {
+		t.Errorf("got ContextVersion %s want %s", got, want)
+	}
+	if got, want := parsed.DialoguePDU.UserInformation.Value, pdu.UserInformation.Value; !bytes.Equal(got, want) {
+		t.Errorf("got user-information %x want %x", got, want)
+	}
+
+	re, err := parsed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+}
+
+func TestUnsupportedDialogueType(t *testing.T) {
+	for _, dlgType := range []uint8{0, 3} {
+		msgs := map[string]*tcap.TCAP{
+			"Begin":                 tcap.NewBeginInvokeWithDialogue(0x11111111, dlgType, tcap.LocationInfoRetrievalContext, 3, 1, 22, nil),
+			"Continue":              tcap.NewContinueInvokeWithDialogue(0x11111111, 0x22222222, 1, 22, dlgType, tcap.LocationInfoRetrievalContext, 3, nil),
+			"End with Invoke":       tcap.NewEndInvokeWithDialogue(0x11111111, 1, 22, dlgType, tcap.LocationInfoRetrievalContext, 3, nil),
+			"End with ReturnResult": tcap.NewEndReturnResultWithDialogue(0x11111111, dlgType, tcap.LocationInfoRetrievalContext, 3, 1, 22, true, nil),
+			"End with ReturnError":  tcap.NewEndReturnErrorWithDialogue(0x11111111, dlgType, tcap.LocationInfoRetrievalContext, 3, 1, 34, true, nil),
+		}
+		for name, m := range msgs {
+			var typeErr *tcap.UnsupportedDialogueTypeError
+			if _, err := m.MarshalBinary(); !errors.As(err, &typeErr) {
+				t.Errorf("%s: got %v want UnsupportedDialogueTypeError for %d", name, err, dlgType)
+				continue
+			}
+			if typeErr.Type != dlgType {
+				t.Errorf("%s: got Type %d want %d", name, typeErr.Type, dlgType)
+			}
+		}
+	}
+
+	if _, err := tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueAsID, tcap.LocationInfoRetrievalContext, 3, 1, 22, nil).MarshalBinary(); err != nil {
+		t.Errorf("got %v want nil for DialogueAsID", err)
+	}
+}
+
+func TestDialogueString(t *testing.T) {
+	d := tcap.NewDialogue(tcap.DialogueAsID, 1, tcap.NewAARQ(1, tcap.LocationInfoRetrievalContext, 3), nil)
+	if s := d.String(); !strings.Contains(s, "ExternalTag: EXTERNAL,") {
+		t.Errorf("got %s want ExternalTag: EXTERNAL", s)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"sync"
+	"time"
+)
+
+// InvokeHandler is a set of callbacks for an outstanding Invoke.
+//
+// Any of the callbacks can be nil. Callbacks are called from the goroutine
+// that calls Dispatch, or from the timer's goroutine for OnTimeout.
+type InvokeHandler struct {
+	// OnResult is called with *ReturnResultLastComponent or
+	// *ReturnResultNotLastComponent. The Invoke is completed by the last one.
+	OnResult func(c TypedComponent)
+	// OnError is called when ReturnError is received for the Invoke.
+	OnError func(c *ReturnErrorComponent)
+	// OnReject is called when Reject is received for the Invoke.
+	OnReject func(c *RejectComponent)
+	// OnTimeout is called when nothing completes the Invoke in time, if the
+	// Operation Class is either OperationClass1 or OperationClass3. For the
+	// others, the missing response is not an error.
+	OnTimeout func(invokeID int)
+}
+
+type pendingInvoke struct {
+	handler *InvokeHandler
+	class   int
+	timer   *time.Timer
+}
+
+// Dispatcher allocates Invoke IDs and dispatches the received Components to
+// the InvokeHandler registered for the Invoke ID.
+//
+// Dispatcher does not send or receive anything by itself, and is safe for
+// concurrent use.
+type Dispatcher struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	timeouts map[int]time.Duration
+	next     int
+	pending  map[int]*pendingInvoke
+}
+
+// NewDispatcher creates a new Dispatcher.
+//
+// timeout is the time to wait for an Invoke to be completed. If it is zero,
+// OnTimeout is never called.
+func NewDispatcher(timeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		timeout:  timeout,
+		timeouts: map[int]time.Duration{},
+		pending:  map[int]*pendingInvoke{},
+	}
+}
+
+// SetClassTimeout changes the time to wait for the Invokes of the Operation
+// Class given, which are registered after this call, from the one given to
+// NewDispatcher.
+func (d *Dispatcher) SetClassTimeout(class int, timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.timeouts[class] = timeout
+}
+
+// Register allocates an Invoke ID that is not in use, and registers h for it.
+// The Invoke is regarded as OperationClass1.
+//
+// The returned Invoke ID should be used for the Invoke sent right after.
+func (d *Dispatcher) Register(h *InvokeHandler) (int, error) {
+	return d.RegisterWithClass(OperationClass1, h)
+}
+
+// RegisterWithClass is Register for the Invoke of the Operation Class given.
+//
+// The Invoke of OperationClass4 is completed immediately, as no reply is
+// expected; the Invoke ID is allocated but h is never called, so h can be nil.
+// ErrNilInvokeHandler is returned if h is nil for the other classes.
+func (d *Dispatcher) RegisterWithClass(class int, h *InvokeHandler) (int, error) {
+	if h == nil && class != OperationClass4 {
+		return 0, ErrNilInvokeHandler
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := 0; i <= maxInvokeID; i++ {
+		id := (d.next + i) % (maxInvokeID + 1)
+		if _, ok := d.pending[id]; ok {
+			continue
+		}
+		d.next = (id + 1) % (maxInvokeID + 1)
+		if class == OperationClass4 {
+			return id, nil
+		}
+
+		p := &pendingInvoke{handler: h, class: class}
+		timeout := d.timeout
+		if t, ok := d.timeouts[class]; ok {
+			timeout = t
+		}
+		if timeout > 0 {
+			p.timer = time.AfterFunc(timeout, func() { d.expire(id, p) })
+		}
+		d.pending[id] = p
+		return id, nil
+	}
+
+	return 0, ErrNoInvokeIDAvailable
+}
+
+// Cancel releases the Invoke ID without calling any callback.
+func (d *Dispatcher) Cancel(invokeID int) {
+	d.release(invokeID, nil)
+}
+
+// Outstanding returns the number of Invokes waiting to be completed.
+func (d *Dispatcher) Outstanding() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.pending)
+}
+
+// Dispatch calls the callbacks for the ReturnResult, ReturnError and Reject
+// Components in t.
+//
+// The Components that are not dispatched, i.e., Invokes from the peer and the
+// ones with unknown Invoke ID, are returned to be handled by the caller.
+// The ReturnResult and ReturnError not expected for the Operation Class, e.g.,
+// ReturnResult for OperationClass2, are also returned, with the Invoke released,
+// so that the caller can reject them.
+func (d *Dispatcher) Dispatch(t *TCAP) []TypedComponent {
+	var rest []TypedComponent
+	for _, tc := range t.TypedComponents() {
+		if !d.dispatch(tc) {
+			rest = append(rest, tc)
+		}
+	}
+	return rest
+}
+
+func (d *Dispatcher) dispatch(tc TypedComponent) bool {
+	switch c := tc.(type) {
+	case *ReturnResultLastComponent:
+		p := d.release(c.InvokeID, nil)
+		if p == nil || p.class == OperationClass2 {
+			return false
+		}
+		if f := p.handler.OnResult; f != nil {
+			f(c)
+		}
+	case *ReturnResultNotLastComponent:
+		d.mu.Lock()
+		p, ok := d.pending[c.InvokeID]
+		d.mu.Unlock()
+		if !ok {
+			return false
+		}
+		if p.class == OperationClass2 {
+			d.release(c.InvokeID, p)
+			return false
+		}
+		if f := p.handler.OnResult; f != nil {
+			f(c)
+		}
+	case *ReturnErrorComponent:
+		p := d.release(c.InvokeID, nil)
+		if p == nil || p.class == OperationClass3 {
+			return false
+		}
+		if f := p.handler.OnError; f != nil {
+			f(c)
+		}
+	case *RejectComponent:
+		p := d.release(c.InvokeID, nil)
+		if p == nil {
+			return false
+		}
+		if f := p.handler.OnReject; f != nil {
+			f(c)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// release removes the pending Invoke and stops its timer. If want is not nil,
+// it is removed only when it is still the one registered.
+func (d *Dispatcher) release(invokeID int, want *pendingInvoke) *pendingInvoke {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.pending[invokeID]
+	if !ok || (want != nil && p != want) {
+		return nil
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	delete(d.pending, invokeID)
+	return p
+}
+
+func (d *Dispatcher) expire(invokeID int, p *pendingInvoke) {
+	if d.release(invokeID, p) == nil {
+		return
+	}
+	// no response means success for OperationClass2.
+	if p.class == OperationClass2 {
+		return
+	}
+	if f := p.handler.OnTimeout; f != nil {
+		f(invokeID)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := tcap.NewDispatcher(0)
+
+	var results, errs, rejects []int
+	h := &tcap.InvokeHandler{
+		OnResult: func(c tcap.TypedComponent) {
+			switch v := c.(type) {
+			case *tcap.ReturnResultLastComponent:
+				results = append(results, v.InvokeID)
+			case *tcap.ReturnResultNotLastComponent:
+				results = append(results, -v.InvokeID)
+			}
+		},
+		OnError:  func(c *tcap.ReturnErrorComponent) { errs = append(errs, c.InvokeID) },
+		OnReject: func(c *tcap.RejectComponent) { rejects = append(rejects, c.InvokeID) },
+	}
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		id, err := d.Register(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	m := &tcap.TCAP{
+		Transaction: tcap.NewContinue(0x11111111, 0x22222222, nil),
+		Components: tcap.NewComponents(
+			tcap.NewReturnResult(ids[0], 3, true, false, nil),
+			tcap.NewReturnResult(ids[0], 3, true, true, nil),
+			tcap.NewReturnError(ids[1], 34, true, nil),
+			tcap.NewReject(ids[2], tcap.ReturnResultProblem, tcap.ResultProblemMistypedParameter, nil),
+			tcap.NewInvoke(ids[0], -1, 45, true, nil),
+			tcap.NewReturnResult(100, 3, true, true, nil),
+		),
+	}
+	m.SetLength()
+
+	rest := d.Dispatch(m)
+	if got, want := len(rest), 2; got != want {
+		t.Fatalf("got %d undispatched components want %d", got, want)
+	}
+	if _, ok := rest[0].(*tcap.InvokeComponent); !ok {
+		t.Errorf("got %T want *tcap.InvokeComponent", rest[0])
+	}
+	if got, want := results, []int{-ids[0], ids[0]}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("OnResult: got %v want %v", got, want)
+	}
+	if len(errs) != 1 || errs[0] != ids[1] {
+		t.Errorf("OnError: got %v want [%d]", errs, ids[1])
+	}
+	if len(rejects) != 1 || rejects[0] != ids[2] {
+		t.Errorf("OnReject: got %v want [%d]", rejects, ids[2])
+	}
+	if got := d.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding Invokes want 0", got)
+	}
+}
+
+func TestDispatcherTimeout(t *testing.T) {
+	d := tcap.NewDispatcher(10 * time.Millisecond)
+
+	timedOut := make(chan int, 1)
+	id, err := d.Register(&tcap.InvokeHandler{
+		OnResult:  func(c tcap.TypedComponent) { t.Errorf("unexpected result: %v", c) },
+		OnTimeout: func(invokeID int) { timedOut <- invokeID },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-timedOut:
+		if got != id {
+			t.Errorf("got %d want %d", got, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnTimeout was not called")
+	}
+
+	// The result after timeout is not dispatched.
+	m := &tcap.TCAP{
+		Transaction: tcap.NewEnd(0x11111111, nil),
+		Components:  tcap.NewComponents(tcap.NewReturnResult(id, 3, true, true, nil)),
+	}
+	m.SetLength()
+	if got := len(d.Dispatch(m)); got != 1 {
+		t.Errorf("got %d undispatched components want 1", got)
+	}
+}
+
+func TestDispatcherConcurrentRegister(t *testing.T) {
+	d := tcap.NewDispatcher(0)
+
+	var wg sync.WaitGroup
+	ids := make(chan int, 128)
+	for i := 0; i < 128; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := d.Register(&tcap.InvokeHandler{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("Invoke ID %d allocated twice", id)
+		}
+		seen[id] = true
+	}
+
+	if _, err := d.Register(&tcap.InvokeHandler{}); err != tcap.ErrNoInvokeIDAvailable {
+		t.Errorf("got %v want %v", err, tcap.ErrNoInvokeIDAvailable)
+	}
+}
+
+func TestDispatcherOperationClass(t *testing.T) {
+	d := tcap.NewDispatcher(time.Hour)
+	d.SetClassTimeout(tcap.OperationClass2, 10*time.Millisecond)
+	d.SetClassTimeout(tcap.OperationClass3, 10*time.Millisecond)
+
+	fail := &tcap.InvokeHandler{
+		OnResult:  func(c tcap.TypedComponent) { t.Errorf("unexpected result: %v", c) },
+		OnError:   func(c *tcap.ReturnErrorComponent) { t.Errorf("unexpected error: %v", c) },
+		OnTimeout: func(invokeID int) { t.Errorf("unexpected timeout: %d", invokeID) },
+	}
+
+	// class 4 is completed immediately.
+	if _, err := d.RegisterWithClass(tcap.OperationClass4, fail); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding Invokes want 0", got)
+	}
+
+	// class 2 does not expect ReturnResult, and class 3 does not expect ReturnError.
+	id2, err := d.RegisterWithClass(tcap.OperationClass2, fail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id3, err := d.RegisterWithClass(tcap.OperationClass3, fail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &tcap.TCAP{
+		Transaction: tcap.NewContinue(0x11111111, 0x22222222, nil),
+		Components: tcap.NewComponents(
+			tcap.NewReturnResult(id2, 3, true, true, nil),
+			tcap.NewReturnError(id3, 34, true, nil),
+		),
+	}
+	m.SetLength()
+	if got, want := len(d.Dispatch(m)), 2; got != want {
+		t.Errorf("got %d undispatched components want %d", got, want)
+	}
+	if got := d.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding Invokes want 0", got)
+	}
+
+	// no response means success in class 2, and failure in class 3.
+	timedOut := make(chan int, 1)
+	if _, err := d.RegisterWithClass(tcap.OperationClass2, fail); err != nil {
+		t.Fatal(err)
+	}
+	id3, err = d.RegisterWithClass(tcap.OperationClass3, &tcap.InvokeHandler{
+		OnTimeout: func(invokeID int) { timedOut <- invokeID },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-timedOut:
+		if got != id3 {
+			t.Errorf("got %d want %d", got, id3)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnTimeout was not called")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := d.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding Invokes want 0", got)
+	}
+
+	if got, want := tcap.NewInvokeWithClass(id2, -1, 3, true, tcap.OperationClass2, nil).Class, tcap.OperationClass2; got != want {
+		t.Errorf("got Class %d want %d", got, want)
+	}
+}
+
+func TestDispatcherNilHandler(t *testing.T) {
+	d := tcap.NewDispatcher(time.Hour)
+
+	if _, err := d.Register(nil); err != tcap.ErrNilInvokeHandler {
+		t.Errorf("got %v want %v", err, tcap.ErrNilInvokeHandler)
+	}
+	if _, err := d.RegisterWithClass(tcap.OperationClass4, nil); err != nil {
+		t.Errorf("got %v want nil for OperationClass4", err)
+	}
+	if got := d.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding Invokes want 0", got)
+	}
+}
@@ -4,7 +4,27 @@
 
 package tcap
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// Error definitions.
+var (
+	ErrAbortReasonConflict    = errors.New("tcap: Abort has both P-Abort Cause and Dialogue Portion")
+	ErrUnsupportedLength      = errors.New("tcap: indefinite or too long form of Length is not supported")
+	ErrNoInvokeIDAvailable    = errors.New("tcap: all Invoke IDs are in use")
+	ErrInvalidOID             = errors.New("tcap: invalid object identifier")
+	ErrInvalidUserInformation = errors.New("tcap: invalid user-information")
+	ErrTooManyIEs             = errors.New("tcap: too many IEs")
+	ErrInvalidTransactionID   = errors.New("tcap: Transaction ID must be 1 to 4 octets")
+	ErrTruncatedLength        = errors.New("tcap: long form of Length is truncated")
+	ErrComponentsInAbort      = errors.New("tcap: Abort has Component Portion")
+	ErrNoAbortReason          = errors.New("tcap: Abort has neither P-Abort Cause nor Dialogue Portion")
+	ErrNotReplyable           = errors.New("tcap: only Begin and Continue can be replied")
+	ErrTIDMismatch            = errors.New("tcap: Destination Transaction ID does not match the local one")
+	ErrNilInvokeHandler       = errors.New("tcap: InvokeHandler is nil")
+)
 
 // InvalidCodeError indicates that Code in TCAP message is invalid.
 type InvalidCodeError struct {
@@ -15,3 +35,153 @@ type InvalidCodeError struct {
 func (e *InvalidCodeError) Error() string {
 	return fmt.Sprintf("tcap: got invalid code: %d", e.Code)
 }
+
+// InvalidTagError indicates that the class, form or code given to build a Tag
+// is out of range, e.g., the code larger than MaxTagCode.
+type InvalidTagError struct {
+	Class, Form, Code int
+}
+
+// Error returns error message with violating content.
+func (e *InvalidTagError) Error() string {
+	return fmt.Sprintf("tcap: invalid tag: class %d, form %d, code %d (code must be 0-%d)", e.Class, e.Form, e.Code, MaxTagCode)
+}
+
+// InvalidMessageTypeError indicates that the tag of TCAP message is none of
+// Unidirectional, Begin, End, Continue and Abort.
+type InvalidMessageTypeError struct {
+	Tag Tag
+}
+
+// Error returns error message with violating content.
+func (e *InvalidMessageTypeError) Error() string {
+	return fmt.Sprintf("tcap: got invalid message type tag: %#x", uint8(e.Tag))
+}
+
+// UnexpectedComponentTagError indicates that the tag of an element in the
+// Component Portion is none of the Component types, which is reported only with
+// WithStrictComponents.
+type UnexpectedComponentTagError struct {
+	Tag Tag
+}
+
+// Error returns error message with violating content.
+func (e *UnexpectedComponentTagError) Error() string {
+	return fmt.Sprintf("tcap: unexpected component tag 0x%02x in component portion", uint8(e.Tag))
+}
+
+// UnexpectedPortionTagError indicates that an element of the other Tag is found
+// where the portion of Want is expected, e.g., the Component Portion given as
+// the Dialogue Portion.
+type UnexpectedPortionTagError struct {
+	Tag  Tag
+	Want Tag
+}
+
+// Error returns error message with violating content.
+func (e *UnexpectedPortionTagError) Error() string {
+	return fmt.Sprintf("tcap: got tag 0x%02x where portion 0x%02x is expected", uint8(e.Tag), uint8(e.Want))
+}
+
+// InvalidLocalCodeError indicates that the local (INTEGER) Operation Code or
+// Error Code in a Component is too long or empty.
+type InvalidLocalCodeError struct {
+	Component Tag
+	Length    int
+}
+
+// Error returns error message with violating content.
+func (e *InvalidLocalCodeError) Error() string {
+	return fmt.Sprintf("tcap: invalid length of local code in component 0x%02x: %d octets, want 1-%d", uint8(e.Component), e.Length, maxLocalCodeLen)
+}
+
+// DuplicateInvokeIDError indicates that the Invoke IDs are shared by multiple
+// Invokes in the same Component Portion.
+type DuplicateInvokeIDError struct {
+	IDs []int
+}
+
+// Error returns error message with violating content.
+func (e *DuplicateInvokeIDError) Error() string {
+	return fmt.Sprintf("tcap: duplicate invoke IDs in component portion: %v", e.IDs)
+}
+
+// InvalidLengthError indicates that Length in TCAP message does not match
+// the length of its contents.
+type InvalidLengthError struct {
+	Tag      Tag
+	Length   int
+	Consumed int
+}
+
+// Error returns error message with violating content.
+func (e *InvalidLengthError) Error() string {
+	return fmt.Sprintf("tcap: got invalid length in %#x: declared %d, consumed %d", uint8(e.Tag), e.Length, e.Consumed)
+}
+
+// TrailingBytesError indicates that there are unexpected bytes after TCAP message.
+type TrailingBytesError struct {
+	Offset int
+	Length int
+}
+
+// Error returns error message with violating content.
+func (e *TrailingBytesError) Error() string {
+	return fmt.Sprintf("tcap: got %d trailing bytes at offset %d", e.Length, e.Offset)
+}
+
+// TooLongError indicates that TCAP message is too long to be carried.
+type TooLongError struct {
+	Length int
+	Max    int
+}
+
+// Error returns error message with violating content.
+func (e *TooLongError) Error() string {
+	return fmt.Sprintf("tcap: message is too long: %d octets, max %d", e.Length, e.Max)
+}
+
+// InvalidDialogueOIDError indicates that the direct-reference OID in Dialogue
+// Portion does not identify the expected dialogue type. Want is empty if none
+// of the known OIDs is found.
+type InvalidDialogueOIDError struct {
+	OID  string
+	Want string
+}
+
+// Error returns error message with violating content.
+func (e *InvalidDialogueOIDError) Error() string {
+	if e.Want == "" {
+		return fmt.Sprintf("tcap: got unknown direct-reference OID in Dialogue Portion: %q", e.OID)
+	}
+	return fmt.Sprintf("tcap: got direct-reference OID %s in Dialogue Portion, want %s", e.OID, e.Want)
+}
+
+// UnsupportedDialogueTypeError indicates that the dialogue type given to build
+// Dialogue Portion is neither DialogueAsID nor UnidialogueAsID.
+type UnsupportedDialogueTypeError struct {
+	Type uint8
+}
+
+// Error returns error message with violating content.
+func (e *UnsupportedDialogueTypeError) Error() string {
+	return fmt.Sprintf("tcap: unsupported dialogue type: %d, want DialogueAsID(%d) or UnidialogueAsID(%d)", e.Type, DialogueAsID, UnidialogueAsID)
+}
+
+// ParseError indicates that ParseBER failed to parse the message at Offset.
+// Cause is the P-Abort Cause that describes the failure.
+type ParseError struct {
+	Offset int
+	Cause  PAbortCause
+	Err    error
+}
+
+// Error returns error message with violating content.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("tcap: failed to parse message at offset %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 
 	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/gsmmap"
 	"github.com/ishidawataru/sctp"
 	"github.com/wmnsk/go-m3ua"
 	m3params "github.com/wmnsk/go-m3ua/messages/params"
@@ -22,25 +23,35 @@ func main() {
 	var (
 		addr    = flag.String("addr", "127.0.0.2:2905", "Remote IP and Port to connect to.")
 		otid    = flag.Int("otid", 0x11111111, "Originating Transaction ID in uint32.")
-		opcode  = flag.Int("opcode", 3, "Operation Code in int.")
-		payload = flag.String("payload", "040800010121436587f9", "Hex representation of the payload")
+		opcode  = flag.Int("opcode", gsmmap.OpCancelLocation, "Operation Code in int, which is used with -payload.")
+		imsi    = flag.String("imsi", "001010123456789", "IMSI in the argument of cancelLocation.")
+		payload = flag.String("payload", "", "Hex representation of the payload, which overrides -imsi if given")
 	)
 	flag.Parse()
 
-	p, err := hex.DecodeString(*payload)
+	// the message is cancelLocation with the argument built from IMSI by
+	// default, and the Invoke of -opcode with the raw payload otherwise.
+	m, err := gsmmap.NewCancelLocation(uint32(*otid), 0, &gsmmap.CancelLocationArg{IMSI: *imsi})
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *payload != "" {
+		p, err := hex.DecodeString(*payload)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m = tcap.NewBeginInvokeWithDialogue(
+			uint32(*otid),                    // OTID
+			tcap.DialogueAsID,                // DialogueType
+			tcap.LocationCancellationContext, // ACN
+			3,                                // ACN Version
+			0,                                // Invoke Id
+			*opcode,                          // OpCode
+			p,                                // Payload
+		)
+	}
 
-	tcapBytes, err := tcap.NewBeginInvokeWithDialogue(
-		uint32(*otid),                    // OTID
-		tcap.DialogueAsID,                // DialogueType
-		tcap.LocationCancellationContext, // ACN
-		3,                                // ACN Version
-		0,                                // Invoke Id
-		*opcode,                          // OpCode
-		p,                                // Payload
-	).MarshalBinary()
+	tcapBytes, err := m.MarshalBinary()
 	if err != nil {
 		log.Fatal(err)
 	}
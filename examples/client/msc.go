@@ -16,8 +16,6 @@ import (
 	"github.com/wmnsk/go-m3ua"
 	m3params "github.com/wmnsk/go-m3ua/messages/params"
 	"github.com/wmnsk/go-sccp"
-	"github.com/wmnsk/go-sccp/params"
-	"github.com/wmnsk/go-sccp/utils"
 	"github.com/hdddl/go-tcap"
 )
 
@@ -102,19 +100,12 @@ func main() {
 		log.Fatal(err)
 	}
 
-	cdPA, err := utils.StrToSwappedBytes(*cdparty, "0")
+	// HLR is called by MSC/VLR in sendAuthenticationInfo.
+	cdPA, err := tcap.NewPartyAddress(tcap.SSNHLR, *cdparty)
 	if err != nil {
 		log.Fatal(err)
 	}
-	esOfCdPA, esOfCgPA := 0x01, 0x01
-	if len(*cdparty)%2 == 0 {
-		esOfCdPA = 0x02
-	}
-	if len(*cgparty)%2 == 0 {
-		esOfCgPA = 0x02
-	}
-
-	cgPA, err := utils.StrToSwappedBytes(*cgparty, "0")
+	cgPA, err := tcap.NewPartyAddress(tcap.SSNVLR, *cgparty)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -123,16 +114,8 @@ func main() {
 	udt, err := sccp.NewUDT(
 		1,    // Protocol Class
 		true, // Message handling
-		params.NewPartyAddress( // CalledPartyAddress: 1234567890123456
-			0x12, 0, 6, 0x00, // Indicator, SPC, SSN, TT
-			0x01, esOfCdPA, 0x04, // NP, ES, NAI
-			cdPA, // GlobalTitleInformation
-		),
-		params.NewPartyAddress( // CallingPartyAddress: 9876543210
-			0x12, 0, 7, 0x00, // Indicator, SPC, SSN, TT
-			0x01, esOfCgPA, 0x04, // NP, ES, NAI
-			cgPA, // GlobalTitleInformation
-		),
+		cdPA, // CalledPartyAddress
+		cgPA, // CallingPartyAddress
 		tcapBytes,
 	).MarshalBinary()
 	if err != nil {
@@ -0,0 +1,105 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// MaxFrameLen is the maximum length of TCAP that can be framed by WriteFramed.
+const MaxFrameLen = 0xffff
+
+// WriteFramed writes the TCAP to w, prefixed with its length in 2 octets in
+// big-endian. This is meant for carrying TCAP over stream transports such as
+// TCP in tests, without SCCP.
+func WriteFramed(w io.Writer, t *TCAP) error {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(b) > MaxFrameLen {
+		return &TooLongError{Length: len(b), Max: MaxFrameLen}
+	}
+
+	f := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(f[:2], uint16(len(b)))
+	copy(f[2:], b)
+	_, err = w.Write(f)
+	return err
+}
+
+// ReadFramed reads a TCAP written by WriteFramed from r, and parses it with
+// ParseBER. It returns io.EOF only if r has no more frames.
+func ReadFramed(r io.Reader, opts ...ParseOption) (*TCAP, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	parsed, err := ParseBER(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return parsed[0], nil
+}
+
+// Encoder writes TCAPs to an io.Writer, reusing the buffer to marshal them.
+//
+// By default, each TCAP is written as it is, back to back. With SetFraming, it
+// is prefixed with its length in the same way as WriteFramed, so that it can be
+// read by ReadFramed.
+type Encoder struct {
+	w      io.Writer
+	buf    []byte
+	framed bool
+}
+
+// NewEncoder creates a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetFraming turns on or off the length prefix of the TCAPs written by Encode.
+func (e *Encoder) SetFraming(on bool) {
+	e.framed = on
+}
+
+// Encode marshals the TCAP given as t and writes it to the underlying writer.
+func (e *Encoder) Encode(t *TCAP) error {
+	l := t.MarshalLen()
+	offset := 0
+	if e.framed {
+		if l > MaxFrameLen {
+			return &TooLongError{Length: l, Max: MaxFrameLen}
+		}
+		offset = 2
+	}
+
+	if cap(e.buf) < offset+l {
+		e.buf = make([]byte, offset+l)
+	}
+	b := e.buf[:offset+l]
+	if e.framed {
+		binary.BigEndian.PutUint16(b[:2], uint16(l))
+	}
+	if err := t.MarshalTo(b[offset:]); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(b)
+	return err
+}
@@ -0,0 +1,95 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+func TestFramed(t *testing.T) {
+	msgs := []*tcap.TCAP{
+		tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x00}).Message(),
+		tcaptest.End().DTID(0x11111111).ReturnResult(1, 2, []byte{0x30, 0x00}).Message(),
+	}
+
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		if err := tcap.WriteFramed(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := buf.Bytes()[:2], []byte{0x00, byte(msgs[0].MarshalLen())}; !bytes.Equal(got, want) {
+		t.Errorf("got prefix %x want %x", got, want)
+	}
+
+	for _, m := range msgs {
+		got, err := tcap.ReadFramed(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.MessageType() != m.MessageType() || got.OTID() != m.OTID() || got.DTID() != m.DTID() {
+			t.Errorf("got %v want %v", got, m)
+		}
+	}
+	if _, err := tcap.ReadFramed(&buf); err != io.EOF {
+		t.Errorf("got %v want %v", err, io.EOF)
+	}
+
+	if _, err := tcap.ReadFramed(bytes.NewReader([]byte{0x00, 0x10, 0x62})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	msgs := []*tcap.TCAP{
+		tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x00}).Message(),
+		tcaptest.End().DTID(0x11111111).ReturnResult(1, 2, []byte{0x30, 0x00}).Message(),
+	}
+
+	var want bytes.Buffer
+	for _, m := range msgs {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Write(b)
+	}
+
+	var buf bytes.Buffer
+	enc := tcap.NewEncoder(&buf)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Errorf("got %x want %x", buf.Bytes(), want.Bytes())
+	}
+
+	buf.Reset()
+	enc.SetFraming(true)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range msgs {
+		got, err := tcap.ReadFramed(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.MessageType() != m.MessageType() || got.OTID() != m.OTID() || got.DTID() != m.DTID() {
+			t.Errorf("got %v want %v", got, m)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %d bytes left want 0", buf.Len())
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func FuzzParseMultiIEs(f *testing.F) {
+	f.Add([]byte{0x04, 0x01, 0x00, 0x04, 0x01, 0x00})
+	// Length in long form, which used to make the loop advance beyond the
+	// given bytes instead of making progress within them.
+	f.Add(append([]byte{0x04, 0x81}, make([]byte, 0x81)...))
+	f.Add([]byte{0x04, 0x80, 0x00})
+	// empty Values, with which the loop advances by the least of 2 octets.
+	f.Add([]byte{0x05, 0x00, 0x05, 0x00})
+	// Length in long form that fits in the short form, which is valid in BER.
+	f.Add([]byte{0x04, 0x81, 0x01, 0x00, 0x04, 0x01, 0x00})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ies, err := tcap.ParseMultiIEs(b)
+		if err != nil {
+			return
+		}
+
+		// the IEs must cover the given bytes, in whatever form of Length.
+		var n int
+		for _, i := range ies {
+			hdr := 2
+			if b[n+1]&0x80 != 0 {
+				hdr += int(b[n+1] & 0x7f)
+			}
+			n += hdr + len(i.Value)
+		}
+		if n != len(b) {
+			t.Errorf("consumed %d octets of %d", n, len(b))
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+/*
+Package gsmmap provides a few MAP(Mobile Application Part) arguments built on
+top of the TCAP primitives, to show how to layer MAP on package tcap.
+
+	arg := &gsmmap.CancelLocationArg{IMSI: "001010123456789"}
+	m, err := gsmmap.NewCancelLocation(0x11111111, 0, arg)
+
+It is not meant to be a complete MAP implementation.
+*/
+package gsmmap
+
+import (
+	"errors"
+
+	"github.com/hdddl/go-tcap"
+)
+
+// Operation Code definitions.
+const (
+	OpCancelLocation = 3
+)
+
+// Error definitions.
+var (
+	ErrInvalidIMSI = errors.New("gsmmap: IMSI must be 6 to 15 digits")
+	ErrInvalidLMSI = errors.New("gsmmap: LMSI must be 4 octets")
+)
+
+// CancelLocationArg is the argument of cancelLocation, which identifies the
+// subscriber whose location is cancelled by IMSI, optionally with LMSI. It is
+// encoded as Identity, which is the whole argument in version 1 and 2.
+//
+//	Identity ::= CHOICE {
+//		imsi          IMSI,
+//		imsi-WithLMSI IMSI-WithLMSI }
+//
+//	IMSI-WithLMSI ::= SEQUENCE {
+//		imsi IMSI,
+//		lmsi LMSI }
+type CancelLocationArg struct {
+	IMSI string
+	LMSI []byte
+}
+
+// MarshalBinary returns the argument in bytes, which can be given to the
+// constructors of Invoke in package tcap as the payload.
+func (a *CancelLocationArg) MarshalBinary() ([]byte, error) {
+	imsi, err := encodeIMSI(a.IMSI)
+	if err != nil {
+		return nil, err
+	}
+	if a.LMSI == nil {
+		return imsi, nil
+	}
+
+	if len(a.LMSI) != 4 {
+		return nil, ErrInvalidLMSI
+	}
+	lmsi := append([]byte{0x04, 0x04}, a.LMSI...)
+	seq := append([]byte{0x30, uint8(len(imsi) + len(lmsi))}, imsi...)
+	return append(seq, lmsi...), nil
+}
+
+// NewCancelLocation creates a Begin with the Invoke of cancelLocation, with the
+// Dialogue Portion of locationCancellationContext version 3.
+//
+// The Identity of arg is wrapped in the argument of version 3.
+//
+//	CancelLocationArg ::= [3] SEQUENCE {
+//		identity Identity,
+//		... }
+func NewCancelLocation(otid uint32, invID int, arg *CancelLocationArg) (*tcap.TCAP, error) {
+	identity, err := arg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	payload := append([]byte{0xa3, uint8(len(identity))}, identity...)
+
+	return tcap.NewBeginInvokeWithDialogue(
+		otid,
+		tcap.DialogueAsID,
+		tcap.LocationCancellationContext, 3,
+		invID, OpCancelLocation,
+		payload,
+	), nil
+}
+
+// encodeIMSI encodes the IMSI in TBCD as an OCTET STRING.
+func encodeIMSI(imsi string) ([]byte, error) {
+	if len(imsi) < 6 || len(imsi) > 15 {
+		return nil, ErrInvalidIMSI
+	}
+
+	for _, d := range imsi {
+		if d < '0' || d > '9' {
+			return nil, ErrInvalidIMSI
+		}
+	}
+	v := EncodeTBCD(imsi)
+	return append([]byte{0x04, uint8(len(v))}, v...), nil
+}
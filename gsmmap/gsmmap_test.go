@@ -0,0 +1,90 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gsmmap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/gsmmap"
+)
+
+func TestCancelLocationArg(t *testing.T) {
+	cases := []struct {
+		description string
+		arg         *gsmmap.CancelLocationArg
+		want        []byte
+		err         error
+	}{
+		{
+			"IMSI",
+			&gsmmap.CancelLocationArg{IMSI: "001010123456789"},
+			[]byte{0x04, 0x08, 0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87, 0xf9},
+			nil,
+		}, {
+			"IMSI with even digits",
+			&gsmmap.CancelLocationArg{IMSI: "00101012345678"},
+			[]byte{0x04, 0x07, 0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87},
+			nil,
+		}, {
+			"IMSI with LMSI",
+			&gsmmap.CancelLocationArg{IMSI: "001010123456789", LMSI: []byte{0xde, 0xad, 0xbe, 0xef}},
+			[]byte{
+				0x30, 0x10,
+				0x04, 0x08, 0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87, 0xf9,
+				0x04, 0x04, 0xde, 0xad, 0xbe, 0xef,
+			},
+			nil,
+		}, {
+			"invalid IMSI",
+			&gsmmap.CancelLocationArg{IMSI: "00101a"},
+			nil,
+			gsmmap.ErrInvalidIMSI,
+		}, {
+			"invalid LMSI",
+			&gsmmap.CancelLocationArg{IMSI: "001010123456789", LMSI: []byte{0x01}},
+			nil,
+			gsmmap.ErrInvalidLMSI,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := c.arg.MarshalBinary()
+			if err != c.err {
+				t.Fatalf("got error %v want %v", err, c.err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("got %x want %x", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewCancelLocation(t *testing.T) {
+	arg := &gsmmap.CancelLocationArg{IMSI: "001010123456789"}
+	m, err := gsmmap.NewCancelLocation(0x11111111, 0, arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := tcap.ParseBERStrict(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.OperationCodes(), []int{gsmmap.OpCancelLocation}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got OperationCodes %v want %v", got, want)
+	}
+	identity, _ := arg.MarshalBinary()
+	want := append([]byte{0xa3, uint8(len(identity))}, identity...)
+	if got := v.Components.Component[0].ParameterBytes(); !bytes.Equal(got, want) {
+		t.Errorf("got Parameter %x want %x", got, want)
+	}
+}
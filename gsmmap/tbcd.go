@@ -0,0 +1,50 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gsmmap
+
+import "strings"
+
+// tbcdDigits is the characters of TBCD-STRING indexed by their value. The
+// filler 0xf is not included.
+const tbcdDigits = "0123456789*#abc"
+
+// EncodeTBCD encodes the digits given as s in TBCD(Telephony BCD), e.g., the
+// value of IMSI or the address digits of ISDN-AddressString, with the filler
+// 0xf put in the last octet when s is odd length.
+//
+// The characters other than 0-9, *, #, a, b and c are encoded as the filler.
+func EncodeTBCD(s string) []byte {
+	b := make([]byte, (len(s)+1)/2)
+	for i := range b {
+		b[i] = 0xff
+	}
+	for i := 0; i < len(s); i++ {
+		d := uint8(0xf)
+		if n := strings.IndexByte(tbcdDigits, s[i]); n >= 0 {
+			d = uint8(n)
+		}
+		if i%2 == 0 {
+			b[i/2] = b[i/2]&0xf0 | d
+		} else {
+			b[i/2] = b[i/2]&0x0f | d<<4
+		}
+	}
+	return b
+}
+
+// DecodeTBCD decodes the digits encoded in TBCD given as b. It stops at the
+// first filler 0xf, which is usually in the last octet of odd length digits.
+func DecodeTBCD(b []byte) string {
+	s := make([]byte, 0, len(b)*2)
+	for _, o := range b {
+		for _, d := range []uint8{o & 0x0f, o >> 4} {
+			if d == 0xf {
+				return string(s)
+			}
+			s = append(s, tbcdDigits[d])
+		}
+	}
+	return string(s)
+}
@@ -0,0 +1,37 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gsmmap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap/gsmmap"
+)
+
+func TestTBCD(t *testing.T) {
+	cases := []struct {
+		description string
+		digits      string
+		encoded     []byte
+	}{
+		{"even", "819012345678", []byte{0x18, 0x09, 0x21, 0x43, 0x65, 0x87}},
+		{"odd", "001010123456789", []byte{0x00, 0x01, 0x01, 0x21, 0x43, 0x65, 0x87, 0xf9}},
+		{"single digit", "5", []byte{0xf5}},
+		{"special digits", "*#1", []byte{0xba, 0xf1}},
+		{"empty", "", []byte{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			if got := gsmmap.EncodeTBCD(c.digits); !bytes.Equal(got, c.encoded) {
+				t.Errorf("got %x want %x", got, c.encoded)
+			}
+			if got := gsmmap.DecodeTBCD(c.encoded); got != c.digits {
+				t.Errorf("got %s want %s", got, c.digits)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@
 package tcap
 
 import (
+	"bytes"
+	"encoding"
 	"fmt"
 	"io"
 )
@@ -26,9 +28,34 @@ const (
 	Constructor
 )
 
+// MaxTagCode is the largest code that a Tag can have, as only the low-tag-number
+// form of a single octet is supported. The code 31 is reserved in BER for the
+// high-tag-number form, which is followed by the code in the octets after.
+const MaxTagCode = 30
+
 // NewTag creates a new Tag.
+//
+// cls is one of Universal, ApplicationWide, ContextSpecific and Private, form
+// is either Primitive or Constructor, and code must be in 0 to MaxTagCode. The
+// values out of range are logged and masked not to overwrite the other bits,
+// which still makes a Tag other than the one intended; use NewTagChecked to
+// get the error instead.
 func NewTag(cls, form, code int) Tag {
-	return Tag((cls << 6) | (form << 5) | code)
+	t, err := NewTagChecked(cls, form, code)
+	if err != nil {
+		logf("failed to build Tag: %v", err)
+		return Tag((cls&0x3)<<6 | (form&0x1)<<5 | code&0x1f)
+	}
+	return t
+}
+
+// NewTagChecked creates a new Tag in the same way as NewTag, but returns
+// InvalidTagError if any of cls, form and code is out of range.
+func NewTagChecked(cls, form, code int) (Tag, error) {
+	if cls < Universal || cls > Private || form < Primitive || form > Constructor || code < 0 || code > MaxTagCode {
+		return 0, &InvalidTagError{Class: cls, Form: form, Code: code}
+	}
+	return Tag(cls<<6 | form<<5 | code), nil
 }
 
 // NewUniversalPrimitiveTag creates a new NewUniversalPrimitiveTag.
@@ -71,6 +98,32 @@ func NewPrivateConstructorTag(code int) Tag {
 	return NewTag(Private, Constructor, code)
 }
 
+// universalTagNames is the names of the universal Tags.
+var universalTagNames = map[Tag]string{
+	0x01: "BOOLEAN",
+	0x02: "INTEGER",
+	0x03: "BIT STRING",
+	0x04: "OCTET STRING",
+	0x05: "NULL",
+	0x06: "OBJECT IDENTIFIER",
+	0x0a: "ENUMERATED",
+	0x0c: "UTF8String",
+	0x13: "PrintableString",
+	0x16: "IA5String",
+	0x28: "EXTERNAL",
+	0x30: "SEQUENCE",
+	0x31: "SET",
+}
+
+// String returns the name of the Tag if it is a known universal one, e.g.,
+// "SEQUENCE", or the Tag in hex otherwise, e.g., "0xa1".
+func (t Tag) String() string {
+	if name, ok := universalTagNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
 // Class returns the Class retieved from a Tag.
 func (t Tag) Class() int {
 	return int(t) >> 6 & 0x3
@@ -89,7 +142,7 @@ func (t Tag) Code() int {
 // IE is a General Structure of TCAP Information Elements.
 type IE struct {
 	Tag
-	Length uint8
+	Length int
 	Value  []byte
 	IE     []*IE
 }
@@ -105,6 +158,32 @@ func NewIE(tag Tag, value []byte) *IE {
 	return i
 }
 
+// NewIEWithLength creates a new IE with the Length given as length, which is
+// set as it is without being computed from value.
+//
+// This is for crafting the malformed IEs, e.g., the test vectors against the
+// validation of Length in the parser. It produces the invalid encodings on
+// purpose if length does not match the length of value, and the IE should not
+// be modified afterwards by the functions that call SetLength.
+func NewIEWithLength(tag Tag, length int, value []byte) *IE {
+	return &IE{
+		Tag:    tag,
+		Length: length,
+		Value:  value,
+	}
+}
+
+// NewIEFrom creates a new IE with the value given as v marshaled, e.g., the
+// argument of an operation of the upper layer. The error from v is returned as
+// it is.
+func NewIEFrom(tag Tag, v encoding.BinaryMarshaler) (*IE, error) {
+	value, err := v.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return NewIE(tag, value), nil
+}
+
 // MarshalBinary returns the byte sequence generated from a IE instance.
 func (i *IE) MarshalBinary() ([]byte, error) {
 	b := make([]byte, i.MarshalLen())
@@ -116,31 +195,119 @@ func (i *IE) MarshalBinary() ([]byte, error) {
 
 // MarshalTo puts the byte sequence in the byte array given as b.
 func (i *IE) MarshalTo(b []byte) error {
-	if len(b) < 2 {
+	l := i.MarshalLen()
+	if len(b) < l {
 		return io.ErrUnexpectedEOF
 	}
 
 	b[0] = uint8(i.Tag)
-	b[1] = i.Length
-	copy(b[2:i.MarshalLen()], i.Value)
+	if !i.hasChildren() {
+		// the form of Length is determined by the Length, as MarshalLen counts,
+		// which can differ from the length of Value in the IEs crafted by
+		// NewIEWithLength.
+		offset := 1 + putLength(b[1:], i.Length, lengthLen(i.Length))
+		copy(b[offset:l], i.Value)
+		return nil
+	}
+
+	// the children are put instead of the Value, which is stale as any of them
+	// is modified after parsing, and so is the Length.
+	n := i.valueLen()
+	offset := 1 + putLength(b[1:], n, lengthLen(n))
+	for _, c := range i.IE {
+		if err := c.MarshalTo(b[offset:]); err != nil {
+			return err
+		}
+		offset += c.MarshalLen()
+	}
 	return nil
 }
 
+// hasChildren reports whether the IE is constructed and has the child IEs that
+// are not encoded in the Value as they are, which are marshaled in place of the
+// Value. The Value is kept if it holds the children as they were parsed, even
+// with the octets after them or the Lengths not in the minimal form.
+func (i *IE) hasChildren() bool {
+	return i.Form() == Constructor && len(i.IE) != 0 && !i.childrenInValue()
+}
+
+// childrenInValue reports whether the Value begins with the children encoded as
+// they are, i.e., none of them is modified after parsing.
+func (i *IE) childrenInValue() bool {
+	b := i.Value
+	for _, c := range i.IE {
+		n := c.encodedIn(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+// encodedIn returns the number of octets at the beginning of b in which the IE
+// is encoded as it is, or -1 if b does not hold the IE.
+func (i *IE) encodedIn(b []byte) int {
+	if len(b) < 2 || Tag(b[0]) != i.Tag {
+		return -1
+	}
+	offset, length, err := decodeLength(b)
+	if err != nil || offset+length > len(b) || length != i.Length || !bytes.Equal(b[offset:offset+length], i.Value) {
+		return -1
+	}
+	if i.hasChildren() {
+		return -1
+	}
+	return offset + length
+}
+
+// valueLen returns the number of octets of the Value to be marshaled, which is
+// the sum of the serial lengths of the children if the IE has them.
+func (i *IE) valueLen() int {
+	if !i.hasChildren() {
+		return len(i.Value)
+	}
+
+	var n int
+	for _, c := range i.IE {
+		n += c.MarshalLen()
+	}
+	return n
+}
+
+// NewNull creates a new NULL as an IE, which is encoded as 0x05 0x00 and can be
+// used as the Parameter of the operations whose argument is NULL.
+func NewNull() *IE {
+	return NewIE(NewUniversalPrimitiveTag(5), nil)
+}
+
+// DefaultMaxIEs is the maximum number of IEs ParseMultiIEs produces by default.
+const DefaultMaxIEs = 4096
+
 // ParseMultiIEs parses multiple (unspecified number of) IEs to []*IE at a time.
-func ParseMultiIEs(b []byte) ([]*IE, error) {
+//
+// It returns ErrTooManyIEs if b contains more IEs than DefaultMaxIEs, or the
+// number given by WithMaxIEs as opts.
+func ParseMultiIEs(b []byte, opts ...ParseOption) ([]*IE, error) {
+	o := newParseOptions(opts)
+
 	var ies []*IE
-	for {
-		if len(b) == 0 {
-			break
+	for offset := 0; offset < len(b); {
+		if len(ies) == o.maxIEs {
+			return nil, ErrTooManyIEs
 		}
 
-		i, err := ParseIE(b)
+		i, err := ParseIE(b[offset:])
 		if err != nil {
 			return nil, err
 		}
+
+		// advance by the octets actually consumed, which can differ from the
+		// serial length if the Length is not in the minimal form. It is at
+		// least 2 octets of Tag and Length, and at most the rest of b as
+		// ParseIE checks, so the loop always makes progress within b.
 		ies = append(ies, i)
-		b = b[i.MarshalLen():]
-		continue
+		offset += encodedLen(b[offset:], i)
 	}
 	return ies, nil
 }
@@ -157,16 +324,21 @@ func ParseIE(b []byte) (*IE, error) {
 // UnmarshalBinary sets the values retrieved from byte sequence in an IE.
 func (i *IE) UnmarshalBinary(b []byte) error {
 	l := len(b)
-	if l < 3 {
+	if l < 2 {
 		return io.ErrUnexpectedEOF
 	}
 
-	i.Tag = Tag(b[0])
-	i.Length = b[1]
-	if l < 2+int(i.Length) {
+	offset, length, err := decodeLength(b)
+	if err != nil {
+		return err
+	}
+	if l < offset+length {
 		return io.ErrUnexpectedEOF
 	}
-	i.Value = b[2 : 2+int(i.Length)]
+
+	i.Tag = Tag(b[0])
+	i.Length = length
+	i.Value = b[offset : offset+length]
 	return nil
 }
 
@@ -178,6 +350,9 @@ func ParseAsBer(b []byte) ([]*IE, error) {
 }
 
 // ParseAsBER parses given byte sequence as multiple IEs.
+//
+// The IEs are parsed regardless of the Class of Tag, so that the unknown ones,
+// e.g., Private-class vendor extensions, are kept as they are with the Value.
 func ParseAsBER(b []byte) ([]*IE, error) {
 	var ies []*IE
 	for {
@@ -190,21 +365,7 @@ func ParseAsBER(b []byte) ([]*IE, error) {
 			return nil, err
 		}
 		ies = append(ies, i)
-
-		if len(i.IE) == 0 {
-			b = b[i.MarshalLen():]
-			continue
-		}
-
-		if i.IE[0].MarshalLen() < i.MarshalLen()-2 {
-			var l = 2
-			for _, ie := range i.IE {
-				l += ie.MarshalLen()
-			}
-			b = b[l:]
-			continue
-		}
-		b = b[i.MarshalLen():]
+		b = b[encodedLen(b, i):]
 	}
 	return ies, nil
 }
@@ -220,6 +381,33 @@ func ParseIERecursive(b []byte) (*IE, error) {
 
 // ParseRecursive sets the values retrieved from byte sequence in an IE.
 func (i *IE) ParseRecursive(b []byte) error {
+	return i.parseRecursive(b, nil)
+}
+
+// parseRecursive is ParseRecursive with the IEs taken from the Arena given.
+func (i *IE) parseRecursive(b []byte, a *Arena) error {
+	if err := i.parseTLV(b); err != nil {
+		return err
+	}
+
+	if i.Tag.Form() == 1 {
+		x, err := a.parseIEs(i.Value)
+		if err != nil {
+			return nil
+		}
+		if i.IE == nil {
+			i.IE = x
+		} else {
+			i.IE = append(i.IE, x...)
+		}
+	}
+
+	return nil
+}
+
+// parseTLV sets Tag, Length and Value of the IE at the beginning of b, without
+// parsing its children.
+func (i *IE) parseTLV(b []byte) error {
 	l := len(b)
 	if l < 2 {
 		return io.ErrUnexpectedEOF
@@ -227,50 +415,251 @@ func (i *IE) ParseRecursive(b []byte) error {
 	i.Tag = Tag(b[0])
 	if b[1]&0x80 == 0x80 {
 		lenBytes := int(b[1] & 0x7F)
-		for k := lenBytes; k >= 1; k-- {
-			if 1+k >= l {
-				continue
-			}
-			i.Length += (b[1+k] << (8 * (lenBytes - k)))
+		// the indefinite form cannot be taken as empty, as the contents and
+		// the end-of-contents octets would be taken as the next IEs.
+		if lenBytes == 0 {
+			return ErrUnsupportedLength
+		}
+		// the length octets themselves can be truncated.
+		if 2+lenBytes > l {
+			return ErrTruncatedLength
 		}
-		i.Value = b[2+lenBytes : 2+lenBytes+int(i.Length)]
+		if lenBytes > 4 {
+			return ErrUnsupportedLength
+		}
+		i.Length = 0
+		for _, x := range b[2 : 2+lenBytes] {
+			i.Length = i.Length<<8 | int(x)
+		}
+		if 2+lenBytes+i.Length > l {
+			return io.ErrUnexpectedEOF
+		}
+		i.Value = b[2+lenBytes : 2+lenBytes+i.Length]
 	} else {
-		i.Length = b[1]
-		i.Value = b[2 : 2+int(i.Length)]
+		i.Length = int(b[1])
+		if 2+i.Length > l {
+			return io.ErrUnexpectedEOF
+		}
+		i.Value = b[2 : 2+i.Length]
 	}
+	return nil
+}
 
-	if int(i.Length)+2 > len(b) {
-		return nil
+// AsIEs parses the Value of the IE as multiple IEs in the same way as
+// ParseAsBER, which is useful to drill into a BER-encoded Parameter.
+func (i *IE) AsIEs() ([]*IE, error) {
+	return ParseAsBER(i.Value)
+}
+
+// lengthOctets returns the number of octets of Length in the IE starting at
+// the beginning of b.
+func lengthOctets(b []byte) int {
+	if b[1]&0x80 == 0 {
+		return 1
 	}
+	return 1 + int(b[1]&0x7f)
+}
 
-	if i.Tag.Form() == 1 {
-		x, err := ParseAsBER(i.Value)
-		if err != nil {
-			return nil
+// encodedLen returns the number of octets the IE i occupies in b, from which it
+// is parsed. It can differ from MarshalLen when the Length is not minimal.
+func encodedLen(b []byte, i *IE) int {
+	return 1 + lengthOctets(b) + len(i.Value)
+}
+
+// childrenLen returns the number of octets in the Value of i that are parsed
+// as its child IEs.
+func childrenLen(i *IE) int {
+	var n int
+	for _, c := range i.IE {
+		n += encodedLen(i.Value[n:], c)
+	}
+	return n
+}
+
+// Encoding keeps how an IE is encoded in the parsed bytes, so that it can be
+// serialized again in the same form.
+type Encoding struct {
+	ie        *IE
+	lenOctets int
+	children  []*Encoding
+	rest      []byte // octets in Value that are not parsed as children
+}
+
+// newEncoding records the encoding of the IE i parsed from b.
+func newEncoding(b []byte, i *IE) *Encoding {
+	e := &Encoding{ie: i, lenOctets: lengthOctets(b)}
+	var n int
+	for _, c := range i.IE {
+		e.children = append(e.children, newEncoding(i.Value[n:], c))
+		n += encodedLen(i.Value[n:], c)
+	}
+	if len(i.IE) != 0 {
+		e.rest = i.Value[n:]
+	}
+	return e
+}
+
+// appendTo appends the IE to b in the recorded encoding. The Length is computed
+// from the current contents, in the same number of octets as it was parsed if
+// possible.
+func (e *Encoding) appendTo(b []byte) []byte {
+	content := e.ie.Value
+	if len(e.children) != 0 {
+		content = nil
+		for _, c := range e.children {
+			content = c.appendTo(content)
 		}
-		i.IE = append(i.IE, x...)
+		content = append(content, e.rest...)
 	}
 
-	return nil
+	b = append(b, uint8(e.ie.Tag))
+	b = appendLength(b, len(content), e.lenOctets)
+	return append(b, content...)
+}
+
+// forceLongForm makes the Length of the IE and its children encoded in the
+// long form by appendTo.
+func (e *Encoding) forceLongForm() {
+	if e.lenOctets < 2 {
+		e.lenOctets = 2
+	}
+	for _, c := range e.children {
+		c.forceLongForm()
+	}
+}
+
+// minimize makes the Length of the IE and its children encoded in the minimal
+// form by appendTo.
+func (e *Encoding) minimize() {
+	e.lenOctets = 1
+	for _, c := range e.children {
+		c.minimize()
+	}
+}
+
+// appendLength appends the Length octets for the Value of n octets to b, using
+// at least the given number of octets. The short form is used only if octets
+// is less than or equal to one.
+func appendLength(b []byte, n, octets int) []byte {
+	if octets <= 1 && n < 0x80 {
+		return append(b, uint8(n))
+	}
+
+	m := 1
+	for x := n >> 8; x > 0; x >>= 8 {
+		m++
+	}
+	if octets-1 > m {
+		m = octets - 1
+	}
+
+	b = append(b, 0x80|uint8(m))
+	for k := m - 1; k >= 0; k-- {
+		b = append(b, uint8(n>>(8*k)))
+	}
+	return b
+}
+
+// lengthLen returns the number of the Length octets in the minimal form for the
+// Value of n octets.
+func lengthLen(n int) int {
+	if n < 0x80 {
+		return 1
+	}
+
+	m := 1
+	for x := n >> 8; x > 0; x >>= 8 {
+		m++
+	}
+	return 1 + m
 }
 
-// MarshalLen returns the serial length of IE.
+// putLength puts length in b as the Length octets of the number given as
+// octets, which is 1 for the short form, and returns octets.
+func putLength(b []byte, length, octets int) int {
+	if octets <= 1 {
+		b[0] = uint8(length)
+		return 1
+	}
+
+	b[0] = 0x80 | uint8(octets-1)
+	for k := 1; k < octets; k++ {
+		b[k] = uint8(length >> (8 * (octets - 1 - k)))
+	}
+	return octets
+}
+
+// decodeLength decodes the Length field of the IE given as b, and returns
+// the offset where the Value starts and the length of the Value.
+func decodeLength(b []byte) (offset, length int, err error) {
+	if len(b) < 2 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	if b[1]&0x80 == 0 {
+		return 2, int(b[1]), nil
+	}
+
+	n := int(b[1] & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, 0, ErrUnsupportedLength
+	}
+	if len(b) < 2+n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	for _, x := range b[2 : 2+n] {
+		length = length<<8 | int(x)
+	}
+	return 2 + n, length, nil
+}
+
+// decodeDeep decodes the Value of the IEs without children as IEs if possible,
+// and does the same for all the descendants.
+func (i *IE) decodeDeep() {
+	if len(i.IE) == 0 {
+		if ies, ok := parseAllAsBER(i.Value); ok {
+			i.IE = ies
+		}
+	}
+
+	for _, ie := range i.IE {
+		ie.decodeDeep()
+	}
+}
+
+// parseAllAsBER parses given byte sequence as IEs, and returns false if any
+// part of b cannot be parsed.
+func parseAllAsBER(b []byte) ([]*IE, bool) {
+	var ies []*IE
+	for len(b) != 0 {
+		i, err := ParseIERecursive(b)
+		if err != nil {
+			return nil, false
+		}
+		ies = append(ies, i)
+		b = b[encodedLen(b, i):]
+	}
+	return ies, len(ies) != 0
+}
+
+// MarshalLen returns the serial length of IE, which is computed from the
+// children instead of the Value if the IE is constructed and any of them is
+// modified after parsing.
 func (i *IE) MarshalLen() int {
-	if len(i.Value) > 127 {
-		return 3 + len(i.Value)
-	} else {
-		return 2 + len(i.Value)
+	if !i.hasChildren() {
+		return 1 + lengthLen(i.Length) + len(i.Value)
 	}
+	n := i.valueLen()
+	return 1 + lengthLen(n) + n
 }
 
 // SetLength sets the length in Length field.
 func (i *IE) SetLength() {
-	i.Length = uint8(len(i.Value))
+	i.Length = len(i.Value)
 }
 
 // String returns IE in human readable string.
 func (i *IE) String() string {
-	return fmt.Sprintf("{Tag: %#x, Length: %d, Value: %x, IE: %v}",
+	return fmt.Sprintf("{Tag: %v, Length: %d, Value: %x, IE: %v}",
 		i.Tag,
 		i.Length,
 		i.Value,
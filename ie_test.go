@@ -0,0 +1,267 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestParseMultiIEsMaxIEs(t *testing.T) {
+	b := bytes.Repeat([]byte{0x04, 0x01, 0x00}, 4)
+
+	ies, err := tcap.ParseMultiIEs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ies), 4; got != want {
+		t.Errorf("got %d IEs want %d", got, want)
+	}
+
+	if _, err := tcap.ParseMultiIEs(b, tcap.WithMaxIEs(3)); err != tcap.ErrTooManyIEs {
+		t.Errorf("got %v want %v", err, tcap.ErrTooManyIEs)
+	}
+
+	b = bytes.Repeat([]byte{0x04, 0x01, 0x00}, tcap.DefaultMaxIEs+1)
+	if _, err := tcap.ParseMultiIEs(b); err != tcap.ErrTooManyIEs {
+		t.Errorf("got %v want %v", err, tcap.ErrTooManyIEs)
+	}
+}
+
+func TestParseMultiIEsNonMinimalLength(t *testing.T) {
+	b := []byte{0x04, 0x81, 0x01, 0xaa, 0x04, 0x01, 0xbb}
+
+	ies, err := tcap.ParseMultiIEs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ies), 2; got != want {
+		t.Fatalf("got %d IEs want %d", got, want)
+	}
+	if got, want := ies[1].Value, []byte{0xbb}; !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+}
+
+func TestAsIEs(t *testing.T) {
+	// OCTET STRING containing SEQUENCE { INTEGER, OCTET STRING }
+	i := tcap.NewIE(tcap.NewUniversalPrimitiveTag(4), []byte{0x30, 0x06, 0x02, 0x01, 0x05, 0x04, 0x01, 0xff})
+
+	ies, err := i.AsIEs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ies), 1; got != want {
+		t.Fatalf("got %d IEs want %d", got, want)
+	}
+	if got, want := len(ies[0].IE), 2; got != want {
+		t.Fatalf("got %d children want %d", got, want)
+	}
+	if got, want := ies[0].IE[1].Value, []byte{0xff}; !bytes.Equal(got, want) {
+		t.Errorf("got Value %x want %x", got, want)
+	}
+
+	if _, err := tcap.NewIE(tcap.NewUniversalPrimitiveTag(4), []byte{0x30, 0x06, 0x02}).AsIEs(); err == nil {
+		t.Errorf("got no error for truncated Value")
+	}
+}
+
+type testMarshaler struct {
+	b   []byte
+	err error
+}
+
+func (m *testMarshaler) MarshalBinary() ([]byte, error) {
+	return m.b, m.err
+}
+
+func TestNewIEFrom(t *testing.T) {
+	ie, err := tcap.NewIEFrom(tcap.NewUniversalConstructorTag(0x10), &testMarshaler{b: []byte{0x80, 0x01, 0x05}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ie.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x30, 0x03, 0x80, 0x01, 0x05}; !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	wantErr := errors.New("failed to marshal")
+	if _, err := tcap.NewIEFrom(tcap.NewUniversalConstructorTag(0x10), &testMarshaler{err: wantErr}); err != wantErr {
+		t.Errorf("got %v want %v", err, wantErr)
+	}
+}
+
+func TestTruncatedLengthOctets(t *testing.T) {
+	// 2 octets of Length are indicated, but only 1 follows.
+	b := []byte{0x62, 0x82, 0x01}
+
+	_, err := tcap.ParseBER(b)
+	var pe *tcap.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v want *ParseError", err)
+	}
+	if pe.Cause != tcap.BadlyFormattedTransactionPortion {
+		t.Errorf("got Cause %d want %d", pe.Cause, tcap.BadlyFormattedTransactionPortion)
+	}
+	if !errors.Is(err, tcap.ErrTruncatedLength) {
+		t.Errorf("got %v want %v", err, tcap.ErrTruncatedLength)
+	}
+
+	if _, err := tcap.ParseIERecursive([]byte{0x04, 0x82, 0x01}); err != tcap.ErrTruncatedLength {
+		t.Errorf("got %v want %v", err, tcap.ErrTruncatedLength)
+	}
+}
+
+func TestNewIEWithLength(t *testing.T) {
+	// Length says 3, while only 2 octets of Value follow.
+	ie := tcap.NewIEWithLength(tcap.NewUniversalPrimitiveTag(4), 3, []byte{0x01, 0x02})
+	b, err := ie.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x04, 0x03, 0x01, 0x02}; !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	if _, err := tcap.ParseIE(b); err == nil {
+		t.Error("got no error with inconsistent Length")
+	}
+
+	// the Length over 127 is written in the long form, not truncated.
+	ie = tcap.NewIEWithLength(tcap.NewUniversalPrimitiveTag(4), 300, []byte{0x01, 0x02})
+	if got, want := ie.MarshalLen(), 6; got != want {
+		t.Errorf("got MarshalLen %d want %d", got, want)
+	}
+	b, err = ie.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x04, 0x82, 0x01, 0x2c, 0x01, 0x02}; !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+}
+
+func TestNewTagOutOfRange(t *testing.T) {
+	if got, err := tcap.NewTagChecked(tcap.ContextSpecific, tcap.Constructor, tcap.MaxTagCode); err != nil || got != 0xbe {
+		t.Errorf("got %v, %v want 0xbe", got, err)
+	}
+
+	_, err := tcap.NewTagChecked(tcap.ContextSpecific, tcap.Primitive, 40)
+	var te *tcap.InvalidTagError
+	if !errors.As(err, &te) {
+		t.Fatalf("got %v want *InvalidTagError", err)
+	}
+	if te.Code != 40 {
+		t.Errorf("got Code %d want %d", te.Code, 40)
+	}
+	if _, err := tcap.NewTagChecked(4, tcap.Primitive, 0); err == nil {
+		t.Error("got no error with class 4")
+	}
+
+	// the code does not overwrite the class and form.
+	tag := tcap.NewContextSpecificPrimitiveTag(40)
+	if tag.Class() != tcap.ContextSpecific || tag.Form() != tcap.Primitive {
+		t.Errorf("got class %d form %d want %d %d", tag.Class(), tag.Form(), tcap.ContextSpecific, tcap.Primitive)
+	}
+}
+
+func TestMarshalLongFormLength(t *testing.T) {
+	value := make([]byte, 200)
+	for n := range value {
+		value[n] = uint8(n)
+	}
+
+	b, err := tcap.NewIE(tcap.NewUniversalPrimitiveTag(4), value).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b[:3], []byte{0x04, 0x81, 0xc8}; !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+	if !bytes.Equal(b[3:], value) {
+		t.Errorf("got Value %x want %x", b[3:], value)
+	}
+
+	parsed, err := tcap.ParseIERecursive(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := parsed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+}
+
+func TestMarshalChildrenWithTrailingOctets(t *testing.T) {
+	cases := []struct {
+		description string
+		b           []byte
+	}{
+		{"trailing octet", []byte{0x30, 0x04, 0x04, 0x01, 0x01, 0xff}},
+		{"non-minimal Length of child", []byte{0x30, 0x04, 0x04, 0x81, 0x01, 0x01}},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			parsed, err := tcap.ParseIERecursive(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := parsed.MarshalLen(), len(c.b); got != want {
+				t.Errorf("got MarshalLen %d want %d", got, want)
+			}
+			re, err := parsed.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(re, c.b) {
+				t.Errorf("got %x want %x", re, c.b)
+			}
+		})
+	}
+}
+
+func TestMarshalModifiedChild(t *testing.T) {
+	// SEQUENCE { OCTET STRING 01 02, [1] { INTEGER 5 } }
+	b := []byte{0x30, 0x09, 0x04, 0x02, 0x01, 0x02, 0xa1, 0x03, 0x02, 0x01, 0x05}
+	parsed, err := tcap.ParseIERecursive(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, err := parsed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+
+	// the modification to the children is reflected in the Lengths of the
+	// constructed IEs, without SetLength on them.
+	parsed.IE[0].Value = []byte{0x01, 0x02, 0x03}
+	parsed.IE[0].SetLength()
+	parsed.IE[1].IE[0].Value = []byte{0x01, 0x00}
+	parsed.IE[1].IE[0].SetLength()
+	want := []byte{0x30, 0x0b, 0x04, 0x03, 0x01, 0x02, 0x03, 0xa1, 0x04, 0x02, 0x02, 0x01, 0x00}
+	if got := parsed.MarshalLen(); got != len(want) {
+		t.Errorf("got MarshalLen %d want %d", got, len(want))
+	}
+	re, err = parsed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, want) {
+		t.Errorf("got %x want %x", re, want)
+	}
+}
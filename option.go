@@ -0,0 +1,322 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import "encoding/binary"
+
+// ParseOption is an option to change the behavior of ParseBER.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	deepDecode       bool
+	zeroPadding      bool
+	preserveEncoding bool
+	strictComponents bool
+	stopAtParameter  bool
+	maxIEs           int
+	maxMessageSize   int
+	maxComponents    int
+	anomalyHook      func(Anomaly)
+}
+
+func newParseOptions(opts []ParseOption) *parseOptions {
+	o := &parseOptions{
+		maxIEs:         DefaultMaxIEs,
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// exceedsMaxMessageSize reports whether the message of n octets is larger than
+// the limit given by WithMaxMessageSize.
+func (o *parseOptions) exceedsMaxMessageSize(n int) bool {
+	return o.maxMessageSize > 0 && n > o.maxMessageSize
+}
+
+// WithDeepDecode makes ParseBER decode the Parameter in Components further,
+// down to the primitive IEs whose value is also a BER-encoded sequence of IEs.
+//
+// The value of a primitive IE is decoded only when it is consumed entirely as
+// IEs. Note that an arbitrary octet string can still happen to look like BER,
+// so this is meant for inspection rather than for interpreting the upper layer.
+func WithDeepDecode() ParseOption {
+	return func(o *parseOptions) {
+		o.deepDecode = true
+	}
+}
+
+// WithZeroPadding makes ParseBERStrict tolerate the zeros after the message,
+// which is typical when a fixed-size receive buffer is given as it is.
+func WithZeroPadding() ParseOption {
+	return func(o *parseOptions) {
+		o.zeroPadding = true
+	}
+}
+
+// WithPreservedEncoding makes ParseBER and ParseBERStrict keep the original
+// encoding of the message, so that ReMarshal reproduces the same bytes even if
+// the input has non-minimal Lengths. This is useful for transparent relays.
+func WithPreservedEncoding() ParseOption {
+	return func(o *parseOptions) {
+		o.preserveEncoding = true
+	}
+}
+
+// WithStrictComponents makes ParseBER and ParseBERStrict fail with
+// UnexpectedComponentTagError when the Component Portion has an element that is
+// none of Invoke, ReturnResultLast, ReturnError, Reject and ReturnResultNotLast.
+// Without it, such an element is kept as a Component with only Type and Length.
+//
+// It also makes them fail with ErrComponentsInAbort when Abort has the Component
+// Portion, which is otherwise ignored.
+func WithStrictComponents() ParseOption {
+	return func(o *parseOptions) {
+		o.strictComponents = true
+	}
+}
+
+// StopAtComponentParameter makes ParseBER and ParseBERStrict leave the Parameter
+// in Components as it is in Value, without parsing it into the children IEs.
+// This is faster, and avoids the upper layer contents being mistaken for IEs.
+// The children can still be retrieved with Component.Parameters.
+func StopAtComponentParameter() ParseOption {
+	return func(o *parseOptions) {
+		o.stopAtParameter = true
+	}
+}
+
+// WithMaxComponents makes ParseBER and ParseBERStrict decode at most n
+// Components in each message, to bound the work on a busy link, e.g., with
+// StopAtComponentParameter. The rest are skipped without being parsed, and the
+// number of them is recorded in Components.Skipped. The message is still
+// consumed entirely by its outer Length. If n is zero or negative, all the
+// Components are decoded.
+func WithMaxComponents(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxComponents = n
+	}
+}
+
+// limitComponents returns the Components in comps to be decoded, which are the
+// first ones of the number given by WithMaxComponents.
+func (o *parseOptions) limitComponents(comps []*IE) []*IE {
+	if o.maxComponents > 0 && len(comps) > o.maxComponents {
+		return comps[:o.maxComponents]
+	}
+	return comps
+}
+
+// WithMaxIEs changes the maximum number of IEs ParseMultiIEs produces from
+// DefaultMaxIEs to n, to limit the allocation on adversarial input.
+func WithMaxIEs(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxIEs = n
+	}
+}
+
+// DefaultMaxMessageSize is the maximum size of a TCAP message ParseBER and
+// ParseBERStrict accept by default.
+const DefaultMaxMessageSize = 64 * 1024
+
+// WithMaxMessageSize changes the maximum size of a TCAP message, including its
+// Tag and Length, from DefaultMaxMessageSize to n. A message larger than n is
+// rejected with *TooLongError by the outer Length before the contents are read,
+// so that a crafted Length cannot make the parser work on a huge input.
+// If n is zero or negative, the size is not limited.
+func WithMaxMessageSize(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxMessageSize = n
+	}
+}
+
+// WithAnomalyHook makes ParseBER and ParseBERStrict call fn for each of the
+// recoverable oddities found in the input, e.g., non-minimal Lengths, ignored
+// trailing bytes and unknown tags, which are tolerated without failing. This
+// gives the insight into the quality of the peer's traffic, e.g., as metrics.
+//
+// fn is called synchronously in the order the anomalies are found, and is not
+// called for the messages that fail to parse.
+func WithAnomalyHook(fn func(Anomaly)) ParseOption {
+	return func(o *parseOptions) {
+		o.anomalyHook = fn
+	}
+}
+
+// MessageOption is an option to build a TCAP with NewMessage.
+type MessageOption func(*messageOptions)
+
+type messageOptions struct {
+	otid       []byte
+	dtid       []byte
+	cause      *PAbortCause
+	dialogue   *Dialogue
+	components []*Component
+	emptyComps bool
+	abortComps bool
+}
+
+func newMessageOptions(opts []MessageOption) *messageOptions {
+	o := &messageOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithOTID sets the Originating Transaction ID.
+func WithOTID(otid uint32) MessageOption {
+	return func(o *messageOptions) {
+		o.otid = make([]byte, 4)
+		binary.BigEndian.PutUint32(o.otid, otid)
+	}
+}
+
+// WithDTID sets the Destination Transaction ID.
+func WithDTID(dtid uint32) MessageOption {
+	return func(o *messageOptions) {
+		o.dtid = make([]byte, 4)
+		binary.BigEndian.PutUint32(o.dtid, dtid)
+	}
+}
+
+// WithOTIDBytes sets the Originating Transaction ID in bytes as it is, which
+// can be 1 to 4 octets, e.g., to answer the peer that uses a shorter one.
+func WithOTIDBytes(otid []byte) MessageOption {
+	return func(o *messageOptions) {
+		o.otid = otid
+	}
+}
+
+// WithDTIDBytes sets the Destination Transaction ID in bytes as it is, which
+// can be 1 to 4 octets, e.g., the OTID of the peer as it is received.
+func WithDTIDBytes(dtid []byte) MessageOption {
+	return func(o *messageOptions) {
+		o.dtid = dtid
+	}
+}
+
+// WithPAbortCause sets the P-Abort Cause, which is valid only in Abort.
+func WithPAbortCause(cause PAbortCause) MessageOption {
+	return func(o *messageOptions) {
+		o.cause = &cause
+	}
+}
+
+// WithDialogue sets the Dialogue Portion.
+func WithDialogue(d *Dialogue) MessageOption {
+	return func(o *messageOptions) {
+		o.dialogue = d
+	}
+}
+
+// WithComponent adds the Component to the Component Portion. It can be given
+// multiple times, and the Components are put in the same order.
+func WithComponent(c *Component) MessageOption {
+	return func(o *messageOptions) {
+		o.components = append(o.components, c)
+	}
+}
+
+// WithEmptyComponentPortion puts the Component Portion even if no Component is
+// given, i.e., 0x6c 0x00, which is different from the absent Component Portion
+// for some peers.
+func WithEmptyComponentPortion() MessageOption {
+	return func(o *messageOptions) {
+		o.emptyComps = true
+	}
+}
+
+// WithRawComponent adds the pre-encoded Component given as b to the Component
+// Portion, which is put verbatim without being parsed. It can be mixed with
+// WithComponent, and the Components are put in the same order.
+func WithRawComponent(b []byte) MessageOption {
+	return func(o *messageOptions) {
+		o.components = append(o.components, NewRawComponent(b))
+	}
+}
+
+// AllowComponentsInAbort makes NewMessage put the Component Portion in Abort,
+// which is dropped by default as Abort never has it in ITU-T Q.773.
+//
+// This is NON-STANDARD, and only for the interoperability with the peers that
+// expect it. Note that such an Abort is rejected by ParseBER and ParseBERStrict
+// with WithStrictComponents, and the Component Portion is ignored without it.
+func AllowComponentsInAbort() MessageOption {
+	return func(o *messageOptions) {
+		o.abortComps = true
+	}
+}
+
+// MarshalOption is an option to change the behavior of MarshalWith.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	longForm bool
+}
+
+func newMarshalOptions(opts []MarshalOption) *marshalOptions {
+	o := &marshalOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ForceLongFormLength makes MarshalWith encode every Length in the long form,
+// e.g., 0x81 0x05 instead of 0x05, for the interoperability with the peers that
+// expect it. The contents of primitive IEs are left as they are.
+func ForceLongFormLength() MarshalOption {
+	return func(o *marshalOptions) {
+		o.longForm = true
+	}
+}
+
+// DialogueOption is an option to control the optional fields of the Dialogue
+// PDU built by NewAARQWith and NewAAREWith.
+type DialogueOption func(*dialogueOptions)
+
+type dialogueOptions struct {
+	protover   int
+	hasVersion bool
+	userinfo   []*IE
+}
+
+func newDialogueOptions(opts []DialogueOption, hasVersion bool) *dialogueOptions {
+	o := &dialogueOptions{
+		protover:   1,
+		hasVersion: hasVersion,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithProtocolVersion puts the protocol-version of the version given as v.
+func WithProtocolVersion(v int) DialogueOption {
+	return func(o *dialogueOptions) {
+		o.protover = v
+		o.hasVersion = true
+	}
+}
+
+// WithoutProtocolVersion omits the protocol-version.
+func WithoutProtocolVersion() DialogueOption {
+	return func(o *dialogueOptions) {
+		o.hasVersion = false
+	}
+}
+
+// WithUserInformation puts the user-information given as ui, which can be
+// created with NewUserInformation. The EXTERNALs in all of them are put into a
+// single user-information if multiple ones are given.
+func WithUserInformation(ui ...*IE) DialogueOption {
+	return func(o *dialogueOptions) {
+		o.userinfo = ui
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"fmt"
+
+	"github.com/wmnsk/go-sccp/params"
+	"github.com/wmnsk/go-sccp/utils"
+)
+
+// RoutingInfo is the TCAP-relevant part of a SCCP Party Address, i.e., the
+// Global Title digits and the SubSystem Number.
+type RoutingInfo struct {
+	// Digits is the Global Title in digits, or empty if the address has no
+	// Global Title.
+	Digits string
+	// SSN is the SubSystem Number, e.g., SSNHLR, or -1 if the address has no
+	// SubSystem Number.
+	SSN int
+}
+
+// NewRoutingInfo extracts the RoutingInfo from the SCCP Party Address given
+// as p, e.g., CalledPartyAddress of the received UDT.
+//
+// It returns nil if p is nil.
+func NewRoutingInfo(p *params.PartyAddress) *RoutingInfo {
+	if p == nil {
+		return nil
+	}
+
+	r := &RoutingInfo{SSN: -1}
+	if p.HasSSN() {
+		r.SSN = int(p.SubsystemNumber)
+	}
+
+	var odd bool
+	switch p.GTI() {
+	case 0:
+		return r
+	case 1:
+		// the odd/even indicator is in the same octet as the nature of address.
+		odd = p.NatureOfAddressIndicator&0x80 != 0
+	case 2:
+		// no indicator; the filler, if any, is left as it is.
+	default:
+		odd = p.IsOddDigits()
+	}
+	if len(p.GlobalTitleInfo) != 0 {
+		r.Digits = utils.SwappedBytesToStr(p.GlobalTitleInfo, odd)
+	}
+
+	return r
+}
+
+// String returns the RoutingInfo in human readable string, e.g., "819012345678/6".
+func (r *RoutingInfo) String() string {
+	if r == nil {
+		return ""
+	}
+	if r.SSN < 0 {
+		return r.Digits
+	}
+	return fmt.Sprintf("%s/%d", r.Digits, r.SSN)
+}
+
+// ReplyPartyAddresses returns the Called and Calling Party Addresses for the
+// reply to the SCCP message that has cdPA and cgPA given, i.e., swaps them.
+//
+// The returned ones are the copies of the given ones, which can be modified
+// without affecting the received message, e.g.,
+//
+//	cdPA, cgPA := tcap.ReplyPartyAddresses(udt.CalledPartyAddress, udt.CallingPartyAddress)
+//	reply := sccp.NewUDT(1, true, cdPA, cgPA, data)
+func ReplyPartyAddresses(cdPA, cgPA *params.PartyAddress) (replyCdPA, replyCgPA *params.PartyAddress) {
+	return copyPartyAddress(cgPA), copyPartyAddress(cdPA)
+}
+
+func copyPartyAddress(p *params.PartyAddress) *params.PartyAddress {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	if p.GlobalTitleInfo != nil {
+		c.GlobalTitleInfo = make([]byte, len(p.GlobalTitleInfo))
+		copy(c.GlobalTitleInfo, p.GlobalTitleInfo)
+	}
+	return &c
+}
@@ -0,0 +1,83 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/pascaldekloe/goe/verify"
+	"github.com/wmnsk/go-sccp/params"
+)
+
+func TestRoutingInfo(t *testing.T) {
+	cases := []struct {
+		description string
+		digits      string
+		ssn         int
+		want        string
+	}{
+		{"odd digits", "819012345", tcap.SSNHLR, "819012345/6"},
+		{"even digits", "8190123456", tcap.SSNVLR, "8190123456/7"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			pa, err := tcap.NewPartyAddress(c.ssn, c.digits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := pa.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := params.ParsePartyAddress(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := tcap.NewRoutingInfo(parsed)
+			verify.Values(t, "RoutingInfo", r, &tcap.RoutingInfo{Digits: c.digits, SSN: c.ssn})
+			if got := r.String(); got != c.want {
+				t.Errorf("got %s want %s", got, c.want)
+			}
+		})
+	}
+
+	t.Run("no SSN", func(t *testing.T) {
+		// routed on GT without SSN.
+		pa := params.NewPartyAddress(0x10, 0, 0, 0x00, 0x01, 0x01, 0x04, []byte{0x21, 0x03})
+		r := tcap.NewRoutingInfo(pa)
+		verify.Values(t, "RoutingInfo", r, &tcap.RoutingInfo{Digits: "123", SSN: -1})
+	})
+
+	if r := tcap.NewRoutingInfo(nil); r != nil {
+		t.Errorf("got %v want nil", r)
+	}
+}
+
+func TestReplyPartyAddresses(t *testing.T) {
+	cdPA, err := tcap.NewPartyAddress(tcap.SSNHLR, "819012345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cgPA, err := tcap.NewPartyAddress(tcap.SSNVLR, "81908765432")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replyCdPA, replyCgPA := tcap.ReplyPartyAddresses(cdPA, cgPA)
+	verify.Values(t, "CalledPartyAddress", replyCdPA, cgPA)
+	verify.Values(t, "CallingPartyAddress", replyCgPA, cdPA)
+
+	// the reply must not share the Global Title with the received one.
+	replyCdPA.GlobalTitleInfo[0] = 0xff
+	if cgPA.GlobalTitleInfo[0] == 0xff {
+		t.Error("got the Global Title shared with the received CallingPartyAddress")
+	}
+	if got, want := tcap.NewRoutingInfo(replyCgPA).String(), "819012345678/6"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
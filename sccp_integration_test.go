@@ -0,0 +1,84 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+//go:build integration
+// +build integration
+
+package tcap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+	"github.com/wmnsk/go-sccp"
+	"github.com/wmnsk/go-sccp/params"
+)
+
+// TestSCCPRoundTrip carries TCAP in SCCP UDT in the same way as the example
+// client does, to make sure that the Data in UDT is recovered as it is.
+func TestSCCPRoundTrip(t *testing.T) {
+	cases := []struct {
+		description string
+		msg         *tcap.TCAP
+	}{
+		{
+			"Begin - AARQ - Invoke",
+			tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).
+				Invoke(0, 71, []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}).Message(),
+		}, {
+			"Continue - Invoke",
+			tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).Invoke(1, 61, []byte{0x30, 0x00}).Message(),
+		}, {
+			"End - AARE - ReturnResultLast",
+			tcaptest.End().DTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).
+				ReturnResult(0, 71, []byte{0x30, 0x00}).Message(),
+		}, {
+			"Abort - ABRT",
+			tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceUser),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			data, err := c.msg.MarshalForSCCP()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := sccp.NewUDT(
+				1,    // Protocol Class
+				true, // Message handling
+				params.NewPartyAddress(0x12, 0, 6, 0x00, 0x01, 0x02, 0x04, []byte{0x21, 0x43, 0x65, 0x87, 0x09, 0x21, 0x43, 0x65}),
+				params.NewPartyAddress(0x12, 0, 7, 0x00, 0x01, 0x02, 0x04, []byte{0x89, 0x67, 0x45, 0x23, 0x01}),
+				data,
+			).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := sccp.ParseMessage(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			udt, ok := msg.(*sccp.UDT)
+			if !ok {
+				t.Fatalf("got %T want *sccp.UDT", msg)
+			}
+
+			parsed, err := tcap.ParseBERStrict(udt.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsed.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("got %x want %x", got, data)
+			}
+		})
+	}
+}
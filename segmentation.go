@@ -0,0 +1,105 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+// maxSegmentLen is the maximum length of the TCAP generated by SplitResponse,
+// which is limited by MaxUDTDataLen and also by the short form of Length, so
+// that the overhead of each segment is fixed.
+const maxSegmentLen = 2 + 127
+
+// SplitResponse creates the TCAPs to deliver the result of the operation that
+// is too large for a single message, i.e., the Continues with ReturnResultNotLast
+// followed by the End with ReturnResultLast, in the order to be sent.
+//
+// payload is the contents of the result parameter, which is split into the
+// segments put in SEQUENCE in each ReturnResult. Each TCAP fits in maxSize
+// octets, which is capped at the length that SCCP UDT can carry. The Dialogue
+// Portion, if needed, should be added to the first one by the caller.
+//
+// It returns TooLongError if maxSize is too small to carry any of the payload.
+// The results can be reassembled by ResultReassembler on the receiver side.
+func SplitResponse(otid, dtid uint32, invID, opCode int, payload []byte, maxSize int) ([]*TCAP, error) {
+	if maxSize > maxSegmentLen {
+		maxSize = maxSegmentLen
+	}
+
+	// Continue has the largest overhead as it has both OTID and DTID.
+	overhead := newSegment(otid, dtid, invID, opCode, false, []byte{}).MarshalLen()
+	segLen := maxSize - overhead
+	if segLen < 1 {
+		return nil, &TooLongError{Length: overhead + 1, Max: maxSize}
+	}
+
+	var ts []*TCAP
+	for len(payload) > segLen {
+		ts = append(ts, newSegment(otid, dtid, invID, opCode, false, payload[:segLen]))
+		payload = payload[segLen:]
+	}
+	return append(ts, newSegment(otid, dtid, invID, opCode, true, payload)), nil
+}
+
+// newSegment creates a Continue with ReturnResultNotLast, or an End with
+// ReturnResultLast if isLast is true, that has seg as the contents of its
+// Parameter.
+func newSegment(otid, dtid uint32, invID, opCode int, isLast bool, seg []byte) *TCAP {
+	c := NewReturnResult(invID, opCode, true, isLast, nil)
+	c.Parameter = NewIE(TagResultSequence, seg)
+	c.SetLength()
+
+	if isLast {
+		return NewMessage(End, WithDTID(dtid), WithComponent(c))
+	}
+	return NewMessage(Continue, WithOTID(otid), WithDTID(dtid), WithComponent(c))
+}
+
+// ResultReassembler reassembles the results of the operations delivered in
+// multiple ReturnResults, e.g., the ones created by SplitResponse, keyed by
+// the Invoke ID.
+//
+// ResultReassembler is not safe for concurrent use.
+type ResultReassembler struct {
+	results map[uint8][]byte
+}
+
+// NewResultReassembler creates a new ResultReassembler.
+func NewResultReassembler() *ResultReassembler {
+	return &ResultReassembler{
+		results: map[uint8][]byte{},
+	}
+}
+
+// Add adds the Component given as c, and returns the reassembled contents of
+// the result parameter with done=true when c is ReturnResultLast. The segments
+// are concatenated in the order they are added.
+//
+// The Components other than ReturnResult are ignored.
+func (r *ResultReassembler) Add(c *Component) (payload []byte, done bool) {
+	code := c.Type.Code()
+	if code != ReturnResultNotLast && code != ReturnResultLast {
+		return nil, false
+	}
+
+	id := c.InvID()
+	payload = r.results[id]
+	if p := c.Parameter; p != nil {
+		payload = append(payload, p.Value...)
+	}
+
+	if code == ReturnResultNotLast {
+		r.results[id] = payload
+		return nil, false
+	}
+
+	delete(r.results, id)
+	if payload == nil {
+		payload = []byte{}
+	}
+	return payload, true
+}
+
+// Pending returns the number of the results that are not completed yet.
+func (r *ResultReassembler) Pending() int {
+	return len(r.results)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestSplitResponse(t *testing.T) {
+	payload := make([]byte, 600)
+	for i := range payload {
+		payload[i] = uint8(i)
+	}
+
+	msgs, err := tcap.SplitResponse(0x22222222, 0x11111111, 1, 56, payload, tcap.MaxUDTDataLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) < 2 {
+		t.Fatalf("got %d messages want more than 1", len(msgs))
+	}
+
+	r := tcap.NewResultReassembler()
+	for i, m := range msgs {
+		b, err := m.MarshalForSCCP()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		parsed, err := tcap.ParseBERStrict(b)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+
+		isLast := i == len(msgs)-1
+		wantType := tcap.Continue
+		if isLast {
+			wantType = tcap.End
+		}
+		if got := parsed.MessageType(); got != wantType {
+			t.Errorf("#%d: got MessageType %d want %d", i, got, wantType)
+		}
+
+		got, done := r.Add(parsed.Components.Component[0])
+		if done != isLast {
+			t.Fatalf("#%d: got done %v want %v", i, done, isLast)
+		}
+		if done && !bytes.Equal(got, payload) {
+			t.Errorf("got %x want %x", got, payload)
+		}
+	}
+	if n := r.Pending(); n != 0 {
+		t.Errorf("got %d pending want 0", n)
+	}
+
+	t.Run("small", func(t *testing.T) {
+		msgs, err := tcap.SplitResponse(0x22222222, 0x11111111, 1, 56, []byte{0x04, 0x00}, tcap.MaxUDTDataLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || msgs[0].MessageType() != tcap.End {
+			t.Errorf("got %v want a single End", msgs)
+		}
+	})
+
+	t.Run("too small maxSize", func(t *testing.T) {
+		var tle *tcap.TooLongError
+		if _, err := tcap.SplitResponse(0x22222222, 0x11111111, 1, 56, payload, 10); !errors.As(err, &tle) {
+			t.Errorf("got %v want *TooLongError", err)
+		}
+	})
+}
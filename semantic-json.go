@@ -0,0 +1,220 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+type semanticMessage struct {
+	Type        string               `json:"type"`
+	OTID        string               `json:"otid,omitempty"`
+	DTID        string               `json:"dtid,omitempty"`
+	PAbortCause string               `json:"pAbortCause,omitempty"`
+	Dialogue    *semanticDialogue    `json:"dialogue,omitempty"`
+	Components  []*semanticComponent `json:"components,omitempty"`
+}
+
+type semanticDialogue struct {
+	Type        string `json:"type"`
+	ACN         string `json:"acn,omitempty"`
+	Version     *int   `json:"version,omitempty"`
+	AbortSource *int   `json:"abortSource,omitempty"`
+}
+
+type semanticComponent struct {
+	Type            string `json:"type"`
+	ID              *int   `json:"id,omitempty"`
+	LinkedID        *int   `json:"linkedId,omitempty"`
+	Operation       string `json:"operation,omitempty"`
+	OpCode          *int   `json:"opcode,omitempty"`
+	GlobalOpCode    string `json:"globalOpcode,omitempty"`
+	ErrorCode       *int   `json:"errorcode,omitempty"`
+	GlobalErrorCode string `json:"globalErrorcode,omitempty"`
+	Problem         string `json:"problem,omitempty"`
+	Parameter       string `json:"parameter,omitempty"`
+}
+
+// MarshalSemanticJSON returns the TCAP in JSON with the decoded semantics
+// instead of the raw IEs, e.g.,
+//
+//	{"type":"begin","otid":"0x11111111",
+//	 "dialogue":{"type":"aarq","acn":"locationCancellationContext","version":3},
+//	 "components":[{"type":"invoke","id":0,"operation":"cancelLocation","opcode":3,"parameter":"0408..."}]}
+//
+// The ACN and the local Operation Codes are named after MAP where known, only if
+// the ACN is under 0.4.0.0.1.0 of MAP or the Dialogue Portion is absent. The
+// others are given in the raw forms, i.e., the ACN in dot notation and the
+// Operation Code as an integer. The Parameter is given in hex including
+// its Tag and Length.
+func (t *TCAP) MarshalSemanticJSON() ([]byte, error) {
+	m := &semanticMessage{}
+	if ts := t.Transaction; ts != nil {
+		m.Type = strings.ToLower(ts.MessageTypeString())
+		if b := t.OTIDBytes(); b != nil {
+			m.OTID = "0x" + hex.EncodeToString(b)
+		}
+		if b := t.DTIDBytes(); b != nil {
+			m.DTID = "0x" + hex.EncodeToString(b)
+		}
+		m.PAbortCause = ts.AbortCause()
+	}
+
+	// the Operation Codes are MAP ones unless the ACN says otherwise.
+	isMAP := true
+	if d := t.Dialogue; d != nil && d.DialoguePDU != nil {
+		m.Dialogue = newSemanticDialogue(d.DialoguePDU)
+		isMAP = isMAPContext(d.DialoguePDU)
+	}
+
+	if c := t.Components; c != nil {
+		for _, comp := range c.Component {
+			m.Components = append(m.Components, newSemanticComponent(comp, isMAP))
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+func newSemanticDialogue(pdu *DialoguePDU) *semanticDialogue {
+	d := &semanticDialogue{Type: strings.ToLower(pdu.DialogueType())}
+	if src := pdu.AbortSource; src != nil && len(src.Value) != 0 {
+		v := int(src.Value[0])
+		d.AbortSource = &v
+	}
+
+	acn := pdu.ApplicationContextName
+	if acn == nil || len(acn.Value) < 2 {
+		return d
+	}
+	if name := pdu.Context(); name != "" && isMAPContext(pdu) {
+		d.ACN = name
+		v := int(acn.Value[len(acn.Value)-1])
+		d.Version = &v
+	} else if oid, err := decodeOID(acn.Value[2:]); err == nil {
+		d.ACN = oid
+	}
+	return d
+}
+
+func newSemanticComponent(c *Component, isMAP bool) *semanticComponent {
+	s := &semanticComponent{Type: c.ComponentTypeString()}
+	if c.InvokeID != nil {
+		s.ID = intPtr(decodeInvokeIDIE(c.InvokeID))
+	}
+	if c.LinkedID != nil {
+		s.LinkedID = intPtr(decodeIntIE(c.LinkedID))
+	}
+
+	if op := c.OperationCode; op != nil {
+		if code, isLocal := decodeCodeIE(op); isLocal {
+			s.OpCode = intPtr(code)
+			if isMAP {
+				s.Operation = mapOperationNames[code]
+			}
+		} else if oid, err := decodeOID(op.Value); err == nil {
+			s.GlobalOpCode = oid
+		}
+	}
+	if c.ErrorCode != nil {
+		if code, isLocal := decodeCodeIE(c.ErrorCode); isLocal {
+			s.ErrorCode = intPtr(code)
+		} else {
+			s.GlobalErrorCode = c.ErrorCodeOID()
+		}
+	}
+	s.Problem = c.ProblemString()
+
+	if b := c.ParameterBytes(); b != nil {
+		s.Parameter = hex.EncodeToString(b)
+	}
+	return s
+}
+
+// mapACNPrefix is the encoded OID of the MAP application contexts, 0.4.0.0.1.0,
+// which is followed by the context and the version.
+var mapACNPrefix = []byte{0x04, 0x00, 0x00, 0x01, 0x00}
+
+// isMAPContext reports whether the ACN of pdu is a MAP one.
+func isMAPContext(pdu *DialoguePDU) bool {
+	acn := pdu.ApplicationContextName
+	if acn == nil || len(acn.Value) != 2+len(mapACNPrefix)+2 {
+		return false
+	}
+	return bytes.HasPrefix(acn.Value[2:], mapACNPrefix)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// mapOperationNames is the names of MAP operations by the local Operation Code.
+var mapOperationNames = map[int]string{
+	2:  "updateLocation",
+	3:  "cancelLocation",
+	4:  "provideRoamingNumber",
+	5:  "noteSubscriberDataModified",
+	6:  "resumeCallHandling",
+	7:  "insertSubscriberData",
+	8:  "deleteSubscriberData",
+	10: "registerSS",
+	11: "eraseSS",
+	12: "activateSS",
+	13: "deactivateSS",
+	14: "interrogateSS",
+	15: "authenticationFailureReport",
+	17: "registerPassword",
+	18: "getPassword",
+	22: "sendRoutingInfo",
+	23: "updateGprsLocation",
+	24: "sendRoutingInfoForGprs",
+	25: "failureReport",
+	26: "noteMsPresentForGprs",
+	29: "sendEndSignal",
+	33: "processAccessSignalling",
+	34: "forwardAccessSignalling",
+	37: "reset",
+	38: "forwardCheckSS-Indication",
+	43: "checkIMEI",
+	44: "mt-forwardSM",
+	45: "sendRoutingInfoForSM",
+	46: "mo-forwardSM",
+	47: "reportSM-DeliveryStatus",
+	50: "activateTraceMode",
+	51: "deactivateTraceMode",
+	55: "sendIdentification",
+	56: "sendAuthenticationInfo",
+	57: "restoreData",
+	58: "sendIMSI",
+	59: "processUnstructuredSS-Request",
+	60: "unstructuredSS-Request",
+	61: "unstructuredSS-Notify",
+	62: "anyTimeSubscriptionInterrogation",
+	63: "informServiceCentre",
+	64: "alertServiceCentre",
+	65: "anyTimeModification",
+	66: "readyForSM",
+	67: "purgeMS",
+	68: "prepareHandover",
+	69: "prepareSubsequentHandover",
+	70: "provideSubscriberInfo",
+	71: "anyTimeInterrogation",
+	72: "ss-InvocationNotification",
+	73: "setReportingState",
+	74: "statusReport",
+	75: "remoteUserFree",
+	76: "registerCC-Entry",
+	77: "eraseCC-Entry",
+	83: "provideSubscriberLocation",
+	84: "sendGroupCallInfo",
+	85: "sendRoutingInfoForLCS",
+	86: "subscriberLocationReport",
+	87: "ist-Alert",
+	88: "ist-Command",
+	89: "noteMM-Event",
+}
@@ -0,0 +1,101 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+func TestMarshalSemanticJSON(t *testing.T) {
+	cases := []struct {
+		description string
+		b           []byte
+		want        string
+	}{
+		{
+			"Begin with Dialogue",
+			tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationCancellationContext, 3).
+				Invoke(0, 3, []byte{0x04, 0x01, 0x01}).Bytes(),
+			`{"type":"begin","otid":"0x11111111",` +
+				`"dialogue":{"type":"aarq","acn":"locationCancellationContext","version":3},` +
+				`"components":[{"type":"invoke","id":0,"operation":"cancelLocation","opcode":3,"parameter":"040101"}]}`,
+		},
+		{
+			"unknown Operation Code",
+			tcaptest.Continue().OTID(0x11111111).DTID(0x22).ReturnResult(1, 99, []byte{0x04, 0x00}).Bytes(),
+			`{"type":"continue","otid":"0x11111111","dtid":"0x00000022",` +
+				`"components":[{"type":"returnResultLast","id":1,"opcode":99,"parameter":"0400"}]}`,
+		},
+		{
+			"Reject",
+			tcaptest.End().DTID(0x11111111).Reject(1, tcap.InvokeProblem, 1).Bytes(),
+			`{"type":"end","dtid":"0x11111111",` +
+				`"components":[{"type":"reject","id":1,"problem":"invoke-problem: unrecognizedOperation"}]}`,
+		},
+		{
+			"Reject with NULL Invoke ID",
+			tcaptest.End().DTID(0x11111111).Reject(-1, tcap.GeneralProblem, 2).Bytes(),
+			`{"type":"end","dtid":"0x11111111",` +
+				`"components":[{"type":"reject","id":-1,"problem":"general-problem: badlyStructuredComponent"}]}`,
+		},
+		{
+			"P-Abort",
+			tcaptest.Abort().DTID(0x11111111).PAbortCause(tcap.UnrecognizedTransactionID).Bytes(),
+			`{"type":"abort","dtid":"0x11111111","pAbortCause":"UnrecognizedTransactionID"}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			m, err := tcap.Parse(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.MarshalSemanticJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.want {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMarshalSemanticJSONNonMAPContext(t *testing.T) {
+	// CAP v3 gsmSSF-to-gsmSCF, 0.4.0.0.1.21.3.4, whose context and version
+	// collide with roamingNumberEnquiryContext of MAP.
+	pdu := tcap.NewAARQ(1, tcap.RoamingNumberEnquiryContext, 4)
+	pdu.ApplicationContextName.Value = []byte{0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x15, 0x03, 0x04}
+	pdu.SetLength()
+	m := tcap.NewMessage(
+		tcap.Begin,
+		tcap.WithOTID(0x11111111),
+		tcap.WithDialogue(tcap.NewDialogue(tcap.DialogueAsID, 1, pdu, []byte{})),
+		tcap.WithComponent(tcap.NewInvoke(0, -1, 23, true, []byte{0x30, 0x00})),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parsed.MarshalSemanticJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"begin","otid":"0x11111111",` +
+		`"dialogue":{"type":"aarq","acn":"0.4.0.0.1.21.3.4"},` +
+		`"components":[{"type":"invoke","id":0,"opcode":23,"parameter":"3000"}]}`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"github.com/wmnsk/go-sccp/params"
+	"github.com/wmnsk/go-sccp/utils"
+)
+
+// SubSystem Number definitions for the entities that use TCAP, which are
+// allocated in 3GPP TS 23.003.
+const (
+	SSNHLR    int = 6
+	SSNVLR    int = 7
+	SSNMSC    int = 8
+	SSNEIR    int = 9
+	SSNAuC    int = 10
+	SSNGMLC   int = 145
+	SSNCAP    int = 146
+	SSNgsmSCF int = 147
+	SSNSIWF   int = 148
+	SSNSGSN   int = 149
+	SSNGGSN   int = 150
+)
+
+// NewPartyAddress creates a new SCCP Party Address that routes on the Global
+// Title given as digits, with the SubSystem Number given as ssn, e.g., SSNHLR.
+//
+// The Global Title is of the type with Translation Type, Numbering Plan,
+// Encoding Scheme and Nature of Address Indicator, in which the numbering plan
+// is ISDN/telephony(E.164) and the nature of address is international number.
+func NewPartyAddress(ssn int, digits string) (*params.PartyAddress, error) {
+	gt, err := utils.StrToSwappedBytes(digits, "0")
+	if err != nil {
+		return nil, err
+	}
+
+	es := 0x01 // BCD, odd number of digits
+	if len(digits)%2 == 0 {
+		es = 0x02 // BCD, even number of digits
+	}
+
+	return params.NewPartyAddress(
+		0x12, 0, ssn, 0x00, // Indicator, SPC, SSN, TT
+		0x01, es, 0x04, // NP, ES, NAI
+		gt, // GlobalTitleInformation
+	), nil
+}
@@ -12,7 +12,11 @@ package tcap
 
 import (
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 )
 
 // TCAP represents a General Structure of TCAP Information Elements.
@@ -20,99 +24,374 @@ type TCAP struct {
 	Transaction *Transaction
 	Dialogue    *Dialogue
 	Components  *Components
+
+	// Encoding is the original encoding of the message kept by ParseBER with
+	// WithPreservedEncoding, which is used by ReMarshal. It is nil otherwise.
+	Encoding *Encoding
 }
 
-// NewBeginInvoke creates a new TCAP of type Transaction=Begin, Component=Invoke.
-func NewBeginInvoke(otid uint32, invID, opCode int, payload []byte) *TCAP {
+// NewMessage creates a new TCAP of the Message Type given as mtype, e.g., Begin,
+// with the portions given as opts.
+//
+// The fields not given are left absent. It is up to the caller to give the ones
+// required for the Message Type, e.g., WithOTID for Begin.
+func NewMessage(mtype int, opts ...MessageOption) *TCAP {
+	o := newMessageOptions(opts)
+
+	tx, err := NewTransactionWithTIDs(mtype, o.otid, o.dtid)
+	if err != nil {
+		logf("failed to build Transaction Portion, building it anyway: %v", err)
+		tx = newTransaction(mtype, o.otid, o.dtid)
+	}
+	if o.cause != nil {
+		tx.PAbortCause = NewIE(NewApplicationWidePrimitiveTag(10), []byte{uint8(*o.cause)})
+	}
+
 	t := &TCAP{
-		Transaction: NewBegin(otid, []byte{}),
-		Components:  NewComponents(NewInvoke(invID, -1, opCode, true, payload)),
+		Transaction: tx,
+		Dialogue:    o.dialogue,
+	}
+	hasComps := len(o.components) != 0 || o.emptyComps
+	if hasComps && mtype == Abort && !o.abortComps {
+		logf("dropping Component Portion in Abort: use AllowComponentsInAbort to put it")
+		hasComps = false
+	}
+	if hasComps {
+		t.Components = NewComponents(o.components...)
 	}
 	t.SetLength()
 
 	return t
 }
 
+// NewUnidirectionalInvoke creates a new TCAP of type Transaction=Unidirectional, Component=Invoke.
+func NewUnidirectionalInvoke(invID, opCode int, payload []byte) *TCAP {
+	return NewMessage(
+		Unidirectional,
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
+// NewUnidirectionalInvokeWithDialogue creates a new TCAP of type Transaction=Unidirectional, Component=Invoke with Dialogue Portion.
+//
+// The Dialogue Portion is always DialogueUnstructured, as required for
+// Unidirectional. dlgType is kept for compatibility and ignored.
+func NewUnidirectionalInvokeWithDialogue(dlgType, ctx, ctxver uint8, invID, opCode int, payload []byte) *TCAP {
+	return NewMessage(
+		Unidirectional,
+		WithDialogue(NewDialogue(DialogueUnstructured, 1, NewAARQ(1, ctx, ctxver), []byte{})),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
+// NewBeginInvoke creates a new TCAP of type Transaction=Begin, Component=Invoke.
+func NewBeginInvoke(otid uint32, invID, opCode int, payload []byte) *TCAP {
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
 // NewBeginInvokeWithDialogue creates a new TCAP of type Transaction=Begin, Component=Invoke with Dialogue Portion.
 func NewBeginInvokeWithDialogue(otid uint32, dlgType, ctx, ctxver uint8, invID, opCode int, payload []byte) *TCAP {
-	t := NewBeginInvoke(otid, invID, opCode, payload)
-	t.Dialogue = NewDialogue(dlgType, 1, NewAARQ(1, ctx, ctxver), []byte{})
-	t.SetLength()
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARQ(1, ctx, ctxver), []byte{})),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
 
-	return t
+// NewBeginReturnResult creates a new TCAP of type Transaction=Begin, Component=ReturnResult.
+func NewBeginReturnResult(otid uint32, invID, opCode int, isLast bool, payload []byte) *TCAP {
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithComponent(NewReturnResult(invID, opCode, true, isLast, payload)),
+	)
+}
+
+// NewBeginReturnError creates a new TCAP of type Transaction=Begin, Component=ReturnError,
+// e.g., to reject an operation the peer announced in advance.
+func NewBeginReturnError(otid uint32, invID, errCode int, isLocal bool, param []byte) *TCAP {
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithComponent(NewReturnError(invID, errCode, isLocal, param)),
+	)
+}
+
+// NewBeginReturnErrorWithDialogue creates a new TCAP of type Transaction=Begin, Component=ReturnError with Dialogue Portion.
+func NewBeginReturnErrorWithDialogue(otid uint32, dlgType, ctx, ctxver uint8, invID, errCode int, isLocal bool, param []byte) *TCAP {
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARQ(1, ctx, ctxver), []byte{})),
+		WithComponent(NewReturnError(invID, errCode, isLocal, param)),
+	)
+}
+
+// NewBeginReject creates a new TCAP of type Transaction=Begin, Component=Reject.
+func NewBeginReject(otid uint32, invID, problemType int, problemCode uint8) *TCAP {
+	return NewMessage(
+		Begin,
+		WithOTID(otid),
+		WithComponent(NewReject(invID, problemType, problemCode, nil)),
+	)
 }
 
 // NewContinueInvoke creates a new TCAP of type Transaction=Continue, Component=Invoke.
 func NewContinueInvoke(otid, dtid uint32, invID, opCode int, payload []byte) *TCAP {
-	t := &TCAP{
-		Transaction: NewContinue(otid, dtid, []byte{}),
-		Components:  NewComponents(NewInvoke(invID, -1, opCode, true, payload)),
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
+func NewContinueInvokeWithDialogue(otid, dtid uint32, invID, opCode int, dlgType, ctx, ctxver uint8, payload []byte) *TCAP {
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, 0, 1, 0), []byte{})),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
+// NewContinueReturnError creates a new TCAP of type Transaction=Continue, Component=ReturnError.
+func NewContinueReturnError(otid, dtid uint32, invID, errCode int, isLocal bool, param []byte) *TCAP {
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithComponent(NewReturnError(invID, errCode, isLocal, param)),
+	)
+}
+
+// NewContinueReturnErrorWithDialogue creates a new TCAP of type Transaction=Continue, Component=ReturnError with Dialogue Portion.
+func NewContinueReturnErrorWithDialogue(otid, dtid uint32, dlgType, ctx, ctxver uint8, invID, errCode int, isLocal bool, param []byte) *TCAP {
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, Accepted, DialogueServiceUser, Null), []byte{})),
+		WithComponent(NewReturnError(invID, errCode, isLocal, param)),
+	)
+}
+
+// NewContinueReject creates a new TCAP of type Transaction=Continue, Component=Reject.
+func NewContinueReject(otid, dtid uint32, invID, problemType int, problemCode uint8) *TCAP {
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithComponent(NewReject(invID, problemType, problemCode, nil)),
+	)
+}
+
+// NewContinueEmpty creates a new TCAP of type Transaction=Continue with the
+// Dialogue given and no Component Portion, which is used to complete the
+// dialogue establishment before any operation.
+func NewContinueEmpty(otid, dtid uint32, dialogue *Dialogue) *TCAP {
+	return NewMessage(
+		Continue,
+		WithOTID(otid),
+		WithDTID(dtid),
+		WithDialogue(dialogue),
+	)
+}
+
+// ReplyContinue creates a new TCAP of type Transaction=Continue in reply to the
+// Begin or Continue received, with the Components given as comps.
+//
+// otid is the local Transaction ID of the dialogue, which is allocated on
+// receiving the Begin. The OTID of the received message becomes the DTID of
+// the reply, while the DTID of the received Continue must be otid, otherwise
+// ErrTIDMismatch is returned. ErrNotReplyable is returned for the other
+// Message Types.
+//
+// The reply to the Begin with AARQ has AARE that accepts the Application
+// Context Name requested. The reply to Continue has no Dialogue Portion, as
+// the dialogue is already established.
+func ReplyContinue(received *TCAP, otid uint32, comps ...*Component) (*TCAP, error) {
+	if received.MessageType() == Continue && received.DTID() != otid {
+		return nil, ErrTIDMismatch
+	}
+	opts, err := replyOptions(received, true)
+	if err != nil {
+		return nil, err
 	}
-	t.SetLength()
 
-	return t
+	opts = append(opts, WithOTID(otid))
+	for _, c := range comps {
+		opts = append(opts, WithComponent(c))
+	}
+	return NewMessage(Continue, opts...), nil
 }
 
-func NewContinueInvokeWithDialogue(otid, dtid uint32, invID, opCode int, dlgType, ctx, ctxver uint8, payload []byte) *TCAP {
-	t := &TCAP{
-		Transaction: NewContinue(otid, dtid, []byte{}),
-		Components:  NewComponents(NewInvoke(invID, -1, opCode, true, payload)),
+// ReplyEnd creates a new TCAP of type Transaction=End in reply to the Begin or
+// Continue received, with the Components given as comps.
+//
+// The OTID of the received message becomes the DTID of the reply, and the
+// reply to the Begin with AARQ has AARE as ReplyContinue does. ErrNotReplyable
+// is returned for the other Message Types.
+func ReplyEnd(received *TCAP, comps ...*Component) (*TCAP, error) {
+	opts, err := replyOptions(received, true)
+	if err != nil {
+		return nil, err
 	}
-	t.Dialogue = NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, 0, 1, 0), []byte{})
 
-	t.SetLength()
+	for _, c := range comps {
+		opts = append(opts, WithComponent(c))
+	}
+	return NewMessage(End, opts...), nil
+}
 
-	return t
+// ReplyAbort creates a new TCAP of type Transaction=Abort with the P-Abort
+// Cause given, e.g., ResourceLimitation, in reply to the Begin or Continue
+// received.
+//
+// The OTID of the received message becomes the DTID of the reply.
+// ErrNotReplyable is returned for the other Message Types.
+func ReplyAbort(received *TCAP, cause PAbortCause) (*TCAP, error) {
+	opts, err := replyOptions(received, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(Abort, append(opts, WithPAbortCause(cause))...), nil
 }
 
-// NewEndInvokeWithDialogue create a new TCAP of type Transaction=End, Component=Invoke
-func NewEndInvokeWithDialogue(dtid uint32, invID, opCode int, dlgType, ctx, ctxver uint8, payload []byte) *TCAP {
-	t := &TCAP{
-		Transaction: NewEnd(dtid, []byte{}),
-		Components:  NewComponents(NewInvoke(invID, -1, opCode, true, payload)),
+// replyOptions returns the MessageOptions common to the replies to the message
+// received, i.e., the DTID, and the Dialogue with AARE if withAARE is true and
+// the received one is the Begin with AARQ.
+func replyOptions(received *TCAP, withAARE bool) ([]MessageOption, error) {
+	mtype := received.MessageType()
+	if mtype != Begin && mtype != Continue {
+		return nil, ErrNotReplyable
+	}
+	dtid := received.OTIDBytes()
+	if dtid == nil {
+		return nil, ErrInvalidTransactionID
 	}
-	t.Dialogue = NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, 0, 1, 0), []byte{})
 
-	t.SetLength()
+	opts := []MessageOption{WithDTIDBytes(dtid)}
+	if withAARE && mtype == Begin {
+		if d := received.Dialogue; d != nil && d.DialoguePDU != nil && d.DialoguePDU.Type.Code() == AARQ {
+			opts = append(opts, WithDialogue(newAcceptingDialogue(d.DialoguePDU)))
+		}
+	}
+	return opts, nil
+}
 
-	return t
+// newAcceptingDialogue creates the Dialogue with AARE that accepts the AARQ
+// given, i.e., that has the same Application Context Name.
+func newAcceptingDialogue(aarq *DialoguePDU) *Dialogue {
+	aare := NewAARE(1, 0, 0, Accepted, DialogueServiceUser, Null)
+	if acn := aarq.ApplicationContextName; acn != nil {
+		aare.ApplicationContextName = NewIE(acn.Tag, append([]byte{}, acn.Value...))
+		aare.SetLength()
+	}
+
+	return NewDialogue(DialogueAsID, 1, aare, []byte{})
+}
+
+// NewEndInvokeWithDialogue create a new TCAP of type Transaction=End, Component=Invoke
+func NewEndInvokeWithDialogue(dtid uint32, invID, opCode int, dlgType, ctx, ctxver uint8, payload []byte) *TCAP {
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, 0, 1, 0), []byte{})),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
 }
 
 // NewEndReturnResult creates a new TCAP of type Transaction=End, Component=ReturnResult.
 func NewEndReturnResult(dtid uint32, invID, opCode int, isLast bool, payload []byte) *TCAP {
-	t := &TCAP{
-		Transaction: NewEnd(dtid, []byte{}),
-		Components:  NewComponents(NewReturnResult(invID, opCode, true, isLast, payload)),
-	}
-	t.SetLength()
-
-	return t
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithComponent(NewReturnResult(invID, opCode, true, isLast, payload)),
+	)
 }
 
 func NewEndReturnError(dtid uint32, invId, errCode int, isLocal bool, param []byte) *TCAP {
-	t := &TCAP{
-		Transaction: NewEnd(dtid, []byte{}),
-		Components:  NewComponents(NewReturnError(invId, errCode, isLocal, param)),
-	}
-	t.SetLength()
-
-	return t
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithComponent(NewReturnError(invId, errCode, isLocal, param)),
+	)
 }
 
 func NewEndReturnErrorWithDialogue(dtid uint32, dlgType, ctx, ctxver uint8, invId, errCode int, isLocal bool, param []byte) *TCAP {
-	t := NewEndReturnError(dtid, invId, errCode, isLocal, param)
-	t.Dialogue = NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, Accepted, DialogueServiceUser, Null), []byte{})
-	t.SetLength()
-	return t
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, Accepted, DialogueServiceUser, Null), []byte{})),
+		WithComponent(NewReturnError(invId, errCode, isLocal, param)),
+	)
 }
 
 // NewEndReturnResultWithDialogue creates a new TCAP of type Transaction=End, Component=ReturnResult with Dialogue Portion.
 func NewEndReturnResultWithDialogue(dtid uint32, dlgType, ctx, ctxver uint8, invID, opCode int, isLast bool, payload []byte) *TCAP {
-	t := NewEndReturnResult(dtid, invID, opCode, isLast, payload)
-	t.Dialogue = NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, Accepted, DialogueServiceUser, Null), []byte{})
-	t.SetLength()
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(dlgType, 1, NewAARE(1, ctx, ctxver, Accepted, DialogueServiceUser, Null), []byte{})),
+		WithComponent(NewReturnResult(invID, opCode, true, isLast, payload)),
+	)
+}
 
-	return t
+// NewEndInvoke creates a new TCAP of type Transaction=End, Component=Invoke.
+func NewEndInvoke(dtid uint32, invID, opCode int, payload []byte) *TCAP {
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithComponent(NewInvoke(invID, -1, opCode, true, payload)),
+	)
+}
+
+// NewEndReject creates a new TCAP of type Transaction=End, Component=Reject.
+func NewEndReject(dtid uint32, invID, problemType int, problemCode uint8) *TCAP {
+	return NewMessage(
+		End,
+		WithDTID(dtid),
+		WithComponent(NewReject(invID, problemType, problemCode, nil)),
+	)
+}
+
+// NewUAbort creates a new TCAP of type Transaction=Abort with ABRT in Dialogue
+// Portion, i.e., U-Abort. src is either AbortDialogueServiceUser or
+// AbortDialogueServiceProvider, and userinfo is the user-information to explain
+// the reason, which can be created with NewUserInformation.
+func NewUAbort(dtid uint32, src AbortSource, userinfo ...*IE) *TCAP {
+	return NewMessage(
+		Abort,
+		WithDTID(dtid),
+		WithDialogue(NewDialogue(DialogueAsID, 1, NewABRT(uint8(src), userinfo...), []byte{})),
+	)
+}
+
+// AbortFromParseError creates a new TCAP of type Transaction=Abort, to reject
+// the message that ParseBER failed to parse with err.
+//
+// The P-Abort Cause is taken from err if it is *ParseError, otherwise it is
+// BadlyFormattedTransactionPortion.
+func AbortFromParseError(dtid uint32, err error) *TCAP {
+	cause := BadlyFormattedTransactionPortion
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		cause = pe.Cause
+	}
+
+	return NewMessage(
+		Abort,
+		WithDTID(dtid),
+		WithPAbortCause(cause),
+	)
 }
 
 // MarshalBinary returns the byte sequence generated from a TCAP instance.
@@ -124,6 +403,94 @@ func (t *TCAP) MarshalBinary() ([]byte, error) {
 	return b, nil
 }
 
+// MarshalWith returns the byte sequence generated from a TCAP instance, with
+// the encoding changed by MarshalOption(s) given as opts.
+func (t *TCAP) MarshalWith(opts ...MarshalOption) ([]byte, error) {
+	o := newMarshalOptions(opts)
+
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !o.longForm {
+		return b, nil
+	}
+
+	tx, err := ParseIERecursive(b)
+	if err != nil {
+		return nil, err
+	}
+	e := newEncoding(b, tx)
+	e.forceLongForm()
+	return e.appendTo(nil), nil
+}
+
+// ReMarshal returns the byte sequence of a TCAP parsed with WithPreservedEncoding
+// in its original encoding, e.g., the form of Length octets, so that the result
+// is identical to the input as long as nothing is modified.
+//
+// The Value of the parsed IEs modified in place is reflected, with the Lengths
+// recomputed in the original form. Otherwise, it is the same as MarshalBinary.
+func (t *TCAP) ReMarshal() ([]byte, error) {
+	if t.Encoding == nil {
+		return t.MarshalBinary()
+	}
+	return t.Encoding.appendTo(nil), nil
+}
+
+// MarshalCanonical returns the byte sequence of a TCAP instance with all the
+// Lengths encoded in the minimal form, so that the messages different only in
+// the form of Lengths, e.g., a retransmission by another node, result in the
+// same bytes. The TCAP parsed with WithPreservedEncoding is canonicalized from
+// its original encoding.
+func (t *TCAP) MarshalCanonical() ([]byte, error) {
+	b, err := t.ReMarshal()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := ParseIERecursive(b)
+	if err != nil {
+		return nil, err
+	}
+	e := newEncoding(b, tx)
+	e.minimize()
+	return e.appendTo(nil), nil
+}
+
+// Fingerprint returns the 64-bit FNV-1a hash of the bytes from MarshalCanonical,
+// which can be used to detect the duplicated messages, e.g., by the redundant
+// network paths.
+func (t *TCAP) Fingerprint() (uint64, error) {
+	b, err := t.MarshalCanonical()
+	if err != nil {
+		return 0, err
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64(), nil
+}
+
+// MaxUDTDataLen is the maximum length of Data that SCCP UDT can carry, which is
+// limited by its one-octet length indicator.
+//
+// Note that the actual limit can be smaller, as the whole SCCP message including
+// Called/Calling Party Addresses must fit in the MTP3 SIF (272 octets).
+const MaxUDTDataLen = 255
+
+// MarshalForSCCP returns the byte sequence generated from a TCAP instance, or
+// TooLongError if it does not fit in the Data of SCCP UDT (class 0/1).
+//
+// When it fails, the message should be sent in XUDT, or the Components should
+// be split into multiple messages.
+func (t *TCAP) MarshalForSCCP() ([]byte, error) {
+	if l := t.MarshalLen(); l > MaxUDTDataLen {
+		return nil, &TooLongError{Length: l, Max: MaxUDTDataLen}
+	}
+	return t.MarshalBinary()
+}
+
 // MarshalTo puts the byte sequence in the byte array given as b.
 func (t *TCAP) MarshalTo(b []byte) error {
 	var offset = 0
@@ -172,73 +539,595 @@ func (t *TCAP) UnmarshalBinary(b []byte) error {
 		return nil
 	}
 
-	switch t.Transaction.Payload[0] {
-	case 0x6b:
-		t.Dialogue, err = ParseDialogue(t.Transaction.Payload)
+	if t.Transaction.Type.Code() == Abort {
+		return t.unmarshalAbortReason()
+	}
+
+	// the portions are located by their Tags, not by their order.
+	payload := t.Transaction.Payload
+	for len(payload) != 0 {
+		switch Tag(payload[0]) {
+		case TagDialoguePortion:
+			t.Dialogue, err = ParseDialogue(payload)
+			if err != nil {
+				return err
+			}
+			if err := t.verifyDialogueType(); err != nil {
+				return err
+			}
+			payload = t.Dialogue.Payload
+		case TagComponentPortion:
+			n, length, err := decodeLength(payload)
+			if err != nil {
+				return err
+			}
+			if n+length > len(payload) {
+				return io.ErrUnexpectedEOF
+			}
+			t.Components, err = ParseComponents(payload[:n+length])
+			if err != nil {
+				return err
+			}
+			payload = payload[n+length:]
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// unmarshalAbortReason sets the reason of Abort, which is either P-Abort Cause
+// or Dialogue Portion(U-Abort). Abort never has Component Portion.
+func (t *TCAP) unmarshalAbortReason() error {
+	if Tag(t.Transaction.Payload[0]) != TagDialoguePortion {
+		return nil
+	}
+	if t.Transaction.PAbortCause != nil {
+		return ErrAbortReasonConflict
+	}
+
+	var err error
+	t.Dialogue, err = ParseDialogue(t.Transaction.Payload)
+	return err
+}
+
+// ParseBer parses given byte sequence as a TCAP.
+//
+// Deprecated: use ParseBER instead.
+func ParseBer(b []byte) ([]*TCAP, error) {
+	return ParseBER(b)
+}
+
+// ParseBER parses given byte sequence as a TCAP.
+//
+// Each message is parsed up to its outer Length, and the zeros after the
+// messages are ignored, e.g., the rest of the receive buffer given as it is.
+//
+// The behavior can be changed with ParseOption(s) given as opts.
+// The error returned is *ParseError, which can be turned into P-Abort with
+// AbortFromParseError.
+func ParseBER(b []byte, opts ...ParseOption) ([]*TCAP, error) {
+	o := newParseOptions(opts)
+
+	var tcaps []*TCAP
+	var offset int
+	for len(b)-offset >= 2 {
+		// no message starts with zero, which is the padding.
+		if offset != 0 && isAllZeros(b[offset:]) {
+			break
+		}
+		if tag := Tag(b[offset]); !isMessageType(tag) {
+			return nil, &ParseError{Offset: offset, Cause: UnrecognizedMessageType, Err: &InvalidMessageTypeError{Tag: tag}}
+		}
+		if n, length, err := decodeLength(b[offset:]); err == nil && o.exceedsMaxMessageSize(n+length) {
+			return nil, &ParseError{Offset: offset, Cause: ResourceLimitation, Err: &TooLongError{Length: n + length, Max: o.maxMessageSize}}
+		}
+		tx, err := parseMessageIE(b[offset:], o, nil)
+		if err != nil {
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: err}
+		}
+
+		t, err := newTCAPFromBER(tx, o, nil)
+		if err != nil {
+			return nil, &ParseError{Offset: offset, Cause: IncorrectTransactionPortion, Err: err}
+		}
+		if o.preserveEncoding {
+			t.Encoding = newEncoding(b[offset:], tx)
+		}
+		o.reportAnomalies(b[offset:], offset, tx)
+		tcaps = append(tcaps, t)
+		offset += encodedLen(b[offset:], tx)
+	}
+
+	if offset < len(b) && o.anomalyHook != nil {
+		o.anomalyHook(Anomaly{
+			Kind: AnomalyTrailingBytes, Offset: offset,
+			Detail: fmt.Sprintf("%d octets after messages", len(b)-offset),
+		})
+	}
+	return tcaps, nil
+}
+
+// TIDPair is the Message Type and the Transaction IDs of a TCAP found by
+// ScanTransactionIDs.
+type TIDPair struct {
+	// Offset is the position of the TCAP in the byte sequence scanned.
+	Offset int
+	// Type is the Message Type, e.g., Begin.
+	Type int
+	// OTID and DTID refer to the byte sequence scanned, and are nil if absent.
+	OTID []byte
+	DTID []byte
+}
+
+// ScanTransactionIDs walks the TCAP messages concatenated back to back in the
+// same way as ParseBERAll, and collects the Message Type and the TIDs of each
+// message without parsing the Dialogue and Component Portions.
+//
+// This is meant for indexing a large capture quickly, e.g., to group the
+// messages by TIDs before parsing the interesting ones. The error returned is
+// *ParseError.
+func ScanTransactionIDs(b []byte) ([]TIDPair, error) {
+	var pairs []TIDPair
+	for offset := 0; offset < len(b); {
+		if tag := Tag(b[offset]); !isMessageType(tag) {
+			return nil, &ParseError{Offset: offset, Cause: UnrecognizedMessageType, Err: &InvalidMessageTypeError{Tag: tag}}
+		}
+		n, length, err := decodeLength(b[offset:])
 		if err != nil {
-			return err
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: err}
+		}
+		end := offset + n + length
+		if end > len(b) {
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: io.ErrUnexpectedEOF}
+		}
+
+		p := TIDPair{Offset: offset, Type: Tag(b[offset]).Code()}
+		if err := p.scanTIDs(b[offset+n : end]); err != nil {
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: err}
 		}
-		if len(t.Dialogue.Payload) == 0 {
+		pairs = append(pairs, p)
+		offset = end
+	}
+
+	return pairs, nil
+}
+
+// scanTIDs finds the TIDs at the beginning of the contents of a TCAP, stopping
+// at the first IE other than TIDs.
+func (p *TIDPair) scanTIDs(b []byte) error {
+	for len(b) != 0 {
+		var field *[]byte
+		switch Tag(b[0]) {
+		case TagOriginatingTID:
+			field = &p.OTID
+		case TagDestinationTID:
+			field = &p.DTID
+		}
+		if field == nil {
 			return nil
 		}
 
-		t.Components, err = ParseComponents(t.Dialogue.Payload)
+		n, length, err := decodeLength(b)
 		if err != nil {
 			return err
 		}
-	case 0x6c:
-		t.Components, err = ParseComponents(t.Transaction.Payload)
+		if n+length > len(b) {
+			return io.ErrUnexpectedEOF
+		}
+		*field = b[n : n+length]
+		b = b[n+length:]
+	}
+	return nil
+}
+
+// ParseBERAll parses given byte sequence as TCAP messages concatenated back to
+// back, e.g., the payloads of multiple SCCP messages in a capture, advancing by
+// the outer Length of each message.
+//
+// Unlike ParseBER, each message is checked as ParseBERStrict does, and the bytes
+// left that do not form a message are treated as error, unless they are all
+// zeros and WithZeroPadding is given as opts. The error returned is *ParseError.
+func ParseBERAll(b []byte, opts ...ParseOption) ([]*TCAP, error) {
+	o := newParseOptions(opts)
+
+	var tcaps []*TCAP
+	for offset := 0; offset < len(b); {
+		if o.zeroPadding && isAllZeros(b[offset:]) {
+			break
+		}
+
+		n, length, err := decodeLength(b[offset:])
 		if err != nil {
-			return err
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: err}
 		}
+		end := offset + n + length
+		if o.exceedsMaxMessageSize(n + length) {
+			return nil, &ParseError{Offset: offset, Cause: ResourceLimitation, Err: &TooLongError{Length: n + length, Max: o.maxMessageSize}}
+		}
+		if end > len(b) {
+			return nil, &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: io.ErrUnexpectedEOF}
+		}
+
+		t, err := ParseBERStrict(b[offset:end], opts...)
+		if err != nil {
+			cause := BadlyFormattedTransactionPortion
+			var mt *InvalidMessageTypeError
+			if errors.As(err, &mt) {
+				cause = UnrecognizedMessageType
+			}
+			return nil, &ParseError{Offset: offset, Cause: cause, Err: err}
+		}
+		tcaps = append(tcaps, t)
+		offset = end
 	}
 
-	return nil
+	return tcaps, nil
 }
 
-// ParseBer parses given byte sequence as a TCAP.
+// ParseBERStrict parses given byte sequence as a single TCAP, and returns error
+// if the Length of the message does not match the bytes consumed by its contents.
 //
-// Deprecated: use ParseBER instead.
-func ParseBer(b []byte) ([]*TCAP, error) {
-	return ParseBER(b)
+// Any bytes after the message are also treated as error, unless they are all
+// zeros and WithZeroPadding is given as opts. So is an Abort that has neither
+// P-Abort Cause nor Dialogue Portion, which ParseBER accepts.
+func ParseBERStrict(b []byte, opts ...ParseOption) (*TCAP, error) {
+	return parseBERStrict(b, newParseOptions(opts), nil)
 }
 
-// ParseBER parses given byte sequence as a TCAP.
-func ParseBER(b []byte) ([]*TCAP, error) {
-	parsed, err := ParseAsBER(b)
+// parseBERStrict is ParseBERStrict with the structures taken from the Arena
+// given as a, which can be nil.
+func parseBERStrict(b []byte, o *parseOptions, a *Arena) (*TCAP, error) {
+	if len(b) != 0 && !isMessageType(Tag(b[0])) {
+		return nil, &InvalidMessageTypeError{Tag: Tag(b[0])}
+	}
+	offset, length, err := decodeLength(b)
 	if err != nil {
 		return nil, err
 	}
+	end := offset + length
+	if o.exceedsMaxMessageSize(end) {
+		return nil, &TooLongError{Length: end, Max: o.maxMessageSize}
+	}
+	if end > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
 
-	tcaps := make([]*TCAP, len(parsed))
-	for i, tx := range parsed {
-		t := &TCAP{
-			Transaction: &Transaction{},
+	if rest := b[end:]; len(rest) != 0 {
+		if !o.zeroPadding || !isAllZeros(rest) {
+			return nil, &TrailingBytesError{Offset: end, Length: len(rest)}
 		}
+	}
+
+	tx, err := parseMessageIE(b[:end], o, a)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := childrenLen(tx)
+	if consumed != length {
+		return nil, &InvalidLengthError{Tag: tx.Tag, Length: length, Consumed: consumed}
+	}
 
-		if err := t.Transaction.SetValsFrom(tx); err != nil {
-			return nil, err
+	t, err := newTCAPFromBER(tx, o, a)
+	if err != nil {
+		return nil, err
+	}
+	// the reason is OPTIONAL in the ASN.1, but an Abort without it tells
+	// nothing about why the dialogue is aborted.
+	if t.MessageType() == Abort && t.Transaction.PAbortCause == nil && t.Dialogue == nil {
+		return nil, ErrNoAbortReason
+	}
+	if o.preserveEncoding {
+		t.Encoding = newEncoding(b, tx)
+	}
+	o.reportAnomalies(b, 0, tx)
+	if end < len(b) && o.anomalyHook != nil {
+		o.anomalyHook(Anomaly{
+			Kind: AnomalyTrailingBytes, Offset: end,
+			Detail: fmt.Sprintf("%d octets of zero padding", len(b)-end),
+		})
+	}
+	return t, nil
+}
+
+// ParseBERPartial parses given byte sequence as a single TCAP up to the first
+// problem, and returns the TCAP with what is decoded before it together with
+// *ParseError, whose Offset points to the element the parse stopped at.
+//
+// The portions and the Components are taken in order as long as they are
+// framed correctly and accepted as ParseBER does, and the rest is dropped. The
+// bytes after the message are treated as error unless they are all zeros. The
+// TCAP is nil only if not even the Message Type can be decoded. This is meant
+// for inspecting a suspect message, e.g., in debugging.
+func ParseBERPartial(b []byte, opts ...ParseOption) (*TCAP, error) {
+	o := newParseOptions(opts)
+
+	if len(b) != 0 && !isMessageType(Tag(b[0])) {
+		return nil, &ParseError{Cause: UnrecognizedMessageType, Err: &InvalidMessageTypeError{Tag: Tag(b[0])}}
+	}
+	hdr, length, err := decodeLength(b)
+	if err != nil {
+		return nil, &ParseError{Cause: BadlyFormattedTransactionPortion, Err: err}
+	}
+	if o.exceedsMaxMessageSize(hdr + length) {
+		return nil, &ParseError{Cause: ResourceLimitation, Err: &TooLongError{Length: hdr + length, Max: o.maxMessageSize}}
+	}
+
+	// the truncated message is taken up to the end of b, so that the portion
+	// cut off is reported.
+	end := hdr + length
+	if end > len(b) {
+		end = len(b)
+	}
+
+	var stop *ParseError
+	var portions []partialPortion
+	for offset := hdr; offset < end; {
+		n, l, err := decodeLength(b[offset:end])
+		if err != nil {
+			stop = &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: err}
+			break
+		}
+		next := offset + n + l
+		truncated := next > end
+		if truncated {
+			next = end
 		}
 
-		for _, dx := range tx.IE {
-			switch dx.Tag {
-			case 0x6b:
-				t.Dialogue = &Dialogue{}
-				if err := t.Dialogue.SetValsFrom(dx); err != nil {
-					return nil, err
+		// the Components before the one cut off are still taken.
+		p := partialPortion{offset: offset, b: b[offset:next]}
+		if Tag(p.b[0]) == TagComponentPortion {
+			p.b, stop = framedComponents(p.b, offset)
+		} else if truncated {
+			stop = &ParseError{Offset: offset, Cause: BadlyFormattedTransactionPortion, Err: io.ErrUnexpectedEOF}
+			break
+		}
+		if stop == nil && truncated {
+			stop = &ParseError{Offset: end, Cause: BadlyFormattedTransactionPortion, Err: io.ErrUnexpectedEOF}
+		}
+		portions = append(portions, p)
+		if stop != nil {
+			break
+		}
+		offset = next
+	}
+	if rest := b[end:]; stop == nil && !isAllZeros(rest) {
+		stop = &ParseError{Offset: end, Cause: BadlyFormattedTransactionPortion, Err: &TrailingBytesError{Offset: end, Length: len(rest)}}
+	}
+
+	// the portions that are not accepted are dropped from the last, so that
+	// the first one of them is reported.
+	for {
+		t, err := newPartialTCAP(b[0], portions, o)
+		if err == nil {
+			if stop != nil {
+				return t, stop
+			}
+			return t, nil
+		}
+		if len(portions) == 0 {
+			return nil, &ParseError{Cause: IncorrectTransactionPortion, Err: err}
+		}
+
+		last := portions[len(portions)-1]
+		stop = &ParseError{Offset: last.offset, Cause: IncorrectTransactionPortion, Err: err}
+		portions = portions[:len(portions)-1]
+	}
+}
+
+// partialPortion is a portion found by ParseBERPartial, with its offset from
+// the beginning of the message.
+type partialPortion struct {
+	offset int
+	b      []byte
+}
+
+// framedComponents returns the Component Portion given as b, which starts at
+// offset and can be truncated, with only the Components before the first one
+// not framed correctly, and the error at that Component if any.
+func framedComponents(b []byte, offset int) ([]byte, *ParseError) {
+	hdr := 1 + lengthOctets(b)
+	n := hdr
+	var stop *ParseError
+	for n < len(b) {
+		h, l, err := decodeLength(b[n:])
+		if err == nil && n+h+l > len(b) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			stop = &ParseError{Offset: offset + n, Cause: BadlyFormattedTransactionPortion, Err: err}
+			break
+		}
+		n += h + l
+	}
+
+	framed := appendLength([]byte{b[0]}, n-hdr, 1)
+	return append(framed, b[hdr:n]...), stop
+}
+
+// newPartialTCAP creates a TCAP of the Message Type given as tag, which
+// consists of the portions given.
+func newPartialTCAP(tag uint8, portions []partialPortion, o *parseOptions) (*TCAP, error) {
+	var value []byte
+	for _, p := range portions {
+		value = append(value, p.b...)
+	}
+	b := append(appendLength([]byte{tag}, len(value), 1), value...)
+
+	tx, err := parseMessageIE(b, o, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newTCAPFromBER(tx, o, nil)
+}
+
+// parseMessageIE parses the TCAP given as b into IEs in the same way as
+// ParseIERecursive, except that the Parameters in Component Portion are left
+// unparsed with StopAtComponentParameter, and that the Components after the
+// number given by WithMaxComponents are left unparsed.
+func parseMessageIE(b []byte, o *parseOptions, a *Arena) (*IE, error) {
+	if !o.stopAtParameter && o.maxComponents <= 0 {
+		return a.parseIE(b)
+	}
+
+	// the errors in children are ignored as ParseIERecursive does.
+	tx := a.newIE()
+	if err := tx.parseTLV(b); err != nil {
+		return nil, err
+	}
+	tx.IE, _ = a.parseTLVs(tx.Value)
+	for _, portion := range tx.IE {
+		switch {
+		case portion.Tag == TagComponentPortion:
+			portion.IE, _ = a.parseTLVs(portion.Value)
+			for _, comp := range o.limitComponents(portion.IE) {
+				if !o.stopAtParameter {
+					comp.IE, _ = a.parseIEs(comp.Value)
+					continue
+				}
+				comp.IE, _ = a.parseTLVs(comp.Value)
+				if comp.Tag != TagReturnResultLast && comp.Tag != TagReturnResultNotLast {
+					continue
+				}
+				for _, x := range comp.IE {
+					if x.Tag == TagResultSequence {
+						x.IE, _ = a.parseTLVs(x.Value)
+					}
+				}
+			}
+		case portion.Tag.Form() == Constructor:
+			portion.IE, _ = a.parseIEs(portion.Value)
+		}
+	}
+	return tx, nil
+}
+
+// newTCAPFromBER creates a TCAP from the IE of a whole message parsed by ParseAsBER,
+// with the structures taken from the Arena given as a, which can be nil.
+func newTCAPFromBER(tx *IE, o *parseOptions, a *Arena) (*TCAP, error) {
+	m := a.newMessage()
+	t := &m.tcap
+	t.Transaction = &m.transaction
+
+	if err := t.Transaction.SetValsFrom(tx); err != nil {
+		return nil, err
+	}
+
+	isAbort := t.Transaction.Type.Code() == Abort
+	for _, dx := range tx.IE {
+		switch dx.Tag {
+		case TagDialoguePortion:
+			if isAbort && t.Transaction.PAbortCause != nil {
+				return nil, ErrAbortReasonConflict
+			}
+			t.Dialogue = &m.dialogue
+			if err := t.Dialogue.setValsFrom(dx, &m.pdu); err != nil {
+				return nil, err
+			}
+			if err := t.verifyDialogueType(); err != nil {
+				return nil, err
+			}
+		case TagComponentPortion:
+			// Abort never has Component Portion.
+			if isAbort {
+				if o.strictComponents {
+					return nil, ErrComponentsInAbort
 				}
-			case 0x6c:
-				t.Components = &Components{}
-				if err := t.Components.SetValsFrom(dx); err != nil {
+				continue
+			}
+			if o.strictComponents {
+				for _, cx := range dx.IE {
+					if !isComponentType(cx.Tag) {
+						return nil, &UnexpectedComponentTagError{Tag: cx.Tag}
+					}
+				}
+			}
+			// the errors in the children are ignored in parsing, but the
+			// Components must be framed correctly not to lose any of them.
+			if childrenLen(dx) != len(dx.Value) {
+				if err := checkFraming(dx.Value); err != nil {
 					return nil, err
 				}
 			}
+			t.Components = &m.components
+			if comps := o.limitComponents(dx.IE); len(comps) != len(dx.IE) {
+				t.Components.Skipped = len(dx.IE) - len(comps)
+				limited := *dx
+				limited.IE = comps
+				dx = &limited
+			}
+			if err := t.Components.setValsFrom(dx, a); err != nil {
+				return nil, err
+			}
+			if o.deepDecode {
+				for _, comp := range t.Components.Component {
+					if comp.Parameter != nil {
+						comp.Parameter.decodeDeep()
+					}
+				}
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// checkFraming returns the error in the Tags and Lengths of the IEs
+// concatenated in b, e.g., ErrUnsupportedLength for the indefinite form.
+func checkFraming(b []byte) error {
+	for len(b) != 0 {
+		n, length, err := decodeLength(b)
+		if err != nil {
+			return err
 		}
+		if n+length > len(b) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[n+length:]
+	}
+	return nil
+}
 
-		tcaps[i] = t
+// verifyDialogueType checks that the Dialogue Portion is of the dialogue type
+// expected for the Message Type, i.e., UnidialogueAsID for Unidirectional and
+// DialogueAsID for the others.
+func (t *TCAP) verifyDialogueType() error {
+	typ, err := t.Dialogue.DialogueType()
+	if err != nil {
+		return err
 	}
 
-	return tcaps, nil
+	want := DialogueAsID
+	if t.MessageType() == Unidirectional {
+		want = UnidialogueAsID
+	}
+	if typ != want {
+		return &InvalidDialogueOIDError{OID: DialogueOID(typ), Want: DialogueOID(want)}
+	}
+	return nil
+}
+
+// isMessageType reports whether the tag is the one of TCAP messages.
+func isMessageType(tag Tag) bool {
+	if tag.Class() != ApplicationWide || tag.Form() != Constructor {
+		return false
+	}
+	switch tag.Code() {
+	case Unidirectional, Begin, End, Continue, Abort:
+		return true
+	}
+	return false
+}
+
+func isAllZeros(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // MarshalLen returns the serial length of TCAP.
@@ -267,34 +1156,308 @@ func (t *TCAP) SetLength() {
 	if portion := t.Transaction; portion != nil {
 		portion.SetLength()
 		if c := t.Components; c != nil {
-			portion.Length += uint8(c.MarshalLen())
+			portion.Length += c.MarshalLen()
 		}
 		if d := t.Dialogue; d != nil {
-			portion.Length += uint8(d.MarshalLen())
+			portion.Length += d.MarshalLen()
 		}
 	}
 }
 
+// MessageType returns the Message Type of TCAP, e.g., Unidirectional or Begin,
+// or 0 if the Transaction Portion is absent.
+func (t *TCAP) MessageType() int {
+	if ts := t.Transaction; ts != nil {
+		return ts.Type.Code()
+	}
+
+	return 0
+}
+
+// HasDialogue reports whether TCAP has the Dialogue Portion. In Abort, it is
+// true only for U-Abort with the Dialogue Portion, not for P-Abort.
+func (t *TCAP) HasDialogue() bool {
+	return t.Dialogue != nil
+}
+
+// HasComponents reports whether TCAP has at least one Component, which is
+// always false for Abort.
+func (t *TCAP) HasComponents() bool {
+	return t.Components != nil && len(t.Components.Component) != 0
+}
+
+// ComponentPortionPresent reports whether TCAP has the Component Portion, which
+// is true even if it has no Component, i.e., 0x6c 0x00. Use HasComponents to
+// know whether it has any Component.
+func (t *TCAP) ComponentPortionPresent() bool {
+	return t.Components != nil
+}
+
+// CheckInvokeIDs checks the Invoke IDs in Component Portion as
+// Components.CheckInvokeIDs does. It returns nil if TCAP has no Components.
+func (t *TCAP) CheckInvokeIDs() error {
+	if t.Components == nil {
+		return nil
+	}
+	return t.Components.CheckInvokeIDs()
+}
+
+// AbortSource returns the abort-source in ABRT of U-Abort, which is either
+// AbortDialogueServiceUser or AbortDialogueServiceProvider.
+// It returns -1 if TCAP is not an Abort with ABRT, e.g., P-Abort.
+func (t *TCAP) AbortSource() AbortSource {
+	pdu := t.abrt()
+	if pdu == nil || pdu.AbortSource == nil || len(pdu.AbortSource.Value) == 0 {
+		return -1
+	}
+
+	return AbortSource(pdu.AbortSource.Value[0])
+}
+
+// PAbortCause returns the P-Abort Cause of P-Abort, e.g., ResourceLimitation,
+// which is carried in the Transaction Portion with Tag 0x4a. ok is false if
+// TCAP is not an Abort with it, e.g., U-Abort.
+func (t *TCAP) PAbortCause() (cause PAbortCause, ok bool) {
+	ts := t.Transaction
+	if ts == nil || ts.Type.Code() != Abort {
+		return 0, false
+	}
+	if c := ts.PAbortCause; c != nil && len(c.Value) != 0 {
+		return PAbortCause(c.Value[0]), true
+	}
+
+	return 0, false
+}
+
+// DialogueResult returns the result of AARE in the Dialogue Portion, i.e.,
+// either Accepted or RejectPerm. ok is false if it is not available.
+func (t *TCAP) DialogueResult() (result uint8, ok bool) {
+	if d := t.Dialogue; d != nil && d.DialoguePDU != nil {
+		return d.DialoguePDU.DialogueResult()
+	}
+
+	return 0, false
+}
+
+// DialogueDiagnostic returns the source and the reason in the
+// result-source-diagnostic of AARE in the Dialogue Portion.
+// See DialoguePDU.DialogueDiagnostic for details.
+func (t *TCAP) DialogueDiagnostic() (source int, reason uint8, ok bool) {
+	if d := t.Dialogue; d != nil && d.DialoguePDU != nil {
+		return d.DialoguePDU.DialogueDiagnostic()
+	}
+
+	return 0, 0, false
+}
+
+// AbortUserInfo returns the user-information in ABRT of U-Abort, or nil if it
+// is absent. The EXTERNALs in it can be decoded with ParseUserInformation.
+func (t *TCAP) AbortUserInfo() *IE {
+	if pdu := t.abrt(); pdu != nil {
+		return pdu.UserInformation
+	}
+
+	return nil
+}
+
+// abrt returns the ABRT if TCAP is an Abort with it, or nil.
+func (t *TCAP) abrt() *DialoguePDU {
+	if t.MessageType() != Abort {
+		return nil
+	}
+	if d := t.Dialogue; d != nil {
+		if pdu := d.DialoguePDU; pdu != nil && pdu.Type.Code() == ABRT {
+			return pdu
+		}
+	}
+
+	return nil
+}
+
 // OTID returns the TCAP Originating Transaction ID in Transaction Portion in uint32.
+//
+// The TID shorter than 4 octets is interpreted as big-endian, e.g., a 1-octet
+// TID 0x01 is 1. Use OTIDBytes to distinguish it from 0x00000001.
 func (t *TCAP) OTID() uint32 {
+	return tidToUint32(t.OTIDBytes())
+}
+
+// OTIDBytes returns the TCAP Originating Transaction ID in Transaction Portion
+// as it is in the octet string, or nil if it is absent.
+func (t *TCAP) OTIDBytes() []byte {
 	if ts := t.Transaction; ts != nil {
 		if otid := ts.OrigTransactionID; otid != nil {
-			return binary.BigEndian.Uint32(otid.Value)
+			return otid.Value
 		}
 	}
 
-	return 0
+	return nil
 }
 
-// DTID returns the TCAP Originating Transaction ID in Transaction Portion in uint32.
+// DTID returns the TCAP Destination Transaction ID in Transaction Portion in uint32.
+//
+// The TID shorter than 4 octets is interpreted as big-endian, e.g., a 1-octet
+// TID 0x01 is 1. Use DTIDBytes to distinguish it from 0x00000001.
 func (t *TCAP) DTID() uint32 {
+	return tidToUint32(t.DTIDBytes())
+}
+
+// DTIDBytes returns the TCAP Destination Transaction ID in Transaction Portion
+// as it is in the octet string, or nil if it is absent.
+func (t *TCAP) DTIDBytes() []byte {
 	if ts := t.Transaction; ts != nil {
 		if dtid := ts.DestTransactionID; dtid != nil {
-			return binary.BigEndian.Uint32(dtid.Value)
+			return dtid.Value
 		}
 	}
 
-	return 0
+	return nil
+}
+
+// DialogueID returns the OTID and the DTID in hex joined by colon, e.g.,
+// "11111111:22222222", for logging. The absent TID is given as "-", e.g.,
+// "11111111:-" for Begin.
+//
+// The TIDs are given in the octets as they are, so a 1-octet TID 0x01 is "01".
+// Note that the OTID and the DTID are swapped in the messages of the opposite
+// direction.
+func (t *TCAP) DialogueID() string {
+	return tidString(t.OTIDBytes()) + ":" + tidString(t.DTIDBytes())
+}
+
+func tidString(b []byte) string {
+	if b == nil {
+		return "-"
+	}
+	return hex.EncodeToString(b)
+}
+
+// DialoguePortionBytes returns a copy of the Dialogue Portion of a parsed TCAP
+// as it was received, including its Tag and Length.
+//
+// The original octets are available in the TCAP parsed by Parse (or
+// UnmarshalBinary), or by ParseBER with WithPreservedEncoding. It returns false
+// if the TCAP has no Dialogue Portion or the original octets are not available.
+func (t *TCAP) DialoguePortionBytes() ([]byte, bool) {
+	if t.Dialogue == nil {
+		return nil, false
+	}
+
+	if e := t.Encoding; e != nil {
+		for _, c := range e.children {
+			if c.ie.Tag == TagDialoguePortion {
+				return c.appendTo(nil), true
+			}
+		}
+		return nil, false
+	}
+
+	ts := t.Transaction
+	if ts == nil || len(ts.Payload) == 0 || Tag(ts.Payload[0]) != TagDialoguePortion {
+		return nil, false
+	}
+	n := len(ts.Payload) - len(t.Dialogue.Payload)
+	if n <= 0 {
+		return nil, false
+	}
+	b := make([]byte, n)
+	copy(b, ts.Payload)
+	return b, true
+}
+
+// RewriteTIDs replaces the Originating and Destination Transaction IDs with the
+// ones given as otid and dtid in 4 octets, leaving the other portions untouched.
+// The TID given as nil is not changed, and the TID absent in TCAP is not added.
+//
+// The Lengths of the TIDs and the Transaction Portion are adjusted. Combined with
+// WithPreservedEncoding, ReMarshal emits the original bytes but the TIDs, which
+// is useful for the TID translation in proxies.
+func (t *TCAP) RewriteTIDs(otid, dtid *uint32) {
+	ts := t.Transaction
+	if ts == nil {
+		return
+	}
+	rewriteTID(ts, ts.OrigTransactionID, otid)
+	rewriteTID(ts, ts.DestTransactionID, dtid)
+}
+
+// rewriteTID replaces the Value of the TID field with tid, without touching the
+// original Value which may be shared with the input of parser.
+func rewriteTID(ts *Transaction, field *IE, tid *uint32) {
+	if field == nil || tid == nil {
+		return
+	}
+
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, *tid)
+	ts.Length += len(v) - len(field.Value)
+	field.Value = v
+	field.Length = len(v)
+}
+
+// RewriteInvokeIDs replaces the Invoke IDs and the Linked IDs in all the
+// Components with the ones returned by fn for the current ones, e.g., for the
+// Invoke ID translation in relays. The Reject without Invoke ID (NULL) and the
+// Components given as raw bytes are left untouched.
+//
+// The new ID must be in the range of -128 to 127; otherwise it is logged and
+// the ID is not changed. The Lengths are adjusted in the same way as RewriteTIDs.
+func (t *TCAP) RewriteInvokeIDs(fn func(old int) int) {
+	c := t.Components
+	if c == nil {
+		return
+	}
+
+	// the form of Length can change with the length of the contents.
+	before := c.MarshalLen()
+	var delta int
+	for _, comp := range c.Component {
+		if comp.Raw != nil {
+			continue
+		}
+		l := comp.MarshalLen()
+		comp.Length += rewriteInvokeID(comp.InvokeID, fn) + rewriteInvokeID(comp.LinkedID, fn)
+		delta += comp.MarshalLen() - l
+	}
+	if delta == 0 {
+		return
+	}
+	c.Length += delta
+	if ts := t.Transaction; ts != nil {
+		ts.Length += c.MarshalLen() - before
+	}
+}
+
+// rewriteInvokeID replaces the Value of the INTEGER field with the one returned
+// by fn, and returns the difference in its length.
+func rewriteInvokeID(field *IE, fn func(old int) int) int {
+	// the Invoke ID of Reject can be NULL.
+	if field == nil || field.Tag == NewUniversalPrimitiveTag(5) {
+		return 0
+	}
+
+	id := fn(decodeIntIE(field))
+	if id < -128 || id > 127 {
+		logf("failed to rewrite Invoke ID, leaving it unchanged: %d is out of range", id)
+		return 0
+	}
+	n := 1 - len(field.Value)
+	field.Value = []byte{uint8(id)}
+	field.Length = 1
+	return n
+}
+
+// tidToUint32 interprets the TID of up to 4 octets as big-endian uint32.
+// The octets after the first 4 are ignored.
+func tidToUint32(b []byte) uint32 {
+	var v uint32
+	for i, x := range b {
+		if i == 4 {
+			break
+		}
+		v = v<<8 | uint32(x)
+	}
+	return v
 }
 
 // AppContextName returns the ACN in string.
@@ -338,6 +1501,55 @@ func (t *TCAP) AppContextNameOid() string {
 	return ""
 }
 
+// OIDs returns all the OBJECT IDENTIFIERs in TCAP in dot notation, in the order
+// they appear, e.g., the dialogue-as-id, the ACN, the direct-reference in the
+// user-information, the global Operation Codes and Error Codes, and the ones in
+// the Parameters. The OIDs that cannot be decoded are skipped.
+func (t *TCAP) OIDs() []string {
+	var oids []string
+	if d := t.Dialogue; d != nil {
+		oids = appendOIDs(oids, d.ObjectIdentifier)
+		if pdu := d.DialoguePDU; pdu != nil {
+			oids = appendOIDs(oids, pdu.ApplicationContextName)
+			oids = appendOIDs(oids, pdu.UserInformation)
+		}
+	}
+	if c := t.Components; c != nil {
+		for _, comp := range c.Component {
+			for _, i := range []*IE{comp.OperationCode, comp.ErrorCode, comp.Parameter} {
+				oids = appendOIDs(oids, i)
+			}
+		}
+	}
+	return oids
+}
+
+// appendOIDs appends the OIDs in i and its descendants to oids. The children
+// of i are decoded from its Value if they are not decoded yet.
+func appendOIDs(oids []string, i *IE) []string {
+	if i == nil {
+		return oids
+	}
+	if i.Tag == NewUniversalPrimitiveTag(6) {
+		if oid, err := decodeOID(i.Value); err == nil {
+			oids = append(oids, oid)
+		}
+		return oids
+	}
+	if i.Tag.Form() != 1 {
+		return oids
+	}
+
+	children := i.IE
+	if len(children) == 0 {
+		children, _ = ParseAsBER(i.Value)
+	}
+	for _, c := range children {
+		oids = appendOIDs(oids, c)
+	}
+	return oids
+}
+
 // ComponentType returns the ComponentType in Component Portion in the list of string.
 //
 // The returned value is of type []string, as it may have multiple Components.
@@ -372,10 +1584,14 @@ func (t *TCAP) InvokeID() []uint8 {
 // OpCode returns the OpCode in Component Portion in the list of string.
 //
 // The returned value is of type []string, as it may have multiple Components.
+// ReturnResults and ReturnErrors without the code are skipped.
 func (t *TCAP) OpCode() []uint8 {
 	if c := t.Components; c != nil {
 		var ops []uint8
 		for _, cm := range c.Component {
+			if cm.Type.Code() != Reject && cm.opCodeIE() == nil {
+				continue
+			}
 			ops = append(ops, cm.OpCode())
 		}
 
@@ -385,13 +1601,42 @@ func (t *TCAP) OpCode() []uint8 {
 	return nil
 }
 
+// OperationCodes returns the operation codes in Invoke and ReturnResult(Not)Last
+// Components, in the order of Components. ReturnResults without operation
+// code are skipped.
+func (t *TCAP) OperationCodes() []int {
+	var ops []int
+	for _, tc := range t.TypedComponents() {
+		switch c := tc.(type) {
+		case *InvokeComponent:
+			ops = append(ops, c.OperationCode)
+		case *ReturnResultLastComponent:
+			if c.OperationCode >= 0 {
+				ops = append(ops, c.OperationCode)
+			}
+		case *ReturnResultNotLastComponent:
+			if c.OperationCode >= 0 {
+				ops = append(ops, c.OperationCode)
+			}
+		}
+	}
+
+	return ops
+}
+
 // LayerPayload returns the upper layer as byte slice.
 //
 // The returned value is of type [][]byte, as it may have multiple Components.
+// It is nil for the Components without Parameter, e.g., ReturnResult without
+// the result, to keep the order of Components.
 func (t *TCAP) LayerPayload() [][]byte {
 	if c := t.Components; c != nil {
 		var ret [][]byte
 		for _, cm := range c.Component {
+			if cm.Parameter == nil {
+				ret = append(ret, nil)
+				continue
+			}
 			ret = append(ret, cm.Parameter.Value)
 		}
 
@@ -0,0 +1,1964 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestParseBERWithDeepDecode(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x62, 0x17, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion
+		0x6c, 0x0f, 0xa1, 0x0d, 0x02, 0x01, 0x00, 0x02, 0x01, 0x03,
+		// Parameter: SEQUENCE { OCTET STRING containing INTEGER }
+		0x30, 0x05, 0x04, 0x03, 0x02, 0x01, 0x05,
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	param := parsed[0].Components.Component[0].Parameter
+	if got, want := len(param.IE), 1; got != want {
+		t.Fatalf("got %d IEs in Parameter want %d", got, want)
+	}
+	if got := len(param.IE[0].IE); got != 0 {
+		t.Errorf("got %d IEs in OCTET STRING without deep decode want 0", got)
+	}
+
+	parsed, err = tcap.ParseBER(b, tcap.WithDeepDecode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	param = parsed[0].Components.Component[0].Parameter
+	if got, want := len(param.IE[0].IE), 1; got != want {
+		t.Fatalf("got %d IEs in OCTET STRING with deep decode want %d", got, want)
+	}
+	inner := param.IE[0].IE[0]
+	if got, want := inner.Tag, tcap.Tag(0x02); got != want {
+		t.Errorf("got Tag %v want %v", got, want)
+	}
+	if got, want := inner.Value, []byte{0x05}; string(got) != string(want) {
+		t.Errorf("got Value %x want %x", got, want)
+	}
+}
+
+func TestDeepDecodeSequenceAndSet(t *testing.T) {
+	for _, tag := range []tcap.Tag{0x30, 0x31} {
+		t.Run(tag.String(), func(t *testing.T) {
+			// Parameter: SEQUENCE/SET { INTEGER 1, INTEGER 2 }
+			param := []byte{byte(tag), 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02}
+			b := tcaptest.Begin().OTID(0x11111111).Invoke(1, 22, param).Bytes()
+
+			parsed, err := tcap.ParseBER(b, tcap.WithDeepDecode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parsed[0].Components.Component[0].Parameter
+			if got, want := p.Tag.Form(), tcap.Constructor; got != want {
+				t.Errorf("got Form %d want %d", got, want)
+			}
+			if got, want := len(p.IE), 2; got != want {
+				t.Fatalf("got %d IEs in Parameter want %d", got, want)
+			}
+			for i, ie := range p.IE {
+				if got, want := ie.Tag.String(), "INTEGER"; got != want {
+					t.Errorf("got Tag %s want %s", got, want)
+				}
+				if got, want := ie.Value, []byte{byte(i + 1)}; !bytes.Equal(got, want) {
+					t.Errorf("got Value %x want %x", got, want)
+				}
+			}
+			if s := p.String(); !strings.HasPrefix(s, "{Tag: "+tag.String()+",") || !strings.Contains(s, "INTEGER") {
+				t.Errorf("got %s want the Tags named", s)
+			}
+		})
+	}
+}
+
+func TestMaxComponents(t *testing.T) {
+	param := []byte{0x30, 0x03, 0x80, 0x01, 0x05}
+	b := tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+		Invoke(0, 22, param).Invoke(1, 22, param).Invoke(2, 22, param).Invoke(3, 22, param).Bytes()
+	next := tcaptest.End().DTID(0x11111111).ReturnResult(0, 22, param).Bytes()
+	both := append(append([]byte{}, b...), next...)
+
+	cases := []struct {
+		description string
+		opts        []tcap.ParseOption
+	}{
+		{"decoded", []tcap.ParseOption{tcap.WithMaxComponents(2)}},
+		{"with StopAtComponentParameter", []tcap.ParseOption{tcap.WithMaxComponents(2), tcap.StopAtComponentParameter()}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			parsed, err := tcap.ParseBER(both, c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(parsed), 2; got != want {
+				t.Fatalf("got %d TCAPs want %d", got, want)
+			}
+			if got, want := parsed[0].InvokeID(), []uint8{0, 1}; !bytes.Equal(got, want) {
+				t.Errorf("got Invoke IDs %v want %v", got, want)
+			}
+			if got, want := parsed[0].Components.Skipped, 2; got != want {
+				t.Errorf("got Skipped %d want %d", got, want)
+			}
+			if got, want := parsed[1].Components.Skipped, 0; got != want {
+				t.Errorf("got Skipped %d in the next message want %d", got, want)
+			}
+			if got, want := parsed[1].MessageType(), tcap.End; got != want {
+				t.Errorf("got MessageType %d want %d", got, want)
+			}
+
+			strict, err := tcap.ParseBERStrict(b, c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(strict.Components.Component), 2; got != want {
+				t.Errorf("got %d Components want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestStopAtComponentParameter(t *testing.T) {
+	param := []byte{0x30, 0x06, 0x80, 0x01, 0x05, 0xa1, 0x01, 0x00}
+	cases := []struct {
+		description string
+		b           []byte
+	}{
+		{"Invoke", tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Invoke(1, 22, param).Bytes()},
+		{"ReturnResult", tcaptest.End().DTID(0x11111111).ReturnResult(1, 22, param).Bytes()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			recursive, err := tcap.ParseBERStrict(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := tcap.ParseBERStrict(c.b, tcap.StopAtComponentParameter())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comp := m.Components.Component[0]
+			if comp.Parameter.IE != nil {
+				t.Errorf("got Parameter children %v want nil", comp.Parameter.IE)
+			}
+			if got, want := comp.ParameterBytes(), param; !bytes.Equal(got, want) {
+				t.Errorf("got ParameterBytes %x want %x", got, want)
+			}
+			if got, want := len(comp.Parameters()), 2; got != want {
+				t.Errorf("got %d Parameters want %d", got, want)
+			}
+
+			want := recursive.Components.Component[0]
+			verify.Values(t, "InvokeID", comp.InvokeID, want.InvokeID)
+			verify.Values(t, "OperationCode", comp.OperationCode, want.OperationCode)
+			if got, want := m.AppContextName(), recursive.AppContextName(); got != want {
+				t.Errorf("got AppContextName %s want %s", got, want)
+			}
+
+			b, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.b) {
+				t.Errorf("got %x want %x", b, c.b)
+			}
+		})
+	}
+}
+
+func TestParseBERWithZeroPadding(t *testing.T) {
+	b := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Invoke(1, 22, []byte{0x04, 0x01, 0x00}).Bytes()
+	padded := append(append([]byte{}, b...), make([]byte, 500)...)
+
+	parsed, err := tcap.ParseBER(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(parsed), 1; got != want {
+		t.Fatalf("got %d TCAPs want %d", got, want)
+	}
+	want, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "TCAP", parsed[0], want[0])
+
+	if _, err := tcap.ParseBER(make([]byte, 500)); err == nil {
+		t.Error("got no error for zeros only")
+	}
+}
+
+func TestParseBERStrict(t *testing.T) {
+	msg := []byte{
+		// Transaction Portion
+		0x65, 0x16, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11, 0x49, 0x04, 0x22, 0x22, 0x22, 0x22,
+		// Component Portion
+		0x6c, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x3d,
+	}
+
+	cases := []struct {
+		description string
+		b           []byte
+		opts        []tcap.ParseOption
+		ok          bool
+	}{
+		{"exact", msg, nil, true},
+		{"zero padding", append(append([]byte{}, msg...), 0, 0, 0), nil, false},
+		{"zero padding tolerated", append(append([]byte{}, msg...), 0, 0, 0), []tcap.ParseOption{tcap.WithZeroPadding()}, true},
+		{"garbage", append(append([]byte{}, msg...), 0, 0xff), []tcap.ParseOption{tcap.WithZeroPadding()}, false},
+		{"truncated", msg[:len(msg)-1], nil, false},
+		{"contents shorter than length", append([]byte{0x65, 0x17}, append(msg[2:], 0)...), nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			v, err := tcap.ParseBERStrict(c.b, c.opts...)
+			if !c.ok {
+				if err == nil {
+					t.Fatalf("got %v want error", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := v.OTID(), uint32(0x11111111); got != want {
+				t.Errorf("got OTID %#x want %#x", got, want)
+			}
+			if got, want := v.DTID(), uint32(0x22222222); got != want {
+				t.Errorf("got DTID %#x want %#x", got, want)
+			}
+		})
+	}
+}
+
+func TestStrictComponents(t *testing.T) {
+	b := []byte{
+		// Transaction Portion
+		0x62, 0x12, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion with Invoke and SEQUENCE
+		0x6c, 0x0a, 0xa1, 0x03, 0x02, 0x01, 0x01, 0x30, 0x03, 0x02, 0x01, 0x02,
+	}
+
+	lenient, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := lenient[0].Components.Component
+	if got, want := len(comps), 2; got != want {
+		t.Fatalf("got %d Components want %d", got, want)
+	}
+	if got, want := comps[1].Type, tcap.Tag(0x30); got != want {
+		t.Errorf("got Type %v want %v", got, want)
+	}
+
+	var tagErr *tcap.UnexpectedComponentTagError
+	if _, err := tcap.ParseBER(b, tcap.WithStrictComponents()); !errors.As(err, &tagErr) {
+		t.Fatalf("got %v want UnexpectedComponentTagError", err)
+	}
+	if got, want := tagErr.Error(), "tcap: unexpected component tag 0x30 in component portion"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if _, err := tcap.ParseBERStrict(b, tcap.WithStrictComponents()); !errors.As(err, &tagErr) {
+		t.Errorf("got %v want UnexpectedComponentTagError", err)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	msg := tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x00}).Bytes()
+	// the outer Length claims 16 MiB with only a few octets following.
+	crafted := []byte{0x62, 0x83, 0xff, 0xff, 0xff, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11}
+
+	var tooLong *tcap.TooLongError
+	if _, err := tcap.ParseBERStrict(crafted); !errors.As(err, &tooLong) {
+		t.Errorf("ParseBERStrict: got %v want *tcap.TooLongError", err)
+	} else if got, want := tooLong.Max, tcap.DefaultMaxMessageSize; got != want {
+		t.Errorf("got Max %d want %d", got, want)
+	}
+	var pe *tcap.ParseError
+	if _, err := tcap.ParseBER(crafted); !errors.As(err, &pe) || !errors.As(err, &tooLong) {
+		t.Errorf("ParseBER: got %v want *tcap.TooLongError", err)
+	} else if got, want := pe.Cause, tcap.ResourceLimitation; got != want {
+		t.Errorf("got Cause %d want %d", got, want)
+	}
+	if _, err := tcap.ParseBERAll(crafted); !errors.As(err, &tooLong) {
+		t.Errorf("ParseBERAll: got %v want *tcap.TooLongError", err)
+	}
+
+	if _, err := tcap.ParseBERStrict(msg, tcap.WithMaxMessageSize(len(msg)-1)); !errors.As(err, &tooLong) {
+		t.Errorf("got %v want *tcap.TooLongError", err)
+	}
+	if _, err := tcap.ParseBERStrict(msg, tcap.WithMaxMessageSize(len(msg))); err != nil {
+		t.Errorf("got %v with the limit of the same size", err)
+	}
+	if _, err := tcap.ParseBERStrict(crafted, tcap.WithMaxMessageSize(0)); errors.As(err, &tooLong) {
+		t.Errorf("got %v without limit", err)
+	}
+}
+
+func TestMarshalForSCCP(t *testing.T) {
+	m := tcap.NewContinueInvoke(0x11111111, 0x22222222, 1, 61, []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef})
+	b, err := m.MarshalForSCCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(b), m.MarshalLen(); got != want {
+		t.Errorf("got %d octets want %d", got, want)
+	}
+
+	m = &tcap.TCAP{
+		Transaction: tcap.NewContinue(0x11111111, 0x22222222, nil),
+		Components: tcap.NewComponents(
+			tcap.NewInvoke(1, -1, 61, true, append([]byte{0x30, 0x7e}, make([]byte, 0x7e)...)),
+			tcap.NewInvoke(2, -1, 61, true, append([]byte{0x30, 0x7e}, make([]byte, 0x7e)...)),
+		),
+	}
+	m.SetLength()
+
+	_, err = m.MarshalForSCCP()
+	tooLong, ok := err.(*tcap.TooLongError)
+	if !ok {
+		t.Fatalf("got %v want *tcap.TooLongError", err)
+	}
+	if got, want := tooLong.Max, tcap.MaxUDTDataLen; got != want {
+		t.Errorf("got Max %d want %d", got, want)
+	}
+	if got, want := tooLong.Length, m.MarshalLen(); got != want {
+		t.Errorf("got Length %d want %d", got, want)
+	}
+}
+
+func TestReMarshal(t *testing.T) {
+	b := []byte{
+		// Transaction Portion, with Length in three octets
+		0x62, 0x82, 0x00, 0x12,
+		// OTID, with Length in two octets
+		0x48, 0x81, 0x04, 0x11, 0x11, 0x11, 0x11,
+		// Component Portion
+		0x6c, 0x81, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+	}
+
+	parsed, err := tcap.ParseBER(b, tcap.WithPreservedEncoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+
+	got, err := m.ReMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, b) {
+		t.Errorf("got %x want %x", got, b)
+	}
+	if minimal, _ := m.MarshalBinary(); bytes.Equal(minimal, b) {
+		t.Errorf("MarshalBinary unexpectedly preserved encoding: %x", minimal)
+	}
+
+	m.Transaction.OrigTransactionID.Value = []byte{0x22, 0x22, 0x22, 0x22, 0x22}
+	got, err = m.ReMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x62, 0x82, 0x00, 0x13,
+		0x48, 0x81, 0x05, 0x22, 0x22, 0x22, 0x22, 0x22,
+		0x6c, 0x81, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+
+	// without the option, it is the same as MarshalBinary.
+	parsed, err = tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed[0].Encoding != nil {
+		t.Errorf("got Encoding without WithPreservedEncoding")
+	}
+}
+
+func TestAbortFromParseError(t *testing.T) {
+	cases := []struct {
+		description string
+		b           []byte
+		cause       tcap.PAbortCause
+	}{
+		{
+			"truncated",
+			[]byte{0x62, 0x10, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11},
+			tcap.BadlyFormattedTransactionPortion,
+		}, {
+			"unknown message type",
+			[]byte{0x63, 0x06, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11},
+			tcap.UnrecognizedMessageType,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			_, err := tcap.ParseBER(c.b)
+			var pe *tcap.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v want *tcap.ParseError", err)
+			}
+			if got, want := pe.Cause, c.cause; got != want {
+				t.Errorf("got Cause %d want %d", got, want)
+			}
+
+			m := tcap.AbortFromParseError(0x11111111, err)
+			b, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []byte{0x67, 0x09, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, uint8(c.cause)}
+			if !bytes.Equal(b, want) {
+				t.Errorf("got %x want %x", b, want)
+			}
+		})
+	}
+}
+
+func TestMessageType(t *testing.T) {
+	b := []byte{
+		0x61, 0x0f,
+		0x6c, 0x0d, 0xa1, 0x0b, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2e, 0x30, 0x03, 0x80, 0x01, 0x00,
+	}
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+	if got, want := m.MessageType(), tcap.Unidirectional; got != want {
+		t.Errorf("got MessageType %d want %d", got, want)
+	}
+	if got, want := len(m.Components.Component), 1; got != want {
+		t.Fatalf("got %d components want %d", got, want)
+	}
+	if got, want := m.OpCode(), []uint8{46}; !bytes.Equal(got, want) {
+		t.Errorf("got OpCode %v want %v", got, want)
+	}
+}
+
+func TestAbortMessageType(t *testing.T) {
+	uAbort, err := tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceUser).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		description string
+		b           []byte
+		hasDialogue bool
+	}{
+		{"P-Abort", tcaptest.Abort().DTID(0x11111111).PAbortCause(0x01).Bytes(), false},
+		{"P-Abort without cause", tcaptest.Abort().DTID(0x11111111).Bytes(), false},
+		{"U-Abort", uAbort, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			fromBER, err := tcap.ParseBER(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+				if got, want := m.MessageType(), tcap.Abort; got != want {
+					t.Errorf("got MessageType %d want %d", got, want)
+				}
+				if m.HasComponents() {
+					t.Errorf("got HasComponents true want false")
+				}
+				if got := m.HasDialogue(); got != c.hasDialogue {
+					t.Errorf("got HasDialogue %v want %v", got, c.hasDialogue)
+				}
+			}
+		})
+	}
+}
+
+func TestPAbortCause(t *testing.T) {
+	b := tcaptest.Abort().DTID(0x11111111).PAbortCause(tcap.ResourceLimitation).Bytes()
+	if want := []byte{0x67, 0x09, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, 0x04}; !bytes.Equal(b, want) {
+		t.Fatalf("got %x want %x", b, want)
+	}
+
+	fromBER, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict, err := tcap.ParseBERStrict(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range []*tcap.TCAP{fromBER[0], fromBytes, strict} {
+		cause, ok := m.PAbortCause()
+		if !ok {
+			t.Fatal("got no P-Abort Cause")
+		}
+		if got, want := cause, tcap.ResourceLimitation; got != want {
+			t.Errorf("got P-Abort Cause %v want %v", got, want)
+		}
+		if got, want := m.Transaction.AbortCause(), "ResourceLimitation"; got != want {
+			t.Errorf("got AbortCause %s want %s", got, want)
+		}
+	}
+
+	uAbort, err := tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceUser).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := tcap.ParseBERStrict(uAbort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.PAbortCause(); ok {
+		t.Error("got P-Abort Cause in U-Abort")
+	}
+
+	noReason := tcaptest.Abort().DTID(0x11111111).Bytes()
+	if _, err := tcap.ParseBERStrict(noReason); err != tcap.ErrNoAbortReason {
+		t.Errorf("got %v want %v", err, tcap.ErrNoAbortReason)
+	}
+	if _, err := tcap.ParseBER(noReason); err != nil {
+		t.Errorf("got %v want nil", err)
+	}
+}
+
+func TestAbortEnumStrings(t *testing.T) {
+	causes := []struct {
+		cause tcap.PAbortCause
+		want  string
+	}{
+		{tcap.UnrecognizedMessageType, "unrecognizedMessageType"},
+		{tcap.UnrecognizedTransactionID, "unrecognizedTransactionID"},
+		{tcap.BadlyFormattedTransactionPortion, "badlyFormattedTransactionPortion"},
+		{tcap.IncorrectTransactionPortion, "incorrectTransactionPortion"},
+		{tcap.ResourceLimitation, "resourceLimitation"},
+		{5, "unknown(5)"},
+	}
+	for _, c := range causes {
+		if got := c.cause.String(); got != c.want {
+			t.Errorf("got %s want %s", got, c.want)
+		}
+	}
+
+	sources := []struct {
+		src  tcap.AbortSource
+		want string
+	}{
+		{tcap.AbortDialogueServiceUser, "dialogue-service-user"},
+		{tcap.AbortDialogueServiceProvider, "dialogue-service-provider"},
+		{2, "unknown(2)"},
+	}
+	for _, s := range sources {
+		if got := s.src.String(); got != s.want {
+			t.Errorf("got %s want %s", got, s.want)
+		}
+	}
+}
+
+func TestEmptyComponentPortion(t *testing.T) {
+	cases := []struct {
+		description string
+		opts        []tcap.MessageOption
+		want        []byte
+		present     bool
+	}{
+		{
+			"absent",
+			nil,
+			[]byte{0x62, 0x06, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11},
+			false,
+		}, {
+			"empty",
+			[]tcap.MessageOption{tcap.WithEmptyComponentPortion()},
+			[]byte{0x62, 0x08, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11, 0x6c, 0x00},
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			opts := append([]tcap.MessageOption{tcap.WithOTID(0x11111111)}, c.opts...)
+			b, err := tcap.NewMessage(tcap.Begin, opts...).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.want) {
+				t.Fatalf("got %x want %x", b, c.want)
+			}
+
+			fromBER, err := tcap.ParseBER(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+				if got := m.ComponentPortionPresent(); got != c.present {
+					t.Errorf("got ComponentPortionPresent %v want %v", got, c.present)
+				}
+				if m.HasComponents() {
+					t.Errorf("got HasComponents true want false")
+				}
+			}
+
+			re, err := fromBER[0].MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(re, c.want) {
+				t.Errorf("got %x want %x", re, c.want)
+			}
+		})
+	}
+}
+
+func TestTIDBytes(t *testing.T) {
+	b := []byte{
+		0x65, 0x09, 0x48, 0x01, 0x01, 0x49, 0x04, 0x00, 0x00, 0x00, 0x01,
+	}
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+
+	if got, want := m.OTID(), m.DTID(); got != want {
+		t.Errorf("got OTID %#x want %#x", got, want)
+	}
+	if got, want := m.OTIDBytes(), []byte{0x01}; !bytes.Equal(got, want) {
+		t.Errorf("got OTIDBytes %x want %x", got, want)
+	}
+	if got, want := m.DTIDBytes(), []byte{0x00, 0x00, 0x00, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("got DTIDBytes %x want %x", got, want)
+	}
+	if got := tcap.NewUnidirectionalInvoke(1, 46, nil).OTIDBytes(); got != nil {
+		t.Errorf("got OTIDBytes %x want nil", got)
+	}
+}
+
+func TestTransactionIDCorrelation(t *testing.T) {
+	cases := []struct {
+		description string
+		tid         []byte
+		minimal     []byte
+	}{
+		{"1 octet", []byte{0x01}, []byte{0x01}},
+		{"4 octets", []byte{0x00, 0x00, 0x00, 0x01}, []byte{0x01}},
+		{"zero", []byte{0x00, 0x00}, []byte{0x00}},
+		{"no leading zero", []byte{0x11, 0x00, 0x00, 0x01}, []byte{0x11, 0x00, 0x00, 0x01}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := tcap.NewTransactionIDBytes(c.tid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, c.minimal) {
+				t.Errorf("got %x want %x", got, c.minimal)
+			}
+		})
+	}
+
+	for _, b := range [][]byte{nil, {0x00, 0x00, 0x00, 0x00, 0x01}} {
+		if _, err := tcap.NewTransactionIDBytes(b); err != tcap.ErrInvalidTransactionID {
+			t.Errorf("got %v want ErrInvalidTransactionID for %x", err, b)
+		}
+	}
+
+	// the received DTID 0x01 matches the stored OTID 1.
+	stored := make([]byte, 4)
+	binary.BigEndian.PutUint32(stored, 1)
+	if got := tcap.CompareTransactionIDs([]byte{0x01}, stored); got != 0 {
+		t.Errorf("got %d want 0", got)
+	}
+	if got := tcap.CompareTransactionIDs([]byte{0x01}, []byte{0x00, 0x02}); got != -1 {
+		t.Errorf("got %d want -1", got)
+	}
+	if got := tcap.CompareTransactionIDs([]byte{0x01, 0x00}, []byte{0xff}); got != 1 {
+		t.Errorf("got %d want 1", got)
+	}
+}
+
+func TestDialogueID(t *testing.T) {
+	cases := []struct {
+		description string
+		m           *tcap.TCAP
+		want        string
+	}{
+		{"Begin", tcaptest.Begin().OTID(0x11111111).Message(), "11111111:-"},
+		{"Continue", tcaptest.Continue().OTID(0x22222222).DTID(0x11111111).Message(), "22222222:11111111"},
+		{"End", tcaptest.End().DTID(0x22222222).Message(), "-:22222222"},
+		{"Unidirectional", tcap.NewUnidirectionalInvoke(1, 46, nil), "-:-"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			if got := c.m.DialogueID(); got != c.want {
+				t.Errorf("got %s want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDialoguePortionBytes(t *testing.T) {
+	m := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		Invoke(0, 22, nil).Message()
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := m.Dialogue.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := parsed.DialoguePortionBytes(); !ok || !bytes.Equal(got, want) {
+		t.Errorf("Parse: got %x, %v want %x, true", got, ok, want)
+	}
+
+	ms, err := tcap.ParseBER(b, tcap.WithPreservedEncoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ms[0].DialoguePortionBytes(); !ok || !bytes.Equal(got, want) {
+		t.Errorf("ParseBER: got %x, %v want %x, true", got, ok, want)
+	}
+
+	ms, err = tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ms[0].DialoguePortionBytes(); ok {
+		t.Errorf("ParseBER without WithPreservedEncoding: got %x, true want false", got)
+	}
+}
+
+func TestNewContinueEmpty(t *testing.T) {
+	m := tcap.NewContinueEmpty(
+		0x11111111, 0x22222222,
+		tcap.NewDialogue(1, 1, tcap.NewAARE(1, tcap.LocationInfoRetrievalContext, 3, 0, 1, 0), []byte{}),
+	)
+	if m.Components != nil {
+		t.Fatalf("got Components %v want nil", m.Components)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := tcap.ParseBERStrict(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.MessageType(), tcap.Continue; got != want {
+		t.Errorf("got MessageType %d want %d", got, want)
+	}
+	if v.Dialogue == nil {
+		t.Errorf("got no Dialogue")
+	}
+	if v.Components != nil {
+		t.Errorf("got Components %v want nil", v.Components)
+	}
+	if got, want := v.DTID(), uint32(0x22222222); got != want {
+		t.Errorf("got DTID %#x want %#x", got, want)
+	}
+
+	p, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Dialogue == nil || p.Components != nil {
+		t.Errorf("Parse: got Dialogue %v, Components %v", p.Dialogue, p.Components)
+	}
+}
+
+func TestReplyContinue(t *testing.T) {
+	begin, err := tcap.ParseBERStrict(
+		tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Invoke(1, 22, []byte{0x04, 0x01, 0x00}).Bytes(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := tcap.ReplyContinue(begin, 0x22222222, tcap.NewReturnResult(1, 22, true, true, []byte{0x04, 0x01, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := reply.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := tcap.ParseBERStrict(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.MessageType(), tcap.Continue; got != want {
+		t.Errorf("got MessageType %d want %d", got, want)
+	}
+	if got, want := v.OTID(), uint32(0x22222222); got != want {
+		t.Errorf("got OTID %#x want %#x", got, want)
+	}
+	if got, want := v.DTID(), uint32(0x11111111); got != want {
+		t.Errorf("got DTID %#x want %#x", got, want)
+	}
+	if result, ok := v.DialogueResult(); !ok || result != tcap.Accepted {
+		t.Errorf("got DialogueResult %d, %v want %d, true", result, ok, tcap.Accepted)
+	}
+	if got, want := v.AppContextNameWithVersion(), begin.AppContextNameWithVersion(); got != want {
+		t.Errorf("got ACN %s want %s", got, want)
+	}
+	verify.Values(t, "ComponentType", v.ComponentType(), []string{"returnResultLast"})
+
+	// the next Continue from the peer is addressed to the local TID.
+	cont := tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).Invoke(2, 22, nil).Message()
+	reply, err = tcap.ReplyContinue(cont, 0x22222222)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reply.OTID(), uint32(0x22222222); got != want {
+		t.Errorf("got OTID %#x want %#x", got, want)
+	}
+	if got, want := reply.DTID(), uint32(0x11111111); got != want {
+		t.Errorf("got DTID %#x want %#x", got, want)
+	}
+	if reply.Dialogue != nil {
+		t.Errorf("got Dialogue %v want nil", reply.Dialogue)
+	}
+
+	if _, err := tcap.ReplyContinue(cont, 0x33333333); err != tcap.ErrTIDMismatch {
+		t.Errorf("got %v want %v", err, tcap.ErrTIDMismatch)
+	}
+	if _, err := tcap.ReplyContinue(tcaptest.End().DTID(0x22222222).Message(), 0x22222222); err != tcap.ErrNotReplyable {
+		t.Errorf("got %v want %v", err, tcap.ErrNotReplyable)
+	}
+}
+
+func TestReplyEnd(t *testing.T) {
+	cases := []struct {
+		description string
+		received    *tcap.TCAP
+		hasDialogue bool
+	}{
+		{
+			"Begin with AARQ",
+			tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Invoke(1, 22, nil).Message(),
+			true,
+		}, {
+			"Begin without Dialogue",
+			tcaptest.Begin().OTID(0x11111111).Invoke(1, 22, nil).Message(),
+			false,
+		}, {
+			"Continue",
+			tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).Invoke(1, 22, nil).Message(),
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			reply, err := tcap.ReplyEnd(c.received, tcap.NewReturnResult(1, 22, true, true, []byte{0x04, 0x01, 0x00}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := reply.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := tcap.ParseBERStrict(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := v.MessageType(), tcap.End; got != want {
+				t.Errorf("got MessageType %d want %d", got, want)
+			}
+			if got := v.OTIDBytes(); got != nil {
+				t.Errorf("got OTID %x want none", got)
+			}
+			if got, want := v.DTID(), uint32(0x11111111); got != want {
+				t.Errorf("got DTID %#x want %#x", got, want)
+			}
+			if got := v.HasDialogue(); got != c.hasDialogue {
+				t.Errorf("got HasDialogue %v want %v", got, c.hasDialogue)
+			}
+			if c.hasDialogue {
+				if result, ok := v.DialogueResult(); !ok || result != tcap.Accepted {
+					t.Errorf("got DialogueResult %d, %v want %d, true", result, ok, tcap.Accepted)
+				}
+			}
+			if !v.HasComponents() {
+				t.Error("got no Components")
+			}
+		})
+	}
+
+	if _, err := tcap.ReplyEnd(tcaptest.End().DTID(0x11111111).Message()); err != tcap.ErrNotReplyable {
+		t.Errorf("got %v want %v", err, tcap.ErrNotReplyable)
+	}
+}
+
+func TestReplyAbort(t *testing.T) {
+	begin := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Invoke(1, 22, nil).Message()
+	reply, err := tcap.ReplyAbort(begin, tcap.ResourceLimitation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := reply.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x67, 0x09, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, 0x04}; !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	if _, err := tcap.ReplyAbort(tcaptest.Abort().DTID(0x11111111).Message(), tcap.ResourceLimitation); err != tcap.ErrNotReplyable {
+		t.Errorf("got %v want %v", err, tcap.ErrNotReplyable)
+	}
+}
+
+func TestTagDefinitions(t *testing.T) {
+	cases := []struct {
+		description string
+		got, want   tcap.Tag
+	}{
+		{"Unidirectional", tcap.TagUnidirectional, tcap.NewApplicationWideConstructorTag(tcap.Unidirectional)},
+		{"Begin", tcap.TagBegin, tcap.NewApplicationWideConstructorTag(tcap.Begin)},
+		{"End", tcap.TagEnd, tcap.NewApplicationWideConstructorTag(tcap.End)},
+		{"Continue", tcap.TagContinue, tcap.NewApplicationWideConstructorTag(tcap.Continue)},
+		{"Abort", tcap.TagAbort, tcap.NewApplicationWideConstructorTag(tcap.Abort)},
+		{"OTID", tcap.TagOriginatingTID, tcap.NewApplicationWidePrimitiveTag(8)},
+		{"DTID", tcap.TagDestinationTID, tcap.NewApplicationWidePrimitiveTag(9)},
+		{"P-Abort Cause", tcap.TagPAbortCause, tcap.NewApplicationWidePrimitiveTag(10)},
+		{"Dialogue Portion", tcap.TagDialoguePortion, tcap.NewApplicationWideConstructorTag(11)},
+		{"Component Portion", tcap.TagComponentPortion, tcap.NewApplicationWideConstructorTag(12)},
+		{"Invoke", tcap.TagInvoke, tcap.NewContextSpecificConstructorTag(tcap.Invoke)},
+		{"ReturnResultLast", tcap.TagReturnResultLast, tcap.NewContextSpecificConstructorTag(tcap.ReturnResultLast)},
+		{"ReturnError", tcap.TagReturnError, tcap.NewContextSpecificConstructorTag(tcap.ReturnError)},
+		{"Reject", tcap.TagReject, tcap.NewContextSpecificConstructorTag(tcap.Reject)},
+		{"ReturnResultNotLast", tcap.TagReturnResultNotLast, tcap.NewContextSpecificConstructorTag(tcap.ReturnResultNotLast)},
+	}
+
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("%s: got %#x want %#x", c.description, c.got, c.want)
+		}
+	}
+}
+
+func TestDialogueOID(t *testing.T) {
+	if got, want := tcap.DialogueOID(tcap.DialogueAsID), "0.0.17.773.1.1.1"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+	if got, want := tcap.DialogueOID(tcap.UnidialogueAsID), "0.0.17.773.1.2.1"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	// Begin with unidialogue-as-id.
+	begin := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).Message()
+	begin.Dialogue = tcap.NewDialogue(tcap.UnidialogueAsID, 1, begin.Dialogue.DialoguePDU, []byte{})
+	begin.SetLength()
+	b, err := begin.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var oidErr *tcap.InvalidDialogueOIDError
+	if _, err := tcap.ParseBER(b); !errors.As(err, &oidErr) {
+		t.Fatalf("ParseBER: got %v want *tcap.InvalidDialogueOIDError", err)
+	}
+	if got, want := oidErr.Want, tcap.DialogueOID(tcap.DialogueAsID); got != want {
+		t.Errorf("got Want %s want %s", got, want)
+	}
+	if _, err := tcap.Parse(b); !errors.As(err, &oidErr) {
+		t.Errorf("Parse: got %v want *tcap.InvalidDialogueOIDError", err)
+	}
+
+	// unknown OID, which cannot be built by NewDialogue.
+	begin.Dialogue = tcap.NewDialogue(tcap.DialogueAsID, 1, begin.Dialogue.DialoguePDU, []byte{})
+	begin.SetLength()
+	b, err = begin.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[bytes.Index(b, []byte{0x00, 0x11, 0x86, 0x05, 0x01, 0x01, 0x01})+5] = 3
+	if _, err := tcap.ParseBER(b); !errors.As(err, &oidErr) || oidErr.Want != "" {
+		t.Errorf("ParseBER: got %v want *tcap.InvalidDialogueOIDError with unknown OID", err)
+	}
+}
+
+func TestDialogueStructure(t *testing.T) {
+	cases := []struct {
+		description string
+		m           *tcap.TCAP
+		want        uint8
+	}{
+		{
+			"Unidirectional",
+			tcap.NewUnidirectionalInvokeWithDialogue(tcap.DialogueStructured, tcap.LocationInfoRetrievalContext, 3, 0, 22, nil),
+			tcap.DialogueUnstructured,
+		}, {
+			"Begin",
+			tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueStructured, tcap.LocationInfoRetrievalContext, 3, 0, 22, nil),
+			tcap.DialogueStructured,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			b, err := c.m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := tcap.ParseBERStrict(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := v.Dialogue.DialogueType()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got %d want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPortionsLocatedByTag(t *testing.T) {
+	b := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		Invoke(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Bytes()
+	otid := b[2:8]
+	dialogue := b[8 : 10+int(b[9])]
+	comps := b[10+int(b[9]):]
+	if dialogue[0] != 0x6b || comps[0] != 0x6c {
+		t.Fatalf("got portions %x and %x", dialogue[0], comps[0])
+	}
+
+	cases := []struct {
+		description string
+		portions    [][]byte
+	}{
+		{"in order", [][]byte{otid, dialogue, comps}},
+		{"Component Portion first", [][]byte{otid, comps, dialogue}},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			msg := append([]byte{0x62, b[1]}, bytes.Join(c.portions, nil)...)
+			fromBER, err := tcap.ParseBER(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromBytes, err := tcap.Parse(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range []*tcap.TCAP{fromBER[0], fromBytes} {
+				if got, want := m.Dialogue.Tag, tcap.TagDialoguePortion; got != want {
+					t.Errorf("got Dialogue Tag %v want %v", got, want)
+				}
+				if got, want := m.AppContextName(), "locationInfoRetrievalContext"; got != want {
+					t.Errorf("got ACN %s want %s", got, want)
+				}
+				if got, want := m.Components.Tag, tcap.TagComponentPortion; got != want {
+					t.Errorf("got Components Tag %v want %v", got, want)
+				}
+				if got, want := m.OperationCodes(), []int{22}; !verify.Values(t, "", got, want) {
+					t.Errorf("got OperationCodes %v want %v", got, want)
+				}
+			}
+		})
+	}
+
+	_, err := tcap.ParseDialogue(comps)
+	var pe *tcap.UnexpectedPortionTagError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v want *UnexpectedPortionTagError", err)
+	}
+	if pe.Tag != tcap.TagComponentPortion || pe.Want != tcap.TagDialoguePortion {
+		t.Errorf("got %v", pe)
+	}
+}
+
+func TestRewriteTIDs(t *testing.T) {
+	b := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		Invoke(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Bytes()
+	orig := append([]byte{}, b...)
+
+	parsed, err := tcap.ParseBER(b, tcap.WithPreservedEncoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+
+	otid := uint32(0xdeadbeef)
+	m.RewriteTIDs(&otid, nil)
+
+	got, err := m.ReMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{}, orig...)
+	// OTID is the first IE in Transaction Portion.
+	copy(want[4:8], []byte{0xde, 0xad, 0xbe, 0xef})
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+	if !bytes.Equal(b, orig) {
+		t.Errorf("input is modified: got %x want %x", b, orig)
+	}
+
+	// a shorter TID is rewritten in 4 octets with Length adjusted.
+	b = []byte{
+		0x65, 0x10, 0x48, 0x01, 0x01, 0x49, 0x01, 0x02,
+		0x6c, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+	}
+	parsed, err = tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = parsed[0]
+
+	dtid := uint32(0x22222222)
+	m.RewriteTIDs(&otid, &dtid)
+	got, err = m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []byte{
+		0x65, 0x16, 0x48, 0x04, 0xde, 0xad, 0xbe, 0xef, 0x49, 0x04, 0x22, 0x22, 0x22, 0x22,
+		0x6c, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+}
+
+func TestRewriteInvokeIDs(t *testing.T) {
+	b := tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+		Invoke(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).
+		ReturnResult(0, 45, nil).
+		Component(tcap.NewInvoke(2, 1, 45, true, nil)).Bytes()
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+	m.RewriteInvokeIDs(func(old int) int {
+		if old == 0 {
+			return 5
+		}
+		return old + 10
+	})
+
+	got, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := tcap.ParseBERStrict(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.InvokeID(), []uint8{5, 5, 12}; !bytes.Equal(got, want) {
+		t.Errorf("got Invoke IDs %v want %v", got, want)
+	}
+	invoke, ok := v.Components.Component[2].Typed().(*tcap.InvokeComponent)
+	if !ok {
+		t.Fatalf("got %T want *InvokeComponent", v.Components.Component[2].Typed())
+	}
+	if got, want := invoke.LinkedID, 11; got != want {
+		t.Errorf("got Linked ID %d want %d", got, want)
+	}
+	if !bytes.Equal(got[:4], b[:4]) || len(got) != len(b) {
+		t.Errorf("got %x want the same Lengths as %x", got, b)
+	}
+}
+
+func TestTIDByTag(t *testing.T) {
+	cases := []struct {
+		description string
+		b           []byte
+		otid, dtid  []byte
+	}{
+		{
+			"End",
+			[]byte{
+				0x64, 0x10, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+				0x6c, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+			},
+			nil, []byte{0x11, 0x11, 0x11, 0x11},
+		}, {
+			"Continue with short TIDs",
+			[]byte{
+				0x65, 0x11, 0x48, 0x01, 0x01, 0x49, 0x02, 0x02, 0x02,
+				0x6c, 0x08, 0xa1, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x2d,
+			},
+			[]byte{0x01}, []byte{0x02, 0x02},
+		}, {
+			"Abort",
+			[]byte{0x67, 0x09, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, 0x01},
+			nil, []byte{0x11, 0x11, 0x11, 0x11},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			ms, err := tcap.ParseBER(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := tcap.Parse(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, v := range []*tcap.TCAP{ms[0], m} {
+				if got := v.OTIDBytes(); !bytes.Equal(got, c.otid) {
+					t.Errorf("got OTID %x want %x", got, c.otid)
+				}
+				if got := v.DTIDBytes(); !bytes.Equal(got, c.dtid) {
+					t.Errorf("got DTID %x want %x", got, c.dtid)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBERInvalidMessageType(t *testing.T) {
+	b := []byte{0x30, 0x06, 0x48, 0x04, 0x11, 0x11, 0x11, 0x11}
+
+	_, err := tcap.ParseBER(b)
+	var mt *tcap.InvalidMessageTypeError
+	if !errors.As(err, &mt) {
+		t.Fatalf("ParseBER: got %v want *tcap.InvalidMessageTypeError", err)
+	}
+	if got, want := mt.Tag, tcap.Tag(0x30); got != want {
+		t.Errorf("got Tag %v want %v", got, want)
+	}
+
+	if _, err := tcap.ParseBERStrict(b); !errors.As(err, &mt) {
+		t.Errorf("ParseBERStrict: got %v want *tcap.InvalidMessageTypeError", err)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	m := tcap.NewMessage(
+		tcap.End,
+		tcap.WithDTID(0x11111111),
+		tcap.WithComponent(tcap.NewReturnResult(1, 2, true, false, []byte{0x30, 0x00})),
+		tcap.WithComponent(tcap.NewReturnResult(1, 2, true, true, []byte{0x30, 0x00})),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x64, 0x20, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x18,
+		0xa7, 0x0a, 0x02, 0x01, 0x01, 0x30, 0x05, 0x02, 0x01, 0x02, 0x30, 0x00,
+		0xa2, 0x0a, 0x02, 0x01, 0x01, 0x30, 0x05, 0x02, 0x01, 0x02, 0x30, 0x00,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	got, err := tcap.NewBeginInvoke(0x11111111, 1, 2, []byte{0x30, 0x00}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = tcap.NewMessage(
+		tcap.Begin,
+		tcap.WithOTID(0x11111111),
+		tcap.WithComponent(tcap.NewInvoke(1, -1, 2, true, []byte{0x30, 0x00})),
+	).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, b) {
+		t.Errorf("got %x want %x", got, b)
+	}
+}
+
+func TestMessageComponentMatrix(t *testing.T) {
+	const otid, dtid = 0x11111111, 0x22222222
+	ctx := tcap.LocationInfoRetrievalContext
+	cases := []struct {
+		m     *tcap.TCAP
+		mtype int
+		ctype string
+	}{
+		{tcap.NewBeginInvoke(otid, 1, 22, nil), tcap.Begin, "invoke"},
+		{tcap.NewBeginReturnResult(otid, 1, 22, true, nil), tcap.Begin, "returnResultLast"},
+		{tcap.NewBeginReturnError(otid, 1, 34, true, nil), tcap.Begin, "returnError"},
+		{tcap.NewBeginReturnErrorWithDialogue(otid, tcap.DialogueAsID, ctx, 3, 1, 34, true, nil), tcap.Begin, "returnError"},
+		{tcap.NewBeginReject(otid, 1, tcap.InvokeProblem, tcap.InvokeProblemDuplicateInvokeID), tcap.Begin, "reject"},
+		{tcap.NewContinueInvoke(otid, dtid, 1, 22, nil), tcap.Continue, "invoke"},
+		{tcap.NewContinueReturnResult(otid, dtid, 1, 22, nil), tcap.Continue, "returnResultLast"},
+		{tcap.NewContinueReturnError(otid, dtid, 1, 34, true, nil), tcap.Continue, "returnError"},
+		{tcap.NewContinueReturnErrorWithDialogue(otid, dtid, tcap.DialogueAsID, ctx, 3, 1, 34, true, nil), tcap.Continue, "returnError"},
+		{tcap.NewContinueReject(otid, dtid, 1, tcap.InvokeProblem, tcap.InvokeProblemDuplicateInvokeID), tcap.Continue, "reject"},
+		{tcap.NewEndInvoke(dtid, 1, 22, nil), tcap.End, "invoke"},
+		{tcap.NewEndReturnResult(dtid, 1, 22, true, nil), tcap.End, "returnResultLast"},
+		{tcap.NewEndReturnError(dtid, 1, 34, true, nil), tcap.End, "returnError"},
+		{tcap.NewEndReturnErrorWithDialogue(dtid, tcap.DialogueAsID, ctx, 3, 1, 34, true, nil), tcap.End, "returnError"},
+		{tcap.NewEndReject(dtid, 1, tcap.InvokeProblem, tcap.InvokeProblemDuplicateInvokeID), tcap.End, "reject"},
+	}
+
+	for _, c := range cases {
+		b, err := c.m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := tcap.ParseBERStrict(b)
+		if err != nil {
+			t.Fatalf("%d/%s: %v", c.mtype, c.ctype, err)
+		}
+		if got, want := v.MessageType(), c.mtype; got != want {
+			t.Errorf("got MessageType %d want %d", got, want)
+		}
+		verify.Values(t, v.Transaction.MessageTypeString(), v.ComponentType(), []string{c.ctype})
+		if got, want := v.HasDialogue(), c.m.Dialogue != nil; got != want {
+			t.Errorf("%d/%s: got HasDialogue %v want %v", c.mtype, c.ctype, got, want)
+		}
+	}
+}
+
+func TestParseBERAll(t *testing.T) {
+	begin := tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x00}).Bytes()
+	end := tcaptest.End().DTID(0x11111111).ReturnResult(1, 2, []byte{0x30, 0x00}).Bytes()
+
+	var b []byte
+	b = append(b, begin...)
+	b = append(b, end...)
+	parsed, err := tcap.ParseBERAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(parsed), 2; got != want {
+		t.Fatalf("got %d messages want %d", got, want)
+	}
+	if got, want := parsed[1].MessageType(), tcap.End; got != want {
+		t.Errorf("got MessageType %d want %d", got, want)
+	}
+
+	if _, err := tcap.ParseBERAll(append(b, 0x00, 0x00), tcap.WithZeroPadding()); err != nil {
+		t.Errorf("got %v with zero padding", err)
+	}
+
+	_, err = tcap.ParseBERAll(append(b, 0x62))
+	var pe *tcap.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v want *tcap.ParseError", err)
+	}
+	if got, want := pe.Offset, len(b); got != want {
+		t.Errorf("got Offset %d want %d", got, want)
+	}
+}
+
+func TestParseBERPartial(t *testing.T) {
+	full := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).
+		Invoke(1, 71, []byte{0x30, 0x00}).Invoke(2, 71, []byte{0x30, 0x00}).Bytes()
+	// the second Invoke is 0xa1 0x08 ... at the end.
+	second := len(full) - 10
+
+	m, err := tcap.ParseBERPartial(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.InvokeID(), []uint8{1, 2}; !bytes.Equal(got, want) {
+		t.Errorf("got Invoke IDs %v want %v", got, want)
+	}
+
+	cases := []struct {
+		description string
+		b           []byte
+		offset      int
+		err         error
+		invIDs      []uint8
+	}{
+		{"truncated Component", full[:len(full)-3], second, io.ErrUnexpectedEOF, []uint8{1}},
+		{"truncated at Component boundary", full[:second], second, io.ErrUnexpectedEOF, []uint8{1}},
+		{"trailing bytes", append(append([]byte{}, full...), 0x01), len(full), &tcap.TrailingBytesError{Offset: len(full), Length: 1}, []uint8{1, 2}},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			m, err := tcap.ParseBERPartial(c.b)
+			var pe *tcap.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v want *ParseError", err)
+			}
+			if pe.Offset != c.offset {
+				t.Errorf("got Offset %d want %d", pe.Offset, c.offset)
+			}
+			verify.Values(t, "Err", pe.Err, c.err)
+			if m == nil {
+				t.Fatal("got no TCAP")
+			}
+			if got, want := m.OTID(), uint32(0x11111111); got != want {
+				t.Errorf("got OTID %x want %x", got, want)
+			}
+			if !m.HasDialogue() {
+				t.Error("got no Dialogue")
+			}
+			if got := m.InvokeID(); !bytes.Equal(got, c.invIDs) {
+				t.Errorf("got Invoke IDs %v want %v", got, c.invIDs)
+			}
+		})
+	}
+
+	// the Dialogue Portion is not accepted in P-Abort, which is dropped.
+	uAbort, err := tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceUser).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict := append([]byte{0x67, uAbort[1] + 3}, uAbort[2:8]...)
+	conflict = append(append(conflict, 0x4a, 0x01, 0x04), uAbort[8:]...)
+	m, err = tcap.ParseBERPartial(conflict)
+	var pe *tcap.ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, tcap.ErrAbortReasonConflict) {
+		t.Fatalf("got %v want %v", err, tcap.ErrAbortReasonConflict)
+	}
+	if got, want := pe.Offset, 11; got != want {
+		t.Errorf("got Offset %d want %d", got, want)
+	}
+	if cause, ok := m.PAbortCause(); !ok || cause != tcap.ResourceLimitation || m.Dialogue != nil {
+		t.Errorf("got P-Abort Cause %v, Dialogue %v", cause, m.Dialogue)
+	}
+
+	if m, err := tcap.ParseBERPartial([]byte{0x30, 0x00}); m != nil || err == nil {
+		t.Errorf("got %v, %v want nil and error", m, err)
+	}
+}
+
+func TestScanTransactionIDs(t *testing.T) {
+	begin := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+		Invoke(1, 22, []byte{0x30, 0x00}).Bytes()
+	cont := []byte{0x65, 0x09, 0x48, 0x01, 0x22, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11}
+	abort := tcaptest.Abort().DTID(0x22).PAbortCause(tcap.ResourceLimitation).Bytes()
+
+	var b []byte
+	b = append(b, begin...)
+	b = append(b, cont...)
+	b = append(b, abort...)
+	got, err := tcap.ScanTransactionIDs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tcap.TIDPair{
+		{Offset: 0, Type: tcap.Begin, OTID: []byte{0x11, 0x11, 0x11, 0x11}},
+		{Offset: len(begin), Type: tcap.Continue, OTID: []byte{0x22}, DTID: []byte{0x11, 0x11, 0x11, 0x11}},
+		{Offset: len(begin) + len(cont), Type: tcap.Abort, DTID: []byte{0x00, 0x00, 0x00, 0x22}},
+	}
+	verify.Values(t, "TIDPairs", got, want)
+
+	_, err = tcap.ScanTransactionIDs(append(b, 0x30, 0x00))
+	var pe *tcap.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v want *tcap.ParseError", err)
+	}
+	if got, want := pe.Offset, len(b); got != want {
+		t.Errorf("got Offset %d want %d", got, want)
+	}
+}
+
+func TestUAbort(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0xa4, 0x02, 0x80, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceUser, ui).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parsed[0]
+	if m.Components != nil {
+		t.Errorf("got Components %v want nil", m.Components)
+	}
+	if got, want := m.AbortSource(), tcap.AbortDialogueServiceUser; got != want {
+		t.Errorf("got AbortSource %d want %d", got, want)
+	}
+	infos, err := tcap.ParseUserInformation(m.AbortUserInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := infos[0].Value, []byte{0xa4, 0x02, 0x80, 0x00}; !bytes.Equal(got, want) {
+		t.Errorf("got user-information %x want %x", got, want)
+	}
+
+	p := tcap.NewUAbort(0x11111111, tcap.AbortDialogueServiceProvider)
+	if got, want := p.AbortSource(), tcap.AbortDialogueServiceProvider; got != want {
+		t.Errorf("got AbortSource %d want %d", got, want)
+	}
+	if p.AbortUserInfo() != nil {
+		t.Errorf("got AbortUserInfo %v want nil", p.AbortUserInfo())
+	}
+
+	pAbort := tcap.AbortFromParseError(0x11111111, nil)
+	if got, want := pAbort.AbortSource(), tcap.AbortSource(-1); got != want {
+		t.Errorf("got AbortSource %d want %d for P-Abort", got, want)
+	}
+}
+
+func TestNewPartyAddress(t *testing.T) {
+	p, err := tcap.NewPartyAddress(tcap.SSNHLR, "12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x08, 0x12, 0x06, 0x00, 0x11, 0x04, 0x21, 0x43, 0x05}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+}
+
+func TestMarshalWithForceLongFormLength(t *testing.T) {
+	m := tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x03, 0x04, 0x01, 0xff}).Message()
+
+	short, err := m.MarshalWith()
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := m.MarshalWith(tcap.ForceLongFormLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x62, 0x81, 0x1c,
+		0x48, 0x81, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x6c, 0x81, 0x12, 0xa1, 0x81, 0x0f,
+		0x02, 0x81, 0x01, 0x01, 0x02, 0x81, 0x01, 0x02, 0x30, 0x81, 0x04, 0x04, 0x81, 0x01, 0xff,
+	}
+	if !bytes.Equal(long, want) {
+		t.Errorf("got %x want %x", long, want)
+	}
+
+	parsed, err := tcap.ParseBER(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parsed[0]
+	if got, want := p.OTID(), m.OTID(); got != want {
+		t.Errorf("got OTID %#x want %#x", got, want)
+	}
+	verify.Values(t, "OperationCodes", p.OperationCodes(), []int{2})
+	if param := p.Components.Component[0].Parameter; len(param.IE) != 1 || !bytes.Equal(param.IE[0].Value, []byte{0xff}) {
+		t.Errorf("got Parameter %v", param)
+	}
+
+	if bytes.Equal(short, long) {
+		t.Errorf("got long form without the option: %x", short)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	m := tcaptest.Begin().OTID(0x11111111).Invoke(1, 2, []byte{0x30, 0x03, 0x04, 0x01, 0xff}).Message()
+	short, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := m.MarshalWith(tcap.ForceLongFormLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.ParseBER(long, tcap.WithPreservedEncoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrans := parsed[0]
+	canonical, err := retrans.MarshalCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(canonical, short) {
+		t.Errorf("got %x want %x", canonical, short)
+	}
+
+	want, err := m.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := retrans.Fingerprint(); err != nil || got != want {
+		t.Errorf("got %#x, %v want %#x", got, err, want)
+	}
+
+	other := tcaptest.Begin().OTID(0x11111112).Invoke(1, 2, []byte{0x30, 0x03, 0x04, 0x01, 0xff}).Message()
+	if got, err := other.Fingerprint(); err != nil || got == want {
+		t.Errorf("got %#x, %v want different from %#x", got, err, want)
+	}
+}
+
+func TestEndWithDialogue(t *testing.T) {
+	param := []byte{0x30, 0x03, 0x04, 0x01, 0x01}
+	m := tcap.NewEndReturnResultWithDialogue(
+		0x11111111, tcap.DialogueAsID, tcap.LocationCancellationContext, 3, 1, 3, true, param,
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parsed[0]
+	if got, want := p.MessageType(), tcap.End; got != want {
+		t.Errorf("got MessageType %d want %d", got, want)
+	}
+	if !p.HasDialogue() {
+		t.Fatal("got no Dialogue Portion")
+	}
+	if got, want := p.Dialogue.DialoguePDU.Type.Code(), tcap.AARE; got != want {
+		t.Errorf("got Dialogue PDU %d want AARE", got)
+	}
+	if result, ok := p.DialogueResult(); !ok || result != tcap.Accepted {
+		t.Errorf("got DialogueResult (%d, %v) want (%d, true)", result, ok, tcap.Accepted)
+	}
+	if got, want := p.AppContextNameWithVersion(), "locationCancellationContext-v3"; got != want {
+		t.Errorf("got ACN %s want %s", got, want)
+	}
+	if got, want := p.ComponentType(), []string{"returnResultLast"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got ComponentType %v want %v", got, want)
+	}
+	if got := p.Components.Component[0].ParameterBytes(); !bytes.Equal(got, param) {
+		t.Errorf("got Parameter %x want %x", got, param)
+	}
+
+	re, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+
+	if _, ok := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationCancellationContext, 3).Message().DialogueResult(); ok {
+		t.Error("got DialogueResult for AARQ")
+	}
+}
+
+func TestComponentsInAbort(t *testing.T) {
+	invoke := tcap.NewInvoke(1, -1, 56, true, []byte{0x04, 0x01, 0x01})
+
+	m := tcap.NewMessage(tcap.Abort, tcap.WithDTID(0x11111111), tcap.WithPAbortCause(tcap.ResourceLimitation), tcap.WithComponent(invoke))
+	if m.Components != nil {
+		t.Errorf("got Component Portion in Abort by default: %v", m.Components)
+	}
+
+	m = tcap.NewMessage(
+		tcap.Abort,
+		tcap.WithDTID(0x11111111),
+		tcap.WithPAbortCause(tcap.ResourceLimitation),
+		tcap.WithComponent(invoke),
+		tcap.AllowComponentsInAbort(),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x67, 0x16,
+		0x49, 0x04, 0x11, 0x11, 0x11, 0x11,
+		0x4a, 0x01, 0x04,
+		0x6c, 0x0b,
+		0xa1, 0x09, 0x02, 0x01, 0x01, 0x02, 0x01, 0x38, 0x04, 0x01, 0x01,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed[0].Components != nil {
+		t.Errorf("got Component Portion %v want ignored", parsed[0].Components)
+	}
+
+	if _, err := tcap.ParseBERStrict(b, tcap.WithStrictComponents()); err != tcap.ErrComponentsInAbort {
+		t.Errorf("got %v want %v", err, tcap.ErrComponentsInAbort)
+	}
+}
+
+var (
+	benchBegin = tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.LocationInfoRetrievalContext, 3).
+			Invoke(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Message()
+	// benchLarge is a Continue close to the size that fits in the short form
+	// of Length, with multiple Components.
+	benchLarge = tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).
+			Invoke(1, 45, bytes.Repeat([]byte{0x04, 0x08, 1, 2, 3, 4, 5, 6, 7, 8}, 4)).
+			Invoke(2, 45, bytes.Repeat([]byte{0x04, 0x08, 1, 2, 3, 4, 5, 6, 7, 8}, 4)).
+			ReturnResult(0, 22, []byte{0x30, 0x03, 0x80, 0x01, 0x00}).Message()
+)
+
+// parseAllocsBudget is the allocations per ParseBER of benchBegin, which is not
+// to be exceeded by the changes in the parser.
+const parseAllocsBudget = 30
+
+func TestOIDs(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0xa0, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := tcap.NewReturnErrorWithOID(2, "1.2.3.4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := tcap.NewMessage(
+		tcap.Begin,
+		tcap.WithOTID(0x11111111),
+		tcap.WithDialogue(tcap.NewDialogue(tcap.DialogueAsID, 1, tcap.NewAARQ(1, 2, 3, ui), []byte{})),
+		// the Parameter is a SEQUENCE with an OID inside.
+		tcap.WithComponent(tcap.NewInvoke(1, -1, 56, true, []byte{0x30, 0x04, 0x06, 0x02, 0x2a, 0x03})),
+		tcap.WithComponent(re),
+	)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		tcap.DialogueOID(tcap.DialogueAsID),
+		"0.4.0.0.1.0.2.3",
+		"0.4.0.0.1.1.1.1",
+		"1.2.3",
+		"1.2.3.4",
+	}
+	fromBER, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*tcap.TCAP{m, fromBER[0], fromBytes} {
+		verify.Values(t, "OIDs", p.OIDs(), want)
+	}
+}
+
+func TestParseNonMinimalTIDLength(t *testing.T) {
+	cases := []struct {
+		description string
+		b           []byte
+		dtid        uint32
+	}{
+		{
+			"DTID",
+			[]byte{0x64, 0x0e, 0x49, 0x81, 0x04, 0x11, 0x22, 0x33, 0x44, 0x6c, 0x05, 0xa2, 0x03, 0x02, 0x01, 0x00},
+			0x11223344,
+		},
+		{
+			"OTID and DTID",
+			[]byte{
+				0x65, 0x18,
+				0x48, 0x81, 0x04, 0x11, 0x22, 0x33, 0x44,
+				0x49, 0x82, 0x00, 0x04, 0x55, 0x66, 0x77, 0x88,
+				0x6c, 0x05, 0xa2, 0x03, 0x02, 0x01, 0x00,
+			},
+			0x55667788,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			m, err := tcap.Parse(c.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m.DTID(), c.dtid; got != want {
+				t.Errorf("got DTID %x want %x", got, want)
+			}
+			if m.Components == nil || len(m.Components.Component) != 1 {
+				t.Fatalf("got Components %v want one ReturnResultLast", m.Components)
+			}
+			if got, want := m.InvokeID(), []uint8{0}; !verify.Values(t, "", got, want) {
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestLongFormLength(t *testing.T) {
+	payload := make([]byte, 400)
+	for n := range payload {
+		payload[n] = uint8(n)
+	}
+	param := append([]byte{0x04, 0x82, 0x01, 0x90}, payload...)
+
+	m := tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueAsID, tcap.AnyTimeInfoEnquiryContext, 3, 1, 71, param)
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(b), m.MarshalLen(); got != want {
+		t.Fatalf("got %d octets want %d", got, want)
+	}
+	if !bytes.HasSuffix(b, param) {
+		t.Fatalf("got %x want suffix %x", b, param)
+	}
+	// the message, Component Portion and Invoke are also in the long form.
+	if got, want := b[:4], []byte{0x62, 0x82, 0x01, uint8(len(b) - 4)}; !bytes.Equal(got, want) {
+		t.Errorf("got %x want %x", got, want)
+	}
+
+	fromBER, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := tcap.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*tcap.TCAP{fromBER[0], fromBytes} {
+		if got := p.LayerPayload(); len(got) != 1 || !bytes.Equal(got[0], payload) {
+			t.Errorf("got payload %x want %x", got, payload)
+		}
+		if got, want := p.AppContextName(), m.AppContextName(); got != want {
+			t.Errorf("got ACN %s want %s", got, want)
+		}
+	}
+
+	re, err := fromBER[0].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(re, b) {
+		t.Errorf("got %x want %x", re, b)
+	}
+}
+
+func TestParseBERAllocsBudget(t *testing.T) {
+	b, err := benchBegin.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := testing.AllocsPerRun(100, func() {
+		if _, err := tcap.ParseBER(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n > parseAllocsBudget {
+		t.Errorf("got %v allocations per run want %d or less", n, parseAllocsBudget)
+	}
+}
+
+func BenchmarkMarshalBegin(b *testing.B) {
+	buf := make([]byte, benchBegin.MarshalLen())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := benchBegin.MarshalTo(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseBegin(b *testing.B) {
+	msg, err := benchBegin.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tcap.ParseBER(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseLargeMessage(b *testing.B) {
+	msg, err := benchLarge.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		if _, err := tcap.ParseBER(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRoundTrip(b *testing.B) {
+	segments, err := tcap.SplitResponse(0x11111111, 0x22222222, 1, 56, bytes.Repeat([]byte{0xff}, 1000), 129)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cases := []struct {
+		description string
+		msgs        []*tcap.TCAP
+	}{
+		{"small", []*tcap.TCAP{benchBegin}},
+		{"large", []*tcap.TCAP{benchLarge}},
+		{"segmented", segments},
+	}
+
+	for _, c := range cases {
+		b.Run(c.description, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, m := range c.msgs {
+					msg, err := m.MarshalBinary()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := tcap.ParseBER(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
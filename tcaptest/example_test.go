@@ -0,0 +1,119 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcaptest_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+// MAP Operation Codes used in the example.
+const (
+	opUpdateLocation       = 2
+	opInsertSubscriberData = 7
+)
+
+// This example runs the MAP updateLocation procedure between a VLR and an HLR
+// over Pipe: the VLR begins the dialogue with updateLocation, the HLR sends
+// the subscriber data with insertSubscriberData in a Continue, and ends the
+// dialogue with the result of updateLocation after the VLR acknowledges it.
+func Example_updateLocation() {
+	vlr, hlr := tcaptest.Pipe()
+	defer vlr.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runHLR(hlr, 0x22222222)
+	}()
+
+	// UpdateLocationArg ::= SEQUENCE { imsi, msc-Number [1], vlr-Number }
+	arg := []byte{
+		0x30, 0x16,
+		0x04, 0x08, 0x00, 0x01, 0x10, 0x10, 0x32, 0x54, 0x76, 0xf8,
+		0x81, 0x04, 0x91, 0x21, 0x43, 0x65,
+		0x04, 0x04, 0x91, 0x21, 0x43, 0x66,
+	}
+	begin := tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueAsID, tcap.NetworkLocUpContext, 3, 1, opUpdateLocation, arg)
+	if err := vlr.Send(begin); err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		m, err := vlr.Receive()
+		if err != nil {
+			log.Fatal(err)
+		}
+		printReceived("VLR", m)
+		if m.MessageType() == tcap.End {
+			break
+		}
+
+		// acknowledge insertSubscriberData with the empty result.
+		invID := int(m.InvokeID()[0])
+		ack, err := tcap.ReplyContinue(m, 0x11111111, tcap.NewReturnResult(invID, opInsertSubscriberData, true, true, []byte{0x30, 0x00}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := vlr.Send(ack); err != nil {
+			log.Fatal(err)
+		}
+	}
+	<-done
+
+	// Output:
+	// HLR received Begin [invoke] [2]
+	// VLR received Continue [invoke] [7]
+	// HLR received Continue [returnResultLast] [7]
+	// VLR received End [returnResultLast] [2]
+}
+
+// runHLR serves a single updateLocation dialogue on l, with otid as its local
+// Transaction ID.
+func runHLR(l *tcaptest.Loopback, otid uint32) {
+	begin, err := l.Receive()
+	if err != nil {
+		log.Fatal(err)
+	}
+	printReceived("HLR", begin)
+	ulInvID := int(begin.InvokeID()[0])
+
+	// InsertSubscriberDataArg ::= SEQUENCE { imsi [0], msisdn [1] }
+	isd := []byte{
+		0x30, 0x10,
+		0x80, 0x08, 0x00, 0x01, 0x10, 0x10, 0x32, 0x54, 0x76, 0xf8,
+		0x81, 0x04, 0x91, 0x21, 0x43, 0x67,
+	}
+	cont, err := tcap.ReplyContinue(begin, otid, tcap.NewInvoke(ulInvID+1, -1, opInsertSubscriberData, true, isd))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := l.Send(cont); err != nil {
+		log.Fatal(err)
+	}
+
+	ack, err := l.Receive()
+	if err != nil {
+		log.Fatal(err)
+	}
+	printReceived("HLR", ack)
+
+	// UpdateLocationRes ::= SEQUENCE { hlr-Number }
+	res := []byte{0x30, 0x06, 0x04, 0x04, 0x91, 0x21, 0x43, 0x68}
+	end, err := tcap.ReplyEnd(ack, tcap.NewReturnResult(ulInvID, opUpdateLocation, true, true, res))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := l.Send(end); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printReceived(node string, m *tcap.TCAP) {
+	fmt.Println(node, "received", m.Transaction.MessageTypeString(), m.ComponentType(), m.OperationCodes())
+}
@@ -0,0 +1,52 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcaptest
+
+import (
+	"net"
+
+	"github.com/hdddl/go-tcap"
+)
+
+// Loopback is one end of an in-memory connection that carries TCAPs framed in
+// the same way as tcap.WriteFramed, which lets the client and the server of a
+// dialogue talk in a single test without SCCP.
+//
+// The connection is synchronous like net.Pipe, i.e., Send blocks until the
+// other end Receives, so the two ends are to be used in separate goroutines.
+// Send and Receive are not safe for concurrent use on the same end.
+type Loopback struct {
+	conn net.Conn
+	enc  *tcap.Encoder
+}
+
+// Pipe creates a pair of connected Loopbacks.
+func Pipe() (*Loopback, *Loopback) {
+	c1, c2 := net.Pipe()
+	return newLoopback(c1), newLoopback(c2)
+}
+
+func newLoopback(conn net.Conn) *Loopback {
+	enc := tcap.NewEncoder(conn)
+	enc.SetFraming(true)
+	return &Loopback{conn: conn, enc: enc}
+}
+
+// Send sends the TCAP given as t to the other end.
+func (l *Loopback) Send(t *tcap.TCAP) error {
+	return l.enc.Encode(t)
+}
+
+// Receive receives a TCAP sent from the other end, which is parsed with the
+// opts given. It returns io.EOF if the other end is closed.
+func (l *Loopback) Receive(opts ...tcap.ParseOption) (*tcap.TCAP, error) {
+	return tcap.ReadFramed(l.conn, opts...)
+}
+
+// Close closes the Loopback, which makes the pending and following Send and
+// Receive on both ends fail.
+func (l *Loopback) Close() error {
+	return l.conn.Close()
+}
@@ -0,0 +1,63 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcaptest_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+func TestLoopback(t *testing.T) {
+	client, server := tcaptest.Pipe()
+	payload := []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}
+
+	errc := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		begin, err := server.Receive()
+		if err != nil {
+			errc <- err
+			return
+		}
+		invID := int(begin.InvokeID()[0])
+		end, err := tcap.ReplyEnd(begin, tcap.NewReturnResult(invID, 71, true, true, payload))
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- server.Send(end)
+	}()
+
+	begin := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).Invoke(1, 71, payload).Message()
+	if err := client.Send(begin); err != nil {
+		t.Fatal(err)
+	}
+	end, err := client.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := end.MessageType(), tcap.End; got != want {
+		t.Errorf("got message type %d want %d", got, want)
+	}
+	if got, want := end.DTID(), uint32(0x11111111); got != want {
+		t.Errorf("got DTID %x want %x", got, want)
+	}
+	// the contents of the result SEQUENCE.
+	if got, want := end.LayerPayload(), payload[2:]; len(got) != 1 || !bytes.Equal(got[0], want) {
+		t.Errorf("got payload %x want %x", got, want)
+	}
+
+	if _, err := client.Receive(); err != io.EOF {
+		t.Errorf("got %v want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+/*
+Package tcaptest provides a fluent builder of TCAP messages for tests.
+
+	b := tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).Invoke(0, 71, payload).Bytes()
+
+It also provides Pipe, the in-memory Loopback pair to exchange TCAPs between
+the client and the server in a single test.
+*/
+package tcaptest
+
+import (
+	"github.com/hdddl/go-tcap"
+)
+
+// Builder builds a TCAP message step by step.
+type Builder struct {
+	mtype      int
+	opts       []tcap.MessageOption
+	hasDlg     bool
+	ctx, ver   uint8
+	components []*tcap.Component
+}
+
+func newBuilder(mtype int) *Builder {
+	return &Builder{mtype: mtype}
+}
+
+// Unidirectional starts building a Unidirectional message.
+func Unidirectional() *Builder { return newBuilder(tcap.Unidirectional) }
+
+// Begin starts building a Begin message.
+func Begin() *Builder { return newBuilder(tcap.Begin) }
+
+// End starts building an End message.
+func End() *Builder { return newBuilder(tcap.End) }
+
+// Continue starts building a Continue message.
+func Continue() *Builder { return newBuilder(tcap.Continue) }
+
+// Abort starts building an Abort message.
+func Abort() *Builder { return newBuilder(tcap.Abort) }
+
+// OTID sets the Originating Transaction ID.
+func (b *Builder) OTID(otid uint32) *Builder {
+	b.opts = append(b.opts, tcap.WithOTID(otid))
+	return b
+}
+
+// DTID sets the Destination Transaction ID.
+func (b *Builder) DTID(dtid uint32) *Builder {
+	b.opts = append(b.opts, tcap.WithDTID(dtid))
+	return b
+}
+
+// PAbortCause sets the P-Abort Cause.
+func (b *Builder) PAbortCause(cause tcap.PAbortCause) *Builder {
+	b.opts = append(b.opts, tcap.WithPAbortCause(cause))
+	return b
+}
+
+// Dialogue sets the Dialogue Portion with the application context ctx of
+// version ver. The Dialogue PDU is AARQ for Unidirectional and Begin, and
+// accepted AARE for the others.
+func (b *Builder) Dialogue(ctx, ver uint8) *Builder {
+	b.hasDlg = true
+	b.ctx, b.ver = ctx, ver
+	return b
+}
+
+// Invoke adds an Invoke Component with a local operation code.
+func (b *Builder) Invoke(invID, opCode int, payload []byte) *Builder {
+	return b.Component(tcap.NewInvoke(invID, -1, opCode, true, payload))
+}
+
+// ReturnResult adds a ReturnResultLast Component with a local operation code.
+func (b *Builder) ReturnResult(invID, opCode int, payload []byte) *Builder {
+	return b.Component(tcap.NewReturnResult(invID, opCode, true, true, payload))
+}
+
+// ReturnError adds a ReturnError Component with a local error code.
+func (b *Builder) ReturnError(invID, errCode int, param []byte) *Builder {
+	return b.Component(tcap.NewReturnError(invID, errCode, true, param))
+}
+
+// Reject adds a Reject Component.
+func (b *Builder) Reject(invID, problemType int, problemCode uint8) *Builder {
+	return b.Component(tcap.NewReject(invID, problemType, problemCode, nil))
+}
+
+// Component adds an arbitrary Component.
+func (b *Builder) Component(c *tcap.Component) *Builder {
+	b.components = append(b.components, c)
+	return b
+}
+
+// Message returns the TCAP built.
+func (b *Builder) Message() *tcap.TCAP {
+	opts := append([]tcap.MessageOption{}, b.opts...)
+	if b.hasDlg {
+		opts = append(opts, tcap.WithDialogue(b.dialogue()))
+	}
+	for _, c := range b.components {
+		opts = append(opts, tcap.WithComponent(c))
+	}
+	return tcap.NewMessage(b.mtype, opts...)
+}
+
+// Bytes returns the TCAP built in bytes. It panics if the TCAP cannot be
+// serialized, which is not expected in tests.
+func (b *Builder) Bytes() []byte {
+	x, err := b.Message().MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func (b *Builder) dialogue() *tcap.Dialogue {
+	switch b.mtype {
+	case tcap.Unidirectional:
+		return tcap.NewDialogue(tcap.UnidialogueAsID, 1, tcap.NewAARQ(1, b.ctx, b.ver), []byte{})
+	case tcap.Begin:
+		return tcap.NewDialogue(tcap.DialogueAsID, 1, tcap.NewAARQ(1, b.ctx, b.ver), []byte{})
+	default:
+		return tcap.NewDialogue(tcap.DialogueAsID, 1, tcap.NewAARE(1, b.ctx, b.ver, tcap.Accepted, tcap.DialogueServiceUser, tcap.Null), []byte{})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcaptest_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+	"github.com/hdddl/go-tcap/tcaptest"
+)
+
+func TestBuilder(t *testing.T) {
+	payload := []byte{0x30, 0x04, 0xde, 0xad, 0xbe, 0xef}
+
+	cases := []struct {
+		description string
+		got         []byte
+		want        *tcap.TCAP
+	}{
+		{
+			"Begin - AARQ - Invoke",
+			tcaptest.Begin().OTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).Invoke(0, 71, payload).Bytes(),
+			tcap.NewBeginInvokeWithDialogue(0x11111111, tcap.DialogueAsID, tcap.AnyTimeInfoEnquiryContext, 3, 0, 71, payload),
+		}, {
+			"End - AARE - ReturnResultLast",
+			tcaptest.End().DTID(0x11111111).Dialogue(tcap.AnyTimeInfoEnquiryContext, 3).ReturnResult(0, 71, payload).Bytes(),
+			tcap.NewEndReturnResultWithDialogue(0x11111111, tcap.DialogueAsID, tcap.AnyTimeInfoEnquiryContext, 3, 0, 71, true, payload),
+		}, {
+			"Continue - Invoke",
+			tcaptest.Continue().OTID(0x11111111).DTID(0x22222222).Invoke(1, 61, payload).Bytes(),
+			tcap.NewContinueInvoke(0x11111111, 0x22222222, 1, 61, payload),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			want, err := c.want.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(c.got, want) {
+				t.Errorf("got %x want %x", c.got, want)
+			}
+		})
+	}
+}
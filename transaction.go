@@ -7,6 +7,7 @@ package tcap
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 // Message Type definitions.
@@ -20,19 +21,56 @@ const (
 	Abort
 )
 
+// Tag definitions of TCAP messages and the IEs in them.
+const (
+	TagUnidirectional   Tag = 0x61
+	TagBegin            Tag = 0x62
+	TagEnd              Tag = 0x64
+	TagContinue         Tag = 0x65
+	TagAbort            Tag = 0x67
+	TagOriginatingTID   Tag = 0x48
+	TagDestinationTID   Tag = 0x49
+	TagPAbortCause      Tag = 0x4a
+	TagDialoguePortion  Tag = 0x6b
+	TagComponentPortion Tag = 0x6c
+)
+
 // Abort Cause definitions.
 const (
-	UnrecognizedMessageType uint8 = iota
+	UnrecognizedMessageType PAbortCause = iota
 	UnrecognizedTransactionID
 	BadlyFormattedTransactionPortion
 	IncorrectTransactionPortion
 	ResourceLimitation
 )
 
+// PAbortCause is the P-Abort Cause in the Transaction Portion of P-Abort.
+type PAbortCause uint8
+
+// String returns the PAbortCause in the name defined in ASN.1 of Q.773.
+func (c PAbortCause) String() string {
+	switch c {
+	case UnrecognizedMessageType:
+		return "unrecognizedMessageType"
+	case UnrecognizedTransactionID:
+		return "unrecognizedTransactionID"
+	case BadlyFormattedTransactionPortion:
+		return "badlyFormattedTransactionPortion"
+	case IncorrectTransactionPortion:
+		return "incorrectTransactionPortion"
+	case ResourceLimitation:
+		return "resourceLimitation"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(c))
+}
+
 // Transaction represents a Transaction Portion of TCAP.
+//
+// It can be built and parsed on its own with NewTransactionWithTIDs and
+// ParseTransaction, apart from the Dialogue and Component Portions in Payload.
 type Transaction struct {
 	Type              Tag
-	Length            uint8
+	Length            int
 	OrigTransactionID *IE
 	DestTransactionID *IE
 	PAbortCause       *IE
@@ -40,7 +78,7 @@ type Transaction struct {
 }
 
 // NewTransaction returns a new Transaction Portion.
-func NewTransaction(mtype int, otid, dtid uint32, cause uint8, payload []byte) *Transaction {
+func NewTransaction(mtype int, otid, dtid uint32, cause PAbortCause, payload []byte) *Transaction {
 	t := &Transaction{
 		Type: NewApplicationWideConstructorTag(mtype),
 		OrigTransactionID: &IE{
@@ -53,7 +91,7 @@ func NewTransaction(mtype int, otid, dtid uint32, cause uint8, payload []byte) *
 		},
 		PAbortCause: &IE{
 			Tag:   NewApplicationWidePrimitiveTag(10),
-			Value: []byte{cause},
+			Value: []byte{uint8(cause)},
 		},
 		Payload: payload,
 	}
@@ -64,6 +102,37 @@ func NewTransaction(mtype int, otid, dtid uint32, cause uint8, payload []byte) *
 	return t
 }
 
+// NewTransactionWithTIDs returns a new Transaction Portion of the Message Type
+// given as mtype, with the TIDs given in bytes as they are, which can be 1 to 4
+// octets. The TID given as nil is left absent, as well as the P-Abort Cause.
+//
+// It returns ErrInvalidTransactionID if the TID given is not 1 to 4 octets.
+func NewTransactionWithTIDs(mtype int, otid, dtid []byte) (*Transaction, error) {
+	for _, tid := range [][]byte{otid, dtid} {
+		if tid != nil && (len(tid) < 1 || len(tid) > 4) {
+			return nil, ErrInvalidTransactionID
+		}
+	}
+	return newTransaction(mtype, otid, dtid), nil
+}
+
+// newTransaction is NewTransactionWithTIDs without checking the TIDs.
+func newTransaction(mtype int, otid, dtid []byte) *Transaction {
+	t := &Transaction{
+		Type:    NewApplicationWideConstructorTag(mtype),
+		Payload: []byte{},
+	}
+	if otid != nil {
+		t.OrigTransactionID = NewIE(NewApplicationWidePrimitiveTag(8), append([]byte{}, otid...))
+	}
+	if dtid != nil {
+		t.DestTransactionID = NewIE(NewApplicationWidePrimitiveTag(9), append([]byte{}, dtid...))
+	}
+	t.SetLength()
+
+	return t
+}
+
 // NewUnidirectional returns Unidirectional type of Transacion Portion.
 func NewUnidirectional(payload []byte) *Transaction {
 	t := NewTransaction(
@@ -125,7 +194,7 @@ func NewContinue(otid, dtid uint32, payload []byte) *Transaction {
 }
 
 // NewAbort returns Abort type of Transacion Portion.
-func NewAbort(dtid uint32, cause uint8, payload []byte) *Transaction {
+func NewAbort(dtid uint32, cause PAbortCause, payload []byte) *Transaction {
 	t := NewTransaction(
 		Abort,   // Type: Abort
 		0,       // otid
@@ -160,9 +229,7 @@ func (t *Transaction) MarshalBinary() ([]byte, error) {
 // MarshalTo puts the byte sequence in the byte array given as b.
 func (t *Transaction) MarshalTo(b []byte) error {
 	b[0] = uint8(t.Type)
-	b[1] = t.Length
-
-	var offset = 2
+	var offset = 1 + putLength(b[1:], t.Length, t.lengthOctets())
 	switch t.Type.Code() {
 	case Unidirectional:
 		break
@@ -223,49 +290,40 @@ func ParseTransaction(b []byte) (*Transaction, error) {
 }
 
 // UnmarshalBinary sets the values retrieved from byte sequence in an Transaction.
+//
+// The Transaction IDs and P-Abort Cause are identified by their Tags rather than
+// their positions, regardless of the Message Type.
 func (t *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 {
+		return io.ErrUnexpectedEOF
+	}
+	offset, length, err := decodeLength(b)
+	if err != nil {
+		return err
+	}
 	t.Type = Tag(b[0])
-	t.Length = b[1]
-
-	var err error
-	var offset = 2
-	switch t.Type.Code() {
-	case Unidirectional:
-		break
-	case Begin:
-		t.OrigTransactionID, err = ParseIE(b[offset : offset+6])
-		if err != nil {
-			return err
+	t.Length = length
+
+	for offset < len(b) {
+		var field **IE
+		switch Tag(b[offset]) {
+		case TagOriginatingTID:
+			field = &t.OrigTransactionID
+		case TagDestinationTID:
+			field = &t.DestTransactionID
+		case TagPAbortCause:
+			field = &t.PAbortCause
 		}
-		offset += t.OrigTransactionID.MarshalLen()
-	case End:
-		t.DestTransactionID, err = ParseIE(b[offset : offset+6])
-		if err != nil {
-			return err
-		}
-		offset += t.DestTransactionID.MarshalLen()
-	case Continue:
-		t.OrigTransactionID, err = ParseIE(b[offset : offset+6])
-		if err != nil {
-			return err
-		}
-		offset += t.OrigTransactionID.MarshalLen()
-		t.DestTransactionID, err = ParseIE(b[offset : offset+6])
-		if err != nil {
-			return err
-		}
-		offset += t.DestTransactionID.MarshalLen()
-	case Abort:
-		t.DestTransactionID, err = ParseIE(b[offset : offset+6])
-		if err != nil {
-			return err
+		if field == nil {
+			break
 		}
-		offset += t.DestTransactionID.MarshalLen()
-		t.PAbortCause, err = ParseIE(b[offset : offset+3])
+
+		ie, err := ParseIE(b[offset:])
 		if err != nil {
 			return err
 		}
-		offset += t.PAbortCause.MarshalLen()
+		*field = ie
+		offset += encodedLen(b[offset:], ie)
 	}
 	t.Payload = b[offset:]
 	return nil
@@ -277,11 +335,11 @@ func (t *Transaction) SetValsFrom(berParsed *IE) error {
 	t.Length = berParsed.Length
 	for _, ie := range berParsed.IE {
 		switch ie.Tag {
-		case 0x48:
+		case TagOriginatingTID:
 			t.OrigTransactionID = ie
-		case 0x49:
+		case TagDestinationTID:
 			t.DestTransactionID = ie
-		case 0x4a:
+		case TagPAbortCause:
 			t.PAbortCause = ie
 		}
 	}
@@ -290,7 +348,23 @@ func (t *Transaction) SetValsFrom(berParsed *IE) error {
 
 // MarshalLen returns the serial length of Transaction.
 func (t *Transaction) MarshalLen() int {
-	l := 2
+	return 1 + t.lengthOctets() + t.contentLen()
+}
+
+// lengthOctets returns the number of the Length octets of Transaction, which
+// can be more than the contents of Transaction needs, as the Length covers the
+// other portions in TCAP.
+func (t *Transaction) lengthOctets() int {
+	if l := t.contentLen(); l > t.Length {
+		return lengthLen(l)
+	}
+	return lengthLen(t.Length)
+}
+
+// contentLen returns the length of the contents of Transaction, including the
+// Payload.
+func (t *Transaction) contentLen() int {
+	var l int
 	switch t.Type.Code() {
 	case Unidirectional:
 		break
@@ -331,7 +405,7 @@ func (t *Transaction) SetLength() {
 	if field := t.PAbortCause; field != nil {
 		field.SetLength()
 	}
-	t.Length = uint8(t.MarshalLen() - 2)
+	t.Length = t.contentLen()
 }
 
 // MessageTypeString returns the name of Message Type in string.
@@ -376,12 +450,12 @@ func (t *Transaction) DTID() string {
 // AbortCause returns the P-Abort Cause in string.
 func (t *Transaction) AbortCause() string {
 	cause := t.PAbortCause
-	if cause == nil {
+	if cause == nil || len(cause.Value) == 0 {
 		return ""
 	}
 
 	if t.Type.Code() == Abort {
-		switch t.PAbortCause.Value[0] {
+		switch PAbortCause(cause.Value[0]) {
 		case UnrecognizedMessageType:
 			return "UnrecognizedMessageType"
 		case UnrecognizedTransactionID:
@@ -399,7 +473,7 @@ func (t *Transaction) AbortCause() string {
 
 // String returns Transaction in human readable string.
 func (t *Transaction) String() string {
-	return fmt.Sprintf("{Type: %#x, Length: %d, OrigTransactionID: %v, DestTransactionID: %v, PAbortCause: %v, Payload: %x}",
+	return fmt.Sprintf("{Type: %v, Length: %d, OrigTransactionID: %v, DestTransactionID: %v, PAbortCause: %v, Payload: %x}",
 		t.Type,
 		t.Length,
 		t.OrigTransactionID,
@@ -408,3 +482,37 @@ func (t *Transaction) String() string {
 		t.Payload,
 	)
 }
+
+// NewTransactionIDBytes returns the TID given as b in the minimal form, i.e.,
+// without the leading zero octets but at least 1 octet, so that the TIDs of
+// different lengths with the same value, e.g., 0x01 and 0x00000001, result in
+// the same octets. It is useful as the key of a transaction table.
+//
+// It returns ErrInvalidTransactionID if b is not 1 to 4 octets.
+func NewTransactionIDBytes(b []byte) ([]byte, error) {
+	if len(b) < 1 || len(b) > 4 {
+		return nil, ErrInvalidTransactionID
+	}
+
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return append([]byte{}, b...), nil
+}
+
+// CompareTransactionIDs compares the TIDs given as a and b as big-endian
+// unsigned integers regardless of their lengths, and returns 0 if a == b, -1 if
+// a < b, and +1 if a > b. The octets after the first 4 are ignored as in OTID.
+//
+// To match a received TID against the one stored as uint32, compare it with the
+// 4 octets of the uint32, e.g., from binary.BigEndian.PutUint32.
+func CompareTransactionIDs(a, b []byte) int {
+	x, y := tidToUint32(a), tidToUint32(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestTransactionWithTIDs(t *testing.T) {
+	cases := []struct {
+		description string
+		mtype       int
+		otid, dtid  []byte
+		want        []byte
+	}{
+		{
+			"Begin with 1-octet OTID",
+			tcap.Begin, []byte{0x01}, nil,
+			[]byte{0x62, 0x03, 0x48, 0x01, 0x01},
+		}, {
+			"Continue with both TIDs of different lengths",
+			tcap.Continue, []byte{0x11, 0x11}, []byte{0x22, 0x22, 0x22},
+			[]byte{0x65, 0x09, 0x48, 0x02, 0x11, 0x11, 0x49, 0x03, 0x22, 0x22, 0x22},
+		}, {
+			"End with 4-octet DTID",
+			tcap.End, nil, []byte{0x11, 0x11, 0x11, 0x11},
+			[]byte{0x64, 0x06, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tx, err := tcap.NewTransactionWithTIDs(c.mtype, c.otid, c.dtid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := tx.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.want) {
+				t.Errorf("got %x want %x", b, c.want)
+			}
+
+			parsed, err := tcap.ParseTransaction(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.Type.Code(); got != c.mtype {
+				t.Errorf("got Type %d want %d", got, c.mtype)
+			}
+			if c.otid != nil && !bytes.Equal(parsed.OrigTransactionID.Value, c.otid) {
+				t.Errorf("got OTID %x want %x", parsed.OrigTransactionID.Value, c.otid)
+			}
+			if c.dtid != nil && !bytes.Equal(parsed.DestTransactionID.Value, c.dtid) {
+				t.Errorf("got DTID %x want %x", parsed.DestTransactionID.Value, c.dtid)
+			}
+
+			// the same Transaction Portion is composed by NewMessage.
+			var opts []tcap.MessageOption
+			if c.otid != nil {
+				opts = append(opts, tcap.WithOTIDBytes(c.otid))
+			}
+			if c.dtid != nil {
+				opts = append(opts, tcap.WithDTIDBytes(c.dtid))
+			}
+			mb, err := tcap.NewMessage(c.mtype, opts...).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(mb, c.want) {
+				t.Errorf("NewMessage: got %x want %x", mb, c.want)
+			}
+		})
+	}
+
+	for _, tid := range [][]byte{{}, {1, 2, 3, 4, 5}} {
+		if _, err := tcap.NewTransactionWithTIDs(tcap.Begin, tid, nil); err != tcap.ErrInvalidTransactionID {
+			t.Errorf("%x: got %v want %v", tid, err, tcap.ErrInvalidTransactionID)
+		}
+	}
+}
+
+func TestTransactionAbortCause(t *testing.T) {
+	tx := tcap.NewAbort(0x11111111, tcap.ResourceLimitation, nil)
+	b, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x67, 0x09, 0x49, 0x04, 0x11, 0x11, 0x11, 0x11, 0x4a, 0x01, 0x04}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	parsed, err := tcap.ParseTransaction(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := parsed.AbortCause(), "ResourceLimitation"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
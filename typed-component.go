@@ -0,0 +1,310 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+// TypedComponent is a Component in the form that can be used in type switch.
+//
+// The concrete type is one of *InvokeComponent, *ReturnResultLastComponent,
+// *ReturnResultNotLastComponent, *ReturnErrorComponent and *RejectComponent.
+type TypedComponent interface {
+	// Component returns the TypedComponent as a Component, which can be
+	// given to NewComponents.
+	Component() *Component
+}
+
+// InvokeComponent is an Invoke Component.
+type InvokeComponent struct {
+	InvokeID      int
+	LinkedID      int // -1 if not present.
+	OperationCode int
+	IsLocal       bool
+	// OperationCodeOID is the global Operation Code in dot notation, which is
+	// used instead of OperationCode if IsLocal is false.
+	OperationCodeOID string
+	Parameter        *IE
+}
+
+// ReturnResultLastComponent is a ReturnResultLast Component.
+type ReturnResultLastComponent struct {
+	InvokeID         int
+	OperationCode    int // -1 if not present.
+	IsLocal          bool
+	OperationCodeOID string // used if IsLocal is false, as in InvokeComponent.
+	Parameter        *IE
+}
+
+// ReturnResultNotLastComponent is a ReturnResultNotLast Component.
+type ReturnResultNotLastComponent struct {
+	InvokeID         int
+	OperationCode    int // -1 if not present.
+	IsLocal          bool
+	OperationCodeOID string // used if IsLocal is false, as in InvokeComponent.
+	Parameter        *IE
+}
+
+// ReturnErrorComponent is a ReturnError Component.
+type ReturnErrorComponent struct {
+	InvokeID  int
+	ErrorCode int
+	IsLocal   bool
+	// ErrorCodeOID is the global Error Code in dot notation, which is used
+	// instead of ErrorCode if IsLocal is false.
+	ErrorCodeOID string
+	Parameter    *IE
+}
+
+// RejectComponent is a Reject Component.
+type RejectComponent struct {
+	// InvokeID is -1 if the Invoke ID is NULL, i.e., not derivable from the
+	// rejected Component.
+	InvokeID    int
+	ProblemType int
+	ProblemCode uint8
+}
+
+// Typed returns the Component as a TypedComponent.
+//
+// The Components created by NewInvoke, NewReturnResult, NewReturnError and
+// NewReject are returned as the corresponding concrete types. It returns nil
+// if the type of Component is unknown.
+func (c *Component) Typed() TypedComponent {
+	switch c.Type.Code() {
+	case Invoke:
+		t := &InvokeComponent{
+			InvokeID:  decodeIntIE(c.InvokeID),
+			LinkedID:  -1,
+			Parameter: c.Parameter,
+		}
+		if c.LinkedID != nil {
+			t.LinkedID = decodeIntIE(c.LinkedID)
+		}
+		t.OperationCode, t.IsLocal = decodeCodeIE(c.OperationCode)
+		if !t.IsLocal {
+			t.OperationCode = 0
+			t.OperationCodeOID = codeOID(c.OperationCode)
+		}
+		return t
+	case ReturnResultLast:
+		t := &ReturnResultLastComponent{
+			InvokeID:      decodeIntIE(c.InvokeID),
+			OperationCode: -1,
+			IsLocal:       true,
+			Parameter:     c.Parameter,
+		}
+		if c.OperationCode != nil {
+			t.OperationCode, t.IsLocal = decodeCodeIE(c.OperationCode)
+			if !t.IsLocal {
+				t.OperationCode = 0
+				t.OperationCodeOID = codeOID(c.OperationCode)
+			}
+		}
+		return t
+	case ReturnResultNotLast:
+		t := &ReturnResultNotLastComponent{
+			InvokeID:      decodeIntIE(c.InvokeID),
+			OperationCode: -1,
+			IsLocal:       true,
+			Parameter:     c.Parameter,
+		}
+		if c.OperationCode != nil {
+			t.OperationCode, t.IsLocal = decodeCodeIE(c.OperationCode)
+			if !t.IsLocal {
+				t.OperationCode = 0
+				t.OperationCodeOID = codeOID(c.OperationCode)
+			}
+		}
+		return t
+	case ReturnError:
+		t := &ReturnErrorComponent{
+			InvokeID:  decodeIntIE(c.InvokeID),
+			Parameter: c.Parameter,
+		}
+		t.ErrorCode, t.IsLocal = decodeCodeIE(c.ErrorCode)
+		if !t.IsLocal {
+			t.ErrorCode = 0
+			t.ErrorCodeOID = codeOID(c.ErrorCode)
+		}
+		return t
+	case Reject:
+		t := &RejectComponent{
+			InvokeID: decodeInvokeIDIE(c.InvokeID),
+		}
+		if p := c.ProblemCode; p != nil {
+			t.ProblemType = p.Tag.Code()
+			if len(p.Value) != 0 {
+				t.ProblemCode = p.Value[0]
+			}
+		}
+		return t
+	}
+	return nil
+}
+
+// Component returns the InvokeComponent as a Component.
+func (t *InvokeComponent) Component() *Component {
+	c := NewInvoke(t.InvokeID, -1, t.OperationCode, t.IsLocal, nil)
+	// NewInvoke takes the Linked ID of 0 as absent, while it is -1 here.
+	if t.LinkedID >= 0 {
+		c.LinkedID = NewIE(tagLinkedID, []byte{uint8(t.LinkedID)})
+	}
+	if !t.IsLocal {
+		setGlobalOperationCode(c, t.OperationCodeOID)
+	}
+	c.Parameter = t.Parameter
+	c.SetLength()
+	return c
+}
+
+// Component returns the ReturnResultLastComponent as a Component.
+func (t *ReturnResultLastComponent) Component() *Component {
+	return newReturnResultComponent(t.InvokeID, t.OperationCode, t.IsLocal, t.OperationCodeOID, true, t.Parameter)
+}
+
+// IsLast reports whether the result is the last segment, which is always true
+// for ReturnResultLastComponent.
+func (t *ReturnResultLastComponent) IsLast() bool {
+	return true
+}
+
+// Component returns the ReturnResultNotLastComponent as a Component.
+func (t *ReturnResultNotLastComponent) Component() *Component {
+	return newReturnResultComponent(t.InvokeID, t.OperationCode, t.IsLocal, t.OperationCodeOID, false, t.Parameter)
+}
+
+// IsLast reports whether the result is the last segment, which is always false
+// for ReturnResultNotLastComponent.
+func (t *ReturnResultNotLastComponent) IsLast() bool {
+	return false
+}
+
+func newReturnResultComponent(invID, opCode int, isLocal bool, opOID string, isLast bool, param *IE) *Component {
+	var c *Component
+	if opCode < 0 {
+		c = NewReturnResultWithoutOpCode(invID, isLast, nil)
+		if param != nil {
+			c.ResultRetres = &IE{Tag: TagResultSequence}
+		}
+	} else {
+		c = NewReturnResult(invID, opCode, isLocal, isLast, nil)
+		if !isLocal {
+			setGlobalOperationCode(c, opOID)
+		}
+	}
+	c.Parameter = param
+	c.SetLength()
+	return c
+}
+
+// setGlobalOperationCode replaces the Operation Code of c with the global one
+// given in dot notation as oid.
+func setGlobalOperationCode(c *Component, oid string) {
+	code, err := NewGlobalCode(oid)
+	if err != nil {
+		logf("failed to build Operation Code: %v", err)
+		return
+	}
+	c.OperationCode = code
+}
+
+// Component returns the ReturnErrorComponent as a Component.
+func (t *ReturnErrorComponent) Component() *Component {
+	c := NewReturnError(t.InvokeID, t.ErrorCode, t.IsLocal, nil)
+	if !t.IsLocal {
+		code, err := NewGlobalCode(t.ErrorCodeOID)
+		if err != nil {
+			logf("failed to build Error Code: %v", err)
+		} else {
+			c.ErrorCode = code
+		}
+	}
+	c.Parameter = t.Parameter
+	c.SetLength()
+	return c
+}
+
+// String returns the Problem of RejectComponent in string.
+// See ProblemString for the format.
+func (t *RejectComponent) String() string {
+	return ProblemString(t.ProblemType, t.ProblemCode)
+}
+
+// Component returns the RejectComponent as a Component.
+func (t *RejectComponent) Component() *Component {
+	return NewReject(t.InvokeID, t.ProblemType, t.ProblemCode, nil)
+}
+
+// TypedComponents returns the Components in Component Portion as TypedComponents.
+func (t *TCAP) TypedComponents() []TypedComponent {
+	if c := t.Components; c != nil {
+		var typed []TypedComponent
+		for _, cm := range c.Component {
+			if tc := cm.Typed(); tc != nil {
+				typed = append(typed, tc)
+			}
+		}
+		return typed
+	}
+
+	return nil
+}
+
+// MarshalTypedComponents returns the Component Portion that consists of the
+// TypedComponents given as comps in bytes.
+func MarshalTypedComponents(comps []TypedComponent) ([]byte, error) {
+	cs := make([]*Component, len(comps))
+	for i, tc := range comps {
+		cs[i] = tc.Component()
+	}
+	return NewComponents(cs...).MarshalBinary()
+}
+
+// ParseTypedComponents parses given byte sequence as a Component Portion, and
+// returns the Components in it as TypedComponents. The Components of unknown
+// type are skipped in the same way as TCAP.TypedComponents.
+func ParseTypedComponents(b []byte) ([]TypedComponent, error) {
+	c, err := ParseComponents(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var typed []TypedComponent
+	for _, cm := range c.Component {
+		if tc := cm.Typed(); tc != nil {
+			typed = append(typed, tc)
+		}
+	}
+	return typed, nil
+}
+
+// decodeIntIE decodes the Value of INTEGER IE as a signed integer.
+func decodeIntIE(ie *IE) int {
+	if ie == nil || len(ie.Value) == 0 {
+		return 0
+	}
+
+	v := int(int8(ie.Value[0]))
+	for _, x := range ie.Value[1:] {
+		v = v<<8 | int(x)
+	}
+	return v
+}
+
+// decodeInvokeIDIE decodes the Invoke ID in the same way as decodeIntIE, but
+// returns -1 for NULL, which is allowed in Reject.
+func decodeInvokeIDIE(ie *IE) int {
+	if ie != nil && ie.Tag == NewUniversalPrimitiveTag(5) {
+		return -1
+	}
+	return decodeIntIE(ie)
+}
+
+// decodeCodeIE decodes Operation Code or Error Code, and returns whether
+// it is a local one (INTEGER) or not.
+func decodeCodeIE(ie *IE) (int, bool) {
+	if ie == nil {
+		return 0, true
+	}
+	return decodeIntIE(ie), ie.Tag == NewUniversalPrimitiveTag(2)
+}
@@ -0,0 +1,167 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+// TypedDialoguePDU is a DialoguePDU in the form that can be used in type switch.
+//
+// The concrete type is one of *AARQPDU, *AAREPDU, *ABRTPDU and *AUDTPDU.
+type TypedDialoguePDU interface {
+	// DialoguePDU returns the TypedDialoguePDU as a DialoguePDU, which can be
+	// given to NewDialogue.
+	DialoguePDU() *DialoguePDU
+}
+
+// AARQPDU is an AARQ(Dialogue Request).
+type AARQPDU struct {
+	ProtocolVersion int // -1 if not present.
+	Context         uint8
+	ContextVersion  uint8
+	UserInformation *IE
+}
+
+// AAREPDU is an AARE(Dialogue Response).
+type AAREPDU struct {
+	ProtocolVersion  int // -1 if not present.
+	Context          uint8
+	ContextVersion   uint8
+	Result           uint8
+	DiagnosticSource int
+	DiagnosticReason uint8
+	UserInformation  *IE
+}
+
+// ABRTPDU is an ABRT(Dialogue Abort).
+type ABRTPDU struct {
+	AbortSource     AbortSource
+	UserInformation *IE
+}
+
+// AUDTPDU is an AUDT(Unidirectional Dialogue), which is sent in the Dialogue
+// Portion of Unidirectional.
+type AUDTPDU struct {
+	ProtocolVersion int // -1 if not present.
+	Context         uint8
+	ContextVersion  uint8
+	UserInformation *IE
+}
+
+// Typed returns the DialoguePDU as a TypedDialoguePDU.
+//
+// The DialoguePDUs created by NewAARQ, NewAARE and NewABRT are returned as the
+// corresponding concrete types. AUDT shares its Tag with AARQ and is returned
+// as *AARQPDU, as it can only be told apart by the dialogue type; use
+// TCAP.TypedDialoguePDU to get *AUDTPDU. It returns nil if the type of
+// DialoguePDU is unknown.
+func (d *DialoguePDU) Typed() TypedDialoguePDU {
+	switch d.Type.Code() {
+	case AARQ:
+		t := &AARQPDU{
+			ProtocolVersion: d.protocolVersion(),
+			UserInformation: d.UserInformation,
+		}
+		t.Context, t.ContextVersion = d.contextAndVersion()
+		return t
+	case AARE:
+		t := &AAREPDU{
+			ProtocolVersion: d.protocolVersion(),
+			UserInformation: d.UserInformation,
+		}
+		t.Context, t.ContextVersion = d.contextAndVersion()
+		t.Result, _ = d.DialogueResult()
+		t.DiagnosticSource, t.DiagnosticReason, _ = d.DialogueDiagnostic()
+		return t
+	case ABRT:
+		t := &ABRTPDU{
+			UserInformation: d.UserInformation,
+		}
+		if src := d.AbortSource; src != nil && len(src.Value) != 0 {
+			t.AbortSource = AbortSource(src.Value[0])
+		}
+		return t
+	}
+
+	return nil
+}
+
+// protocolVersion returns the version in the protocol-version, or -1 if it is
+// absent.
+func (d *DialoguePDU) protocolVersion() int {
+	if v := d.ProtocolVersion; v != nil && len(v.Value) != 0 {
+		return int(v.Value[len(v.Value)-1] >> 7)
+	}
+	return -1
+}
+
+// contextAndVersion returns the last two arcs of the Application Context Name,
+// i.e., the context and its version.
+func (d *DialoguePDU) contextAndVersion() (ctx, ver uint8) {
+	if acn := d.ApplicationContextName; acn != nil && len(acn.Value) >= 9 {
+		return acn.Value[7], acn.Value[8]
+	}
+	return 0, 0
+}
+
+// DialoguePDU returns the AARQPDU as a DialoguePDU.
+func (t *AARQPDU) DialoguePDU() *DialoguePDU {
+	return NewAARQWith(t.Context, t.ContextVersion, typedDialogueOptions(t.ProtocolVersion, t.UserInformation)...)
+}
+
+// DialoguePDU returns the AAREPDU as a DialoguePDU.
+func (t *AAREPDU) DialoguePDU() *DialoguePDU {
+	return NewAAREWith(
+		t.Context, t.ContextVersion, t.Result, t.DiagnosticSource, t.DiagnosticReason,
+		typedDialogueOptions(t.ProtocolVersion, t.UserInformation)...,
+	)
+}
+
+// DialoguePDU returns the ABRTPDU as a DialoguePDU.
+func (t *ABRTPDU) DialoguePDU() *DialoguePDU {
+	if t.UserInformation == nil {
+		return NewABRT(uint8(t.AbortSource))
+	}
+	return NewABRT(uint8(t.AbortSource), t.UserInformation)
+}
+
+// DialoguePDU returns the AUDTPDU as a DialoguePDU, which is to be put in the
+// Dialogue of UnidialogueAsID.
+func (t *AUDTPDU) DialoguePDU() *DialoguePDU {
+	return NewAARQWith(t.Context, t.ContextVersion, typedDialogueOptions(t.ProtocolVersion, t.UserInformation)...)
+}
+
+func typedDialogueOptions(protover int, userinfo *IE) []DialogueOption {
+	opts := []DialogueOption{WithoutProtocolVersion()}
+	if protover >= 0 {
+		opts[0] = WithProtocolVersion(protover)
+	}
+	if userinfo != nil {
+		opts = append(opts, WithUserInformation(userinfo))
+	}
+	return opts
+}
+
+// TypedDialoguePDU returns the DialoguePDU in Dialogue Portion as a
+// TypedDialoguePDU, or nil if TCAP has no DialoguePDU.
+//
+// Unlike DialoguePDU.Typed, the AUDT in the Dialogue of UnidialogueAsID is
+// returned as *AUDTPDU.
+func (t *TCAP) TypedDialoguePDU() TypedDialoguePDU {
+	d := t.Dialogue
+	if d == nil || d.DialoguePDU == nil {
+		return nil
+	}
+
+	typed := d.DialoguePDU.Typed()
+	if aarq, ok := typed.(*AARQPDU); ok {
+		if typ, err := d.DialogueType(); err == nil && typ == UnidialogueAsID {
+			return &AUDTPDU{
+				ProtocolVersion: aarq.ProtocolVersion,
+				Context:         aarq.Context,
+				ContextVersion:  aarq.ContextVersion,
+				UserInformation: aarq.UserInformation,
+			}
+		}
+	}
+	return typed
+}
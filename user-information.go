@@ -0,0 +1,146 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap
+
+import (
+	"strconv"
+	"strings"
+)
+
+// UserInformation represents an EXTERNAL in the user-information of Dialogue PDU.
+type UserInformation struct {
+	// DirectReference is the OID in dot notation, e.g., "0.4.0.0.1.1.1.1".
+	DirectReference string
+	// Value is the encoded single-ASN1-type, or the octet-aligned value.
+	Value []byte
+}
+
+// NewUserInformation creates a new user-information as an IE, which contains an
+// EXTERNAL with the direct-reference oid and data as single-ASN1-type.
+//
+// The returned IE can be given as userinfo to NewAARQ, NewAARE and NewABRT.
+func NewUserInformation(oid string, data []byte) (*IE, error) {
+	ref, err := encodeOID(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	for _, i := range []*IE{
+		NewIE(NewUniversalPrimitiveTag(6), ref),
+		NewIE(NewContextSpecificConstructorTag(0), data),
+	} {
+		b, err := i.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, b...)
+	}
+
+	ext, err := NewIE(NewUniversalConstructorTag(8), value).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return NewIE(NewContextSpecificConstructorTag(30), ext), nil
+}
+
+// ParseUserInformation decodes the EXTERNALs in the user-information given as ui.
+// It returns ErrInvalidUserInformation if ui is nil, i.e., absent.
+func ParseUserInformation(ui *IE) ([]*UserInformation, error) {
+	if ui == nil {
+		return nil, ErrInvalidUserInformation
+	}
+
+	exts, ok := parseAllAsBER(ui.Value)
+	if !ok {
+		return nil, ErrInvalidUserInformation
+	}
+
+	var infos []*UserInformation
+	for _, ext := range exts {
+		if ext.Tag != 0x28 {
+			return nil, &InvalidCodeError{Code: int(ext.Tag)}
+		}
+		fields, ok := parseAllAsBER(ext.Value)
+		if !ok {
+			return nil, ErrInvalidUserInformation
+		}
+
+		info := &UserInformation{}
+		for _, f := range fields {
+			switch f.Tag {
+			case 0x06:
+				oid, err := decodeOID(f.Value)
+				if err != nil {
+					return nil, err
+				}
+				info.DirectReference = oid
+			case 0xa0, 0x81:
+				info.Value = f.Value
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// encodeOID encodes the OID in dot notation into the contents octets.
+func encodeOID(oid string) ([]byte, error) {
+	arcs := strings.Split(oid, ".")
+	if len(arcs) < 2 {
+		return nil, ErrInvalidOID
+	}
+
+	nums := make([]uint64, len(arcs))
+	for i, a := range arcs {
+		n, err := strconv.ParseUint(a, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidOID
+		}
+		nums[i] = n
+	}
+	if nums[0] > 2 || (nums[0] < 2 && nums[1] > 39) {
+		return nil, ErrInvalidOID
+	}
+
+	var b []byte
+	subs := append([]uint64{nums[0]*40 + nums[1]}, nums[2:]...)
+	for _, n := range subs {
+		var enc []byte
+		enc = append(enc, uint8(n&0x7f))
+		for n >>= 7; n > 0; n >>= 7 {
+			enc = append([]byte{uint8(n&0x7f) | 0x80}, enc...)
+		}
+		b = append(b, enc...)
+	}
+	return b, nil
+}
+
+// decodeOID decodes the contents octets of OID into dot notation.
+func decodeOID(b []byte) (string, error) {
+	if len(b) == 0 || b[len(b)-1]&0x80 != 0 {
+		return "", ErrInvalidOID
+	}
+
+	var arcs []string
+	var n uint64
+	for _, x := range b {
+		n = n<<7 | uint64(x&0x7f)
+		if x&0x80 != 0 {
+			continue
+		}
+		if arcs == nil {
+			first := n / 40
+			if first > 2 {
+				first = 2
+			}
+			arcs = append(arcs, strconv.FormatUint(first, 10), strconv.FormatUint(n-first*40, 10))
+		} else {
+			arcs = append(arcs, strconv.FormatUint(n, 10))
+		}
+		n = 0
+	}
+	return strings.Join(arcs, "."), nil
+}
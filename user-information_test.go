@@ -0,0 +1,139 @@
+// Copyright 2019-2020 go-tcap authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package tcap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdddl/go-tcap"
+)
+
+func TestUserInformation(t *testing.T) {
+	ui, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0x30, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ui.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xbe, 0x0f, 0x28, 0x0d, 0x06, 0x07, 0x04, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01, 0xa0, 0x02, 0x30, 0x00,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %x want %x", b, want)
+	}
+
+	m := tcap.NewBeginInvoke(0x11111111, 1, 2, []byte{0x30, 0x00})
+	m.Dialogue = tcap.NewDialogue(tcap.DialogueAsID, 1, tcap.NewAARQ(1, tcap.NetworkLocUpContext, 3, ui), []byte{})
+	m.SetLength()
+	b, err = m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tcap.ParseBER(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := tcap.ParseUserInformation(parsed[0].Dialogue.DialoguePDU.UserInformation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(infos), 1; got != want {
+		t.Fatalf("got %d EXTERNALs want %d", got, want)
+	}
+	if got, want := infos[0].DirectReference, "0.4.0.0.1.1.1.1"; got != want {
+		t.Errorf("got DirectReference %s want %s", got, want)
+	}
+	if got, want := infos[0].Value, []byte{0x30, 0x00}; !bytes.Equal(got, want) {
+		t.Errorf("got Value %x want %x", got, want)
+	}
+}
+
+func TestUserInformationInvalidOID(t *testing.T) {
+	for _, oid := range []string{"", "0", "3.1", "0.40", "0.4.x"} {
+		if _, err := tcap.NewUserInformation(oid, nil); err != tcap.ErrInvalidOID {
+			t.Errorf("%q: got %v want %v", oid, err, tcap.ErrInvalidOID)
+		}
+	}
+}
+
+func TestParseAbsentUserInformation(t *testing.T) {
+	pdu := tcap.NewAARQ(1, tcap.NetworkLocUpContext, 3)
+	if _, err := tcap.ParseUserInformation(pdu.UserInformation); err != tcap.ErrInvalidUserInformation {
+		t.Errorf("got %v want %v", err, tcap.ErrInvalidUserInformation)
+	}
+}
+
+func TestUserInformationOIDRoundTrip(t *testing.T) {
+	for _, oid := range []string{"0.0.17.773.1.1.1", "1.2.840.113549", "2.999.3"} {
+		ui, err := tcap.NewUserInformation(oid, []byte{0x05, 0x00})
+		if err != nil {
+			t.Fatalf("%s: %v", oid, err)
+		}
+		infos, err := tcap.ParseUserInformation(ui)
+		if err != nil {
+			t.Fatalf("%s: %v", oid, err)
+		}
+		if got := infos[0].DirectReference; got != oid {
+			t.Errorf("got %s want %s", got, oid)
+		}
+	}
+}
+
+func TestUserInformationList(t *testing.T) {
+	ui1, err := tcap.NewUserInformation("0.4.0.0.1.1.1.1", []byte{0x30, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui2, err := tcap.NewUserInformation("0.4.0.0.1.1.2.2", []byte{0x04, 0x01, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pdus := map[string]*tcap.DialoguePDU{
+		"NewAARQ":     tcap.NewAARQ(1, tcap.NetworkLocUpContext, 3, ui1, ui2),
+		"NewAARQWith": tcap.NewAARQWith(tcap.NetworkLocUpContext, 3, tcap.WithUserInformation(ui1, ui2)),
+	}
+	for name, pdu := range pdus {
+		t.Run(name, func(t *testing.T) {
+			m := tcap.NewBeginInvoke(0x11111111, 1, 2, []byte{0x30, 0x00})
+			m.Dialogue = tcap.NewDialogue(tcap.DialogueAsID, 1, pdu, []byte{})
+			m.SetLength()
+			b, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := tcap.ParseBER(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			infos, err := parsed[0].Dialogue.DialoguePDU.UserInformationList()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []*tcap.UserInformation{
+				{DirectReference: "0.4.0.0.1.1.1.1", Value: []byte{0x30, 0x00}},
+				{DirectReference: "0.4.0.0.1.1.2.2", Value: []byte{0x04, 0x01, 0x01}},
+			}
+			if len(infos) != len(want) {
+				t.Fatalf("got %d EXTERNALs want %d", len(infos), len(want))
+			}
+			for i, info := range infos {
+				if info.DirectReference != want[i].DirectReference || !bytes.Equal(info.Value, want[i].Value) {
+					t.Errorf("#%d: got %+v want %+v", i, info, want[i])
+				}
+			}
+		})
+	}
+
+	infos, err := tcap.NewAARQ(1, tcap.NetworkLocUpContext, 3).UserInformationList()
+	if err != nil || infos != nil {
+		t.Errorf("got (%v, %v) want (nil, nil)", infos, err)
+	}
+}